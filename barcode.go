@@ -0,0 +1,760 @@
+package godocx
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"math"
+	"slices"
+	"strings"
+)
+
+// Built-in `qr`, `code128` and `ean13` functions, generating images (PNG or SVG) for the IMAGE command
+
+const (
+	DEFAULT_QR_SIZE         = 3     // cm
+	DEFAULT_BARCODE_HEIGHT  = 1.5   // cm
+	DEFAULT_BARCODE_MODULE  = 0.033 // cm, width of the narrowest bar
+	DEFAULT_QR_QUIET_ZONE   = 4     // modules
+	DEFAULT_BARCODE_QUIET_Z = 10    // modules
+)
+
+var QrErrorCorrectionLevels = []string{"L", "M", "Q", "H"}
+
+// newBarcodeFunctions returns the built-in functions, configured by the options
+func newBarcodeFunctions(options BarcodeOptions) map[string]Function {
+	return map[string]Function{
+		// qr(text, [sizeCm])
+		"qr": func(args ...any) VarValue {
+			text, size, _, err := getBarcodeArgs("qr", args, DEFAULT_QR_SIZE, 0)
+			if err != nil {
+				return err
+			}
+			level := options.ErrorCorrection
+			if level == "" {
+				level = "M"
+			}
+			matrix, err := encodeQr([]byte(text), level)
+			if err != nil {
+				return err
+			}
+			return newBarcodeImage(text, matrix, options, DEFAULT_QR_QUIET_ZONE, size, size)
+		},
+		// code128(text, [widthCm], [heightCm])
+		"code128": func(args ...any) VarValue {
+			text, width, height, err := getBarcodeArgs("code128", args, 0, DEFAULT_BARCODE_HEIGHT)
+			if err != nil {
+				return err
+			}
+			bars, err := encodeCode128(text)
+			if err != nil {
+				return err
+			}
+			return newBarcodeImage(text, [][]bool{bars}, options, DEFAULT_BARCODE_QUIET_Z, width, height)
+		},
+		// ean13(text, [widthCm], [heightCm])
+		"ean13": func(args ...any) VarValue {
+			text, width, height, err := getBarcodeArgs("ean13", args, 0, DEFAULT_BARCODE_HEIGHT)
+			if err != nil {
+				return err
+			}
+			bars, err := encodeEan13(text)
+			if err != nil {
+				return err
+			}
+			return newBarcodeImage(text, [][]bool{bars}, options, DEFAULT_BARCODE_QUIET_Z, width, height)
+		},
+	}
+}
+
+// getBarcodeArgs reads the arguments of the barcode functions: the text, then optional sizes
+func getBarcodeArgs(funcName string, args []any, defaultWidth float64, defaultHeight float64) (string, float64, float64, error) {
+	if len(args) == 0 || len(args) > 3 {
+		return "", 0, 0, fmt.Errorf("%s: expected the text to encode, and optionally its size in cm", funcName)
+	}
+	text := fmt.Sprint(args[0])
+	sizes := []float64{defaultWidth, defaultHeight}
+	for i, arg := range args[1:] {
+		size, ok := toNumber(arg)
+		if !ok || size <= 0 {
+			return "", 0, 0, fmt.Errorf("%s: invalid size %v", funcName, arg)
+		}
+		sizes[i] = size
+	}
+	return text, sizes[0], sizes[1], nil
+}
+
+// newBarcodeImage renders the modules of a barcode: a square matrix for QR codes,
+// or a single row of bars for linear barcodes (a width of 0 meaning the default module width)
+func newBarcodeImage(text string, matrix [][]bool, options BarcodeOptions, defaultQuietZone int, width float64, height float64) VarValue {
+	quietZone := options.QuietZone
+	if quietZone == 0 {
+		quietZone = defaultQuietZone
+	}
+	quietZone = max(quietZone, 0)
+	linear := len(matrix) == 1
+	columns := len(matrix[0]) + 2*quietZone
+	rows := len(matrix) + 2*quietZone
+	if linear {
+		rows = 1
+		if width <= 0 {
+			width = float64(columns) * DEFAULT_BARCODE_MODULE
+		}
+	}
+
+	pars := &ImagePars{Width: float32(width), Height: float32(height), Alt: text}
+	isDark := func(x int, y int) bool {
+		if linear {
+			y = quietZone
+		}
+		x, y = x-quietZone, y-quietZone
+		return y >= 0 && y < len(matrix) && x >= 0 && x < len(matrix[y]) && matrix[y][x]
+	}
+	switch options.Format {
+	case "", "png":
+		scale := 8 // pixels per module
+		imageHeight := rows * scale
+		if linear {
+			scale = 3
+			imageHeight = max(1, int(float64(columns*scale)*height/width))
+		}
+		img := image.NewPaletted(image.Rect(0, 0, columns*scale, imageHeight), color.Palette{color.White, color.Black})
+		for y := range imageHeight {
+			for x := range columns * scale {
+				if isDark(x/scale, y/scale) {
+					img.SetColorIndex(x, y, 1)
+				}
+			}
+		}
+		out := new(bytes.Buffer)
+		if err := png.Encode(out, img); err != nil {
+			return err
+		}
+		pars.Data, pars.Extension = out.Bytes(), ".png"
+	case "svg":
+		viewHeight := float64(rows)
+		if linear {
+			viewHeight = float64(columns) * height / width
+		}
+		var path strings.Builder
+		for y := range rows {
+			for x := 0; x < columns; x++ {
+				if !isDark(x, y) {
+					continue
+				}
+				run := 1
+				for x+run < columns && isDark(x+run, y) {
+					run++
+				}
+				if linear {
+					fmt.Fprintf(&path, "M%d 0h%dV%sh-%dz", x, run, formatChartNumber(viewHeight), run)
+				} else {
+					fmt.Fprintf(&path, "M%d %dh%dv1h-%dz", x, y, run, run)
+				}
+				x += run
+			}
+		}
+		svg := fmt.Sprintf(`<svg xmlns="http://www.w3.org/2000/svg" width="%scm" height="%scm" viewBox="0 0 %d %s" shape-rendering="crispEdges">`+
+			`<rect width="%d" height="%s" fill="#fff"/><path d="%s" fill="#000"/></svg>`,
+			formatChartNumber(width), formatChartNumber(height), columns, formatChartNumber(viewHeight),
+			columns, formatChartNumber(viewHeight), path.String())
+		pars.Data, pars.Extension = []byte(svg), ".svg"
+	default:
+		return fmt.Errorf("Unsupported barcode format %q (expected png or svg)", options.Format)
+	}
+	return pars
+}
+
+// Code 128
+
+// Widths of the bars and spaces of each Code 128 symbol (the last one being the stop pattern)
+var code128Patterns = []string{
+	"212222", "222122", "222221", "121223", "121322", "131222", "122213", "122312", "132212", "221213",
+	"221312", "231212", "112232", "122132", "122231", "113222", "123122", "123221", "223211", "221132",
+	"221231", "213212", "223112", "312131", "311222", "321122", "321221", "312212", "322112", "322211",
+	"212123", "212321", "232121", "111323", "131123", "131321", "112313", "132113", "132311", "211313",
+	"231113", "231311", "112133", "112331", "132131", "113123", "113321", "133121", "313121", "211331",
+	"231131", "213113", "213311", "213131", "311123", "311321", "331121", "312113", "312311", "332111",
+	"314111", "221411", "431111", "111224", "111422", "121124", "121421", "141122", "141221", "112214",
+	"112412", "122114", "122411", "142112", "142211", "241211", "221114", "413111", "241112", "134111",
+	"111242", "121142", "121241", "114212", "124112", "124211", "411212", "421112", "421211", "212141",
+	"214121", "412121", "111143", "111341", "131141", "114113", "114311", "411113", "411311", "113141",
+	"114131", "311141", "411131", "211412", "211214", "211232", "2331112",
+}
+
+const (
+	code128CodeC  = 99
+	code128CodeB  = 100
+	code128CodeA  = 101
+	code128StartA = 103
+	code128StartB = 104
+	code128StartC = 105
+	code128Stop   = 106
+)
+
+var code128StartCodes = map[int]int{code128CodeA: code128StartA, code128CodeB: code128StartB, code128CodeC: code128StartC}
+
+// encodeCode128 encodes ASCII text, using code set C for runs of digits, code set A for
+// control characters, and code set B otherwise
+func encodeCode128(text string) ([]bool, error) {
+	if text == "" {
+		return nil, errors.New("code128: empty text")
+	}
+	for _, r := range text {
+		if r > 127 {
+			return nil, fmt.Errorf("code128: unsupported character %q", r)
+		}
+	}
+	digitRun := func(i int) int {
+		n := 0
+		for i+n < len(text) && text[i+n] >= '0' && text[i+n] <= '9' {
+			n++
+		}
+		return n
+	}
+	// Code set A has the control characters, and code set B the lower case letters
+	codeSet := func(char byte, current int) int {
+		if char < 32 {
+			return code128CodeA
+		}
+		if char >= 96 || current != code128CodeA {
+			return code128CodeB
+		}
+		return code128CodeA
+	}
+	value := func(char byte, set int) int {
+		if char < 32 {
+			return int(char) + 64
+		}
+		return int(char) - 32
+	}
+
+	set := code128CodeC
+	if digitRun(0) < 4 {
+		set = codeSet(text[0], code128CodeB)
+	}
+	codes := []int{code128StartCodes[set]}
+	for i := 0; i < len(text); {
+		run := digitRun(i)
+		if set == code128CodeC {
+			if run >= 2 {
+				codes = append(codes, int(text[i]-'0')*10+int(text[i+1]-'0'))
+				i += 2
+				continue
+			}
+			set = codeSet(text[i], code128CodeB)
+			codes = append(codes, set)
+		}
+		// Switching to code set C is worth it for 4 digits at the end, 6 digits otherwise
+		if run >= 6 || (run >= 4 && i+run == len(text)) {
+			if run%2 == 1 {
+				codes = append(codes, value(text[i], set))
+				i++
+			}
+			codes = append(codes, code128CodeC)
+			set = code128CodeC
+			continue
+		}
+		if newSet := codeSet(text[i], set); newSet != set {
+			codes = append(codes, newSet)
+			set = newSet
+		}
+		codes = append(codes, value(text[i], set))
+		i++
+	}
+	checksum := codes[0]
+	for i, code := range codes[1:] {
+		checksum += (i + 1) * code
+	}
+	codes = append(codes, checksum%103, code128Stop)
+
+	bars := []bool{}
+	for _, code := range codes {
+		for i, width := range code128Patterns[code] {
+			bars = append(bars, slices.Repeat([]bool{i%2 == 0}, int(width-'0'))...)
+		}
+	}
+	return bars, nil
+}
+
+// EAN-13
+
+var ean13LeftCodes = []string{
+	"0001101", "0011001", "0010011", "0111101", "0100011", "0110001", "0101111", "0111011", "0110111", "0001011",
+}
+
+// Parity of the 6 left digits (L or G), given by the first digit
+var ean13Parities = []string{
+	"LLLLLL", "LLGLGG", "LLGGLG", "LLGGGL", "LGLLGG", "LGGLLG", "LGGGLL", "LGLGLG", "LGLGGL", "LGGLGL",
+}
+
+// encodeEan13 encodes 12 digits (the check digit is computed), or 13 digits (the check digit is verified)
+func encodeEan13(text string) ([]bool, error) {
+	text = strings.TrimSpace(text)
+	if len(text) != 12 && len(text) != 13 || strings.Trim(text, "0123456789") != "" {
+		return nil, fmt.Errorf("ean13: expected 12 or 13 digits, got %q", text)
+	}
+	digits := make([]int, len(text))
+	sum := 0
+	for i := range text {
+		digits[i] = int(text[i] - '0')
+		if i < 12 {
+			sum += digits[i] * (1 + 2*(i%2))
+		}
+	}
+	check := (10 - sum%10) % 10
+	if len(digits) == 13 && digits[12] != check {
+		return nil, fmt.Errorf("ean13: invalid check digit in %q (expected %d)", text, check)
+	}
+	digits = append(digits[:12], check)
+
+	bars := []bool{}
+	addPattern := func(pattern string, invert bool, reverse bool) {
+		modules := []bool{}
+		for _, c := range pattern {
+			modules = append(modules, (c == '1') != invert)
+		}
+		if reverse {
+			slices.Reverse(modules)
+		}
+		bars = append(bars, modules...)
+	}
+	addPattern("101", false, false)
+	for i, digit := range digits[1:7] {
+		// G codes are the reversed R codes, which are the inverted L codes
+		isG := ean13Parities[digits[0]][i] == 'G'
+		addPattern(ean13LeftCodes[digit], isG, isG)
+	}
+	addPattern("01010", false, false)
+	for _, digit := range digits[7:] {
+		addPattern(ean13LeftCodes[digit], true, false)
+	}
+	addPattern("101", false, false)
+	return bars, nil
+}
+
+// QR codes (byte mode), following ISO/IEC 18004
+
+var qrEccCodewordsPerBlock = map[string][]int{
+	"L": {-1, 7, 10, 15, 20, 26, 18, 20, 24, 30, 18, 20, 24, 26, 30, 22, 24, 28, 30, 28, 28, 28, 28, 30, 30, 26, 28, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30},
+	"M": {-1, 10, 16, 26, 18, 24, 16, 18, 22, 22, 26, 30, 22, 22, 24, 24, 28, 28, 26, 26, 26, 26, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28},
+	"Q": {-1, 13, 22, 18, 26, 18, 24, 18, 22, 20, 24, 28, 26, 24, 20, 30, 24, 28, 28, 26, 30, 28, 30, 30, 30, 30, 28, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30},
+	"H": {-1, 17, 28, 22, 16, 22, 28, 26, 26, 24, 28, 24, 28, 22, 24, 24, 30, 28, 28, 26, 28, 30, 24, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30},
+}
+
+var qrErrorCorrectionBlocks = map[string][]int{
+	"L": {-1, 1, 1, 1, 1, 1, 2, 2, 2, 2, 4, 4, 4, 4, 4, 6, 6, 6, 6, 7, 8, 8, 9, 9, 10, 12, 12, 12, 13, 14, 15, 16, 17, 18, 19, 19, 20, 21, 22, 24, 25},
+	"M": {-1, 1, 1, 1, 2, 2, 4, 4, 4, 5, 5, 5, 8, 9, 9, 10, 10, 11, 13, 14, 16, 17, 17, 18, 20, 21, 23, 25, 26, 28, 29, 31, 33, 35, 37, 38, 40, 43, 45, 47, 49},
+	"Q": {-1, 1, 1, 2, 2, 4, 4, 6, 6, 8, 8, 8, 10, 12, 16, 12, 17, 16, 18, 21, 20, 23, 23, 25, 27, 29, 34, 34, 35, 38, 40, 43, 45, 48, 51, 53, 56, 59, 62, 65, 68},
+	"H": {-1, 1, 1, 2, 4, 4, 4, 5, 6, 8, 8, 11, 11, 16, 16, 18, 16, 19, 21, 25, 25, 25, 34, 30, 32, 35, 37, 40, 42, 45, 48, 51, 54, 57, 60, 63, 66, 70, 74, 77, 81},
+}
+
+// Format bits of the error correction levels
+var qrEccFormatBits = map[string]int{"L": 1, "M": 0, "Q": 3, "H": 2}
+
+type qrCode struct {
+	version    int
+	size       int
+	modules    [][]bool
+	isFunction [][]bool
+}
+
+// qrRawDataModules is the number of data bits of a version, once the function patterns are removed
+func qrRawDataModules(version int) int {
+	result := (16*version+128)*version + 64
+	if version >= 2 {
+		numAlign := version/7 + 2
+		result -= (25*numAlign-10)*numAlign - 55
+		if version >= 7 {
+			result -= 36
+		}
+	}
+	return result
+}
+
+func qrDataCodewords(version int, level string) int {
+	return qrRawDataModules(version)/8 - qrEccCodewordsPerBlock[level][version]*qrErrorCorrectionBlocks[level][version]
+}
+
+// encodeQr encodes data in the smallest QR code possible, and returns its modules (true for dark)
+func encodeQr(data []byte, level string) ([][]bool, error) {
+	if !slices.Contains(QrErrorCorrectionLevels, level) {
+		return nil, fmt.Errorf("qr: invalid error correction level %q (one of %v)", level, QrErrorCorrectionLevels)
+	}
+	version := 1
+	for ; version <= 40; version++ {
+		countBits := 8
+		if version >= 10 {
+			countBits = 16
+		}
+		if 4+countBits+8*len(data) <= qrDataCodewords(version, level)*8 {
+			break
+		}
+	}
+	if version > 40 {
+		return nil, fmt.Errorf("qr: text too long (%d bytes)", len(data))
+	}
+
+	// Segment (byte mode), terminator and padding
+	bits := []bool{}
+	appendBits := func(value int, count int) {
+		for i := count - 1; i >= 0; i-- {
+			bits = append(bits, (value>>i)&1 != 0)
+		}
+	}
+	appendBits(0b0100, 4)
+	if version >= 10 {
+		appendBits(len(data), 16)
+	} else {
+		appendBits(len(data), 8)
+	}
+	for _, b := range data {
+		appendBits(int(b), 8)
+	}
+	capacity := qrDataCodewords(version, level) * 8
+	appendBits(0, min(4, capacity-len(bits)))
+	appendBits(0, (8-len(bits)%8)%8)
+	for pad := 0xEC; len(bits) < capacity; pad ^= 0xEC ^ 0x11 {
+		appendBits(pad, 8)
+	}
+	codewords := make([]byte, len(bits)/8)
+	for i, bit := range bits {
+		if bit {
+			codewords[i/8] |= 1 << (7 - i%8)
+		}
+	}
+
+	qr := newQrCode(version)
+	qr.drawFunctionPatterns(level)
+	qr.drawCodewords(qrAddEccAndInterleave(codewords, version, level))
+
+	bestMask, minPenalty := 0, -1
+	for mask := range 8 {
+		qr.applyMask(mask)
+		qr.drawFormatBits(level, mask)
+		if penalty := qr.penaltyScore(); minPenalty < 0 || penalty < minPenalty {
+			bestMask, minPenalty = mask, penalty
+		}
+		// Masks are XOR: applying it again undoes it
+		qr.applyMask(mask)
+	}
+	qr.applyMask(bestMask)
+	qr.drawFormatBits(level, bestMask)
+	return qr.modules, nil
+}
+
+func newQrCode(version int) *qrCode {
+	size := version*4 + 17
+	qr := &qrCode{version: version, size: size, modules: make([][]bool, size), isFunction: make([][]bool, size)}
+	for i := range size {
+		qr.modules[i] = make([]bool, size)
+		qr.isFunction[i] = make([]bool, size)
+	}
+	return qr
+}
+
+func (qr *qrCode) setFunctionModule(x int, y int, dark bool) {
+	qr.modules[y][x] = dark
+	qr.isFunction[y][x] = true
+}
+
+func (qr *qrCode) alignmentPatternPositions() []int {
+	if qr.version == 1 {
+		return nil
+	}
+	numAlign := qr.version/7 + 2
+	step := (qr.version*8 + numAlign*3 + 5) / (numAlign*4 - 4) * 2
+	positions := make([]int, numAlign)
+	positions[0] = 6
+	for i, pos := numAlign-1, qr.size-7; i >= 1; i, pos = i-1, pos-step {
+		positions[i] = pos
+	}
+	return positions
+}
+
+func (qr *qrCode) drawFunctionPatterns(level string) {
+	// Timing patterns
+	for i := range qr.size {
+		qr.setFunctionModule(6, i, i%2 == 0)
+		qr.setFunctionModule(i, 6, i%2 == 0)
+	}
+	// Finder patterns, with their separators
+	for _, center := range [][2]int{{3, 3}, {qr.size - 4, 3}, {3, qr.size - 4}} {
+		for dy := -4; dy <= 4; dy++ {
+			for dx := -4; dx <= 4; dx++ {
+				x, y := center[0]+dx, center[1]+dy
+				if x >= 0 && x < qr.size && y >= 0 && y < qr.size {
+					dist := max(math.Abs(float64(dx)), math.Abs(float64(dy)))
+					qr.setFunctionModule(x, y, dist != 2 && dist != 4)
+				}
+			}
+		}
+	}
+	// Alignment patterns, except where the finder patterns are
+	positions := qr.alignmentPatternPositions()
+	last := len(positions) - 1
+	for i, x := range positions {
+		for j, y := range positions {
+			if (i == 0 && j == 0) || (i == 0 && j == last) || (i == last && j == 0) {
+				continue
+			}
+			for dy := -2; dy <= 2; dy++ {
+				for dx := -2; dx <= 2; dx++ {
+					qr.setFunctionModule(x+dx, y+dy, max(math.Abs(float64(dx)), math.Abs(float64(dy))) != 1)
+				}
+			}
+		}
+	}
+	// Reserve the format bits, drawn once the mask is chosen
+	qr.drawFormatBits(level, 0)
+	// Version bits
+	if qr.version >= 7 {
+		rem := qr.version
+		for range 12 {
+			rem = (rem << 1) ^ ((rem >> 11) * 0x1F25)
+		}
+		bits := qr.version<<12 | rem
+		for i := range 18 {
+			bit := (bits>>i)&1 != 0
+			a, b := qr.size-11+i%3, i/3
+			qr.setFunctionModule(a, b, bit)
+			qr.setFunctionModule(b, a, bit)
+		}
+	}
+}
+
+func (qr *qrCode) drawFormatBits(level string, mask int) {
+	data := qrEccFormatBits[level]<<3 | mask
+	rem := data
+	for range 10 {
+		rem = (rem << 1) ^ ((rem >> 9) * 0x537)
+	}
+	bits := (data<<10 | rem) ^ 0x5412
+	bit := func(i int) bool { return (bits>>i)&1 != 0 }
+
+	// First copy, around the top left finder pattern
+	for i := range 6 {
+		qr.setFunctionModule(8, i, bit(i))
+	}
+	qr.setFunctionModule(8, 7, bit(6))
+	qr.setFunctionModule(8, 8, bit(7))
+	qr.setFunctionModule(7, 8, bit(8))
+	for i := 9; i < 15; i++ {
+		qr.setFunctionModule(14-i, 8, bit(i))
+	}
+	// Second copy, split between the two other finder patterns
+	for i := range 8 {
+		qr.setFunctionModule(qr.size-1-i, 8, bit(i))
+	}
+	for i := 8; i < 15; i++ {
+		qr.setFunctionModule(8, qr.size-15+i, bit(i))
+	}
+	qr.setFunctionModule(8, qr.size-8, true)
+}
+
+// drawCodewords places the data in zigzag, two columns at a time from the bottom right
+func (qr *qrCode) drawCodewords(data []byte) {
+	i := 0
+	for right := qr.size - 1; right >= 1; right -= 2 {
+		if right == 6 {
+			// Skip the vertical timing pattern
+			right = 5
+		}
+		for vert := range qr.size {
+			for j := range 2 {
+				x := right - j
+				y := vert
+				if (right+1)&2 == 0 {
+					y = qr.size - 1 - vert
+				}
+				if !qr.isFunction[y][x] && i < len(data)*8 {
+					qr.modules[y][x] = (data[i>>3]>>(7-i&7))&1 != 0
+					i++
+				}
+			}
+		}
+	}
+}
+
+func (qr *qrCode) applyMask(mask int) {
+	for y := range qr.size {
+		for x := range qr.size {
+			var invert bool
+			switch mask {
+			case 0:
+				invert = (x+y)%2 == 0
+			case 1:
+				invert = y%2 == 0
+			case 2:
+				invert = x%3 == 0
+			case 3:
+				invert = (x+y)%3 == 0
+			case 4:
+				invert = (x/3+y/2)%2 == 0
+			case 5:
+				invert = x*y%2+x*y%3 == 0
+			case 6:
+				invert = (x*y%2+x*y%3)%2 == 0
+			case 7:
+				invert = ((x+y)%2+x*y%3)%2 == 0
+			}
+			if invert && !qr.isFunction[y][x] {
+				qr.modules[y][x] = !qr.modules[y][x]
+			}
+		}
+	}
+}
+
+// penaltyScore rates a masked QR code: lower is easier to read
+func (qr *qrCode) penaltyScore() int {
+	const n1, n2, n3, n4 = 3, 3, 40, 10
+	result := 0
+	size := qr.size
+	addHistory := func(runLength int, history *[7]int) {
+		if history[0] == 0 {
+			// Light border before the first run
+			runLength += size
+		}
+		copy(history[1:], history[:6])
+		history[0] = runLength
+	}
+	countPatterns := func(history *[7]int) int {
+		n := history[1]
+		core := n > 0 && history[2] == n && history[3] == n*3 && history[4] == n && history[5] == n
+		count := 0
+		if core && history[0] >= n*4 && history[6] >= n {
+			count++
+		}
+		if core && history[6] >= n*4 && history[0] >= n {
+			count++
+		}
+		return count
+	}
+	lineScore := func(get func(i int) bool) int {
+		score := 0
+		runColor, runLength := false, 0
+		history := [7]int{}
+		for i := range size {
+			if get(i) == runColor {
+				runLength++
+				if runLength == 5 {
+					score += n1
+				} else if runLength > 5 {
+					score++
+				}
+			} else {
+				addHistory(runLength, &history)
+				if !runColor {
+					score += countPatterns(&history) * n3
+				}
+				runColor, runLength = get(i), 1
+			}
+		}
+		// Terminate with a light border
+		if runColor {
+			addHistory(runLength, &history)
+			runLength = 0
+		}
+		addHistory(runLength+size, &history)
+		return score + countPatterns(&history)*n3
+	}
+	for i := range size {
+		result += lineScore(func(x int) bool { return qr.modules[i][x] })
+		result += lineScore(func(y int) bool { return qr.modules[y][i] })
+	}
+	dark := 0
+	for y := range size {
+		for x := range size {
+			if qr.modules[y][x] {
+				dark++
+			}
+			if x < size-1 && y < size-1 {
+				color := qr.modules[y][x]
+				if color == qr.modules[y][x+1] && color == qr.modules[y+1][x] && color == qr.modules[y+1][x+1] {
+					result += n2
+				}
+			}
+		}
+	}
+	total := size * size
+	k := (int(math.Abs(float64(dark*20-total*10)))+total-1)/total - 1
+	return result + k*n4
+}
+
+// qrAddEccAndInterleave splits the data in blocks, adds their Reed-Solomon error
+// correction codewords, and interleaves them
+func qrAddEccAndInterleave(data []byte, version int, level string) []byte {
+	numBlocks := qrErrorCorrectionBlocks[level][version]
+	blockEccLen := qrEccCodewordsPerBlock[level][version]
+	rawCodewords := qrRawDataModules(version) / 8
+	numShortBlocks := numBlocks - rawCodewords%numBlocks
+	shortBlockLen := rawCodewords / numBlocks
+
+	divisor := reedSolomonDivisor(blockEccLen)
+	blocks := make([][]byte, numBlocks)
+	k := 0
+	for i := range numBlocks {
+		length := shortBlockLen - blockEccLen
+		if i >= numShortBlocks {
+			length++
+		}
+		block := slices.Clone(data[k : k+length])
+		k += length
+		ecc := reedSolomonRemainder(block, divisor)
+		if i < numShortBlocks {
+			// Placeholder, skipped when interleaving
+			block = append(block, 0)
+		}
+		blocks[i] = append(block, ecc...)
+	}
+	result := []byte{}
+	for i := range blocks[0] {
+		for j, block := range blocks {
+			if i != shortBlockLen-blockEccLen || j >= numShortBlocks {
+				result = append(result, block[i])
+			}
+		}
+	}
+	return result
+}
+
+func reedSolomonMultiply(x byte, y byte) byte {
+	z := 0
+	for i := 7; i >= 0; i-- {
+		z = (z << 1) ^ ((z >> 7) * 0x11D)
+		z ^= int((y>>i)&1) * int(x)
+	}
+	return byte(z)
+}
+
+func reedSolomonDivisor(degree int) []byte {
+	result := make([]byte, degree)
+	result[degree-1] = 1
+	root := byte(1)
+	for range degree {
+		for j := range degree {
+			result[j] = reedSolomonMultiply(result[j], root)
+			if j+1 < degree {
+				result[j] ^= result[j+1]
+			}
+		}
+		root = reedSolomonMultiply(root, 0x02)
+	}
+	return result
+}
+
+func reedSolomonRemainder(data []byte, divisor []byte) []byte {
+	result := make([]byte, len(divisor))
+	for _, b := range data {
+		factor := b ^ result[0]
+		copy(result, result[1:])
+		result[len(result)-1] = 0
+		for i := range result {
+			result[i] ^= reedSolomonMultiply(divisor[i], factor)
+		}
+	}
+	return result
+}
@@ -0,0 +1,169 @@
+package godocx
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Word limits bookmark names to 40 characters
+const MAX_BOOKMARK_NAME_LENGTH = 40
+
+var (
+	nameInterpolationRegexp = regexp.MustCompile(`\$\{(.*?)\}|\$\w+(?:\.\w+)*`)
+	invalidBookmarkRegexp   = regexp.MustCompile(`[^\p{L}\p{N}_]`)
+)
+
+// interpolate returns text with its `${expression}` and `$var` parts evaluated (e.g. `item_$idx`)
+func interpolate(text string, ctx *Context, data *ReportData) (string, error) {
+	var err error
+	interpolated := nameInterpolationRegexp.ReplaceAllStringFunc(text, func(match string) string {
+		expression := match
+		if strings.HasPrefix(match, "${") {
+			expression = match[2 : len(match)-1]
+		}
+		value, evalErr := runAndGetValue(expression, ctx, data)
+		if evalErr != nil {
+			err = evalErr
+			return ""
+		}
+		return fmt.Sprint(value)
+	})
+	return interpolated, err
+}
+
+// interpolateName returns a bookmark name, as used by BOOKMARK, LINK-TO and PAGEREF
+func interpolateName(text string, ctx *Context, data *ReportData) (string, error) {
+	name, err := interpolate(text, ctx, data)
+	if err != nil {
+		return "", err
+	}
+	return toBookmarkName(name), nil
+}
+
+func toBookmarkName(name string) string {
+	runes := []rune(invalidBookmarkRegexp.ReplaceAllString(strings.TrimSpace(name), "_"))
+	if len(runes) > MAX_BOOKMARK_NAME_LENGTH {
+		runes = runes[:MAX_BOOKMARK_NAME_LENGTH]
+	}
+	return string(runes)
+}
+
+// BOOKMARK <name>
+func processBookmark(data *ReportData, ctx *Context, rest string) error {
+	name, err := interpolateName(rest, ctx, data)
+	if err != nil {
+		return err
+	}
+	if name == "" {
+		return fmt.Errorf("Invalid bookmark name: %q", rest)
+	}
+	ctx.pendingBookmarks = append(ctx.pendingBookmarks, name)
+	return nil
+}
+
+// LINK-TO <bookmark> [label expression]
+func processLinkTo(data *ReportData, ctx *Context, rest string) error {
+	target, labelExpression, _ := strings.Cut(strings.TrimSpace(rest), " ")
+	anchor, err := interpolateName(target, ctx, data)
+	if err != nil {
+		return err
+	}
+	if anchor == "" {
+		return errors.New("Missing bookmark name")
+	}
+	label := anchor
+	if strings.TrimSpace(labelExpression) != "" {
+		value, err := runAndGetValue(labelExpression, ctx, data)
+		if err != nil {
+			return err
+		}
+		label = fmt.Sprint(value)
+	}
+
+	node := NewNonTextNode
+	var textRunPropsNode Node = node("w:rPr", nil, []Node{
+		node("w:u", map[string]string{"w:val": "single"}, nil),
+	})
+	if ctx.textRunPropsNode != nil {
+		textRunPropsNode = cloneNode(ctx.textRunPropsNode)
+	}
+	addRunNodes(ctx, node("w:hyperlink", map[string]string{"w:anchor": anchor, "w:history": "1"}, []Node{
+		node("w:r", nil, []Node{
+			textRunPropsNode,
+			node("w:t", map[string]string{"xml:space": "preserve"}, []Node{NewTextNode(label)}),
+		}),
+	}))
+	return nil
+}
+
+// PAGEREF <bookmark>
+func processPageRef(data *ReportData, ctx *Context, rest string) error {
+	anchor, err := interpolateName(rest, ctx, data)
+	if err != nil {
+		return err
+	}
+	if anchor == "" {
+		return errors.New("Missing bookmark name")
+	}
+	node := NewNonTextNode
+	addRunNodes(ctx, node("w:fldSimple", map[string]string{"w:instr": fmt.Sprintf(" PAGEREF %s \\h ", anchor)}, []Node{
+		node(R_TAG, nil, []Node{node(T_TAG, nil, []Node{NewTextNode(TOC_PAGE_PLACEHOLDER)})}),
+	}))
+	return nil
+}
+
+// fixBookmarks makes the ids and names of the bookmarks of a part unique (bookmarks of a
+// loop are repeated), keeping them when possible
+func fixBookmarks(out Node) {
+	starts := findDescendants(out, "w:bookmarkStart")
+	if len(starts) == 0 {
+		return
+	}
+	maxId := 0
+	for _, start := range starts {
+		if id, err := strconv.Atoi(start.Attrs["w:id"]); err == nil {
+			maxId = max(maxId, id)
+		}
+	}
+	usedIds, usedNames := map[string]bool{}, map[string]bool{}
+	open := map[string][]string{} // [original id]new ids of the bookmarks started
+	var visit func(node Node)
+	visit = func(node Node) {
+		for _, child := range node.Children() {
+			nonTextChild, ok := child.(*NonTextNode)
+			if !ok {
+				continue
+			}
+			id := nonTextChild.Attrs["w:id"]
+			switch nonTextChild.Tag {
+			case "w:bookmarkStart":
+				newId := id
+				if _, err := strconv.Atoi(id); err != nil || usedIds[id] {
+					maxId++
+					newId = fmt.Sprint(maxId)
+				}
+				usedIds[newId] = true
+				open[id] = append(open[id], newId)
+				nonTextChild.Attrs["w:id"] = newId
+
+				name := nonTextChild.Attrs["w:name"]
+				for i := 2; usedNames[name]; i++ {
+					name = fmt.Sprintf("%s_%d", nonTextChild.Attrs["w:name"], i)
+				}
+				usedNames[name] = true
+				nonTextChild.Attrs["w:name"] = name
+			case "w:bookmarkEnd":
+				if len(open[id]) > 0 {
+					nonTextChild.Attrs["w:id"] = open[id][len(open[id])-1]
+					open[id] = open[id][:len(open[id])-1]
+				}
+			default:
+				visit(nonTextChild)
+			}
+		}
+	}
+	visit(out)
+}
@@ -0,0 +1,163 @@
+package godocx
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+var (
+	SectionBreakTypes = []string{"nextPage", "continuous", "evenPage", "oddPage", "nextColumn"}
+	PageOrientations  = []string{"portrait", "landscape"}
+
+	PPR_ORDER = []string{
+		"w:pStyle", "w:keepNext", "w:keepLines", "w:pageBreakBefore", "w:framePr", "w:widowControl",
+		"w:numPr", "w:suppressLineNumbers", "w:pBdr", "w:shd", "w:tabs", "w:suppressAutoHyphens",
+		"w:kinsoku", "w:wordWrap", "w:overflowPunct", "w:topLinePunct", "w:autoSpaceDE",
+		"w:autoSpaceDN", "w:bidi", "w:adjustRightInd", "w:snapToGrid", "w:spacing", "w:ind",
+		"w:contextualSpacing", "w:mirrorIndents", "w:suppressOverlap", "w:jc", "w:textDirection",
+		"w:textAlignment", "w:textboxTightWrap", "w:outlineLvl", "w:divId", "w:cnfStyle", "w:rPr",
+		"w:sectPr", "w:pPrChange",
+	}
+	SECTPR_ORDER = []string{
+		"w:headerReference", "w:footerReference", "w:footnotePr", "w:endnotePr", "w:type", "w:pgSz",
+		"w:pgMar", "w:paperSrc", "w:pgBorders", "w:lnNumType", "w:pgNumType", "w:cols", "w:formProt",
+		"w:vAlign", "w:noEndnote", "w:titlePg", "w:textDirection", "w:bidi", "w:rtlGutter",
+		"w:docGrid", "w:printerSettings", "w:sectPrChange",
+	}
+	P_ORDER = []string{"w:pPr"}
+)
+
+const (
+	DEFAULT_SECTION_BREAK = "nextPage"
+	// A4 page size, in twips, for the sections without one
+	DEFAULT_PAGE_WIDTH  = 11906
+	DEFAULT_PAGE_HEIGHT = 16838
+)
+
+// sectionBreak holds the settings of the section following a SECTIONBREAK
+type sectionBreak struct {
+	kind        string
+	orientation string
+}
+
+// processBreak handles PAGEBREAK and COLUMNBREAK: `PAGEBREAK [condition]`
+func processBreak(data *ReportData, ctx *Context, cmdName string, rest string) error {
+	ok, err := evalBreakCondition(rest, ctx, data)
+	if err != nil || !ok {
+		return err
+	}
+	breakType := "page"
+	if cmdName == "COLUMNBREAK" {
+		breakType = "column"
+	}
+	addRunNodes(ctx, NewNonTextNode(R_TAG, nil, []Node{
+		NewNonTextNode("w:br", map[string]string{"w:type": breakType}, nil),
+	}))
+	return nil
+}
+
+// processSectionBreak handles `SECTIONBREAK [type] [orientation] [condition]`. The type and the
+// orientation apply to the section starting after the break.
+func processSectionBreak(data *ReportData, ctx *Context, rest string) error {
+	sectBreak := &sectionBreak{kind: DEFAULT_SECTION_BREAK}
+	args := strings.Fields(rest)
+	for len(args) > 0 {
+		if idx := slices.IndexFunc(SectionBreakTypes, func(t string) bool { return strings.EqualFold(t, args[0]) }); idx >= 0 {
+			sectBreak.kind = SectionBreakTypes[idx]
+		} else if idx := slices.IndexFunc(PageOrientations, func(o string) bool { return strings.EqualFold(o, args[0]) }); idx >= 0 {
+			sectBreak.orientation = PageOrientations[idx]
+		} else {
+			break
+		}
+		args = args[1:]
+	}
+	ok, err := evalBreakCondition(strings.Join(args, " "), ctx, data)
+	if err != nil || !ok {
+		return err
+	}
+	sectPr := NewNonTextNode("w:sectPr", map[string]string{}, nil)
+	ctx.sectionBreaks[sectPr] = sectBreak
+	ctx.pendingSectPr = sectPr
+	return nil
+}
+
+func evalBreakCondition(condition string, ctx *Context, data *ReportData) (bool, error) {
+	if strings.TrimSpace(condition) == "" {
+		return true, nil
+	}
+	value, err := runAndGetValue(condition, ctx, data)
+	if err != nil {
+		return false, err
+	}
+	return isTruthy(value), nil
+}
+
+// addSectionBreak ends the section with the paragraph p
+func addSectionBreak(p *NonTextNode, sectPr *NonTextNode) {
+	pPr := ensureChildInOrder(p, "w:pPr", P_ORDER)
+	setChildInOrder(pPr, sectPr, PPR_ORDER)
+}
+
+// fixSectionBreaks completes the section properties generated by SECTIONBREAK: each one is a copy of
+// those of the section it was in (i.e. the next template section), with the type and orientation
+// requested by the previous break, if any. The template section following the breaks gets the
+// settings of the last one.
+func fixSectionBreaks(out Node, breaks map[*NonTextNode]*sectionBreak) {
+	if len(breaks) == 0 {
+		return
+	}
+	sections := slices.DeleteFunc(findDescendants(out, "w:sectPr"), func(sectPr *NonTextNode) bool {
+		parent, ok := sectPr.Parent().(*NonTextNode)
+		return !ok || (parent.Tag != "w:pPr" && parent.Tag != "w:body")
+	})
+	var previous *sectionBreak
+	var generated []*NonTextNode
+	for _, sectPr := range sections {
+		if _, ok := breaks[sectPr]; ok {
+			generated = append(generated, sectPr)
+			continue
+		}
+		for _, generatedSectPr := range generated {
+			generatedSectPr.SetChildren(nil)
+			for _, child := range sectPr.Children() {
+				AddChild(generatedSectPr, cloneNode(child))
+			}
+			applySectionBreak(generatedSectPr, previous)
+			previous = breaks[generatedSectPr]
+		}
+		generated = nil
+		applySectionBreak(sectPr, previous)
+		previous = nil
+	}
+	// Breaks after the last template section keep the default layout
+	for _, generatedSectPr := range generated {
+		applySectionBreak(generatedSectPr, previous)
+		previous = breaks[generatedSectPr]
+	}
+}
+
+func applySectionBreak(sectPr *NonTextNode, sectBreak *sectionBreak) {
+	if sectBreak == nil {
+		return
+	}
+	setChildInOrder(sectPr, NewNonTextNode("w:type", map[string]string{"w:val": sectBreak.kind}, nil), SECTPR_ORDER)
+	if sectBreak.orientation == "" {
+		return
+	}
+	pgSz := ensureChildInOrder(sectPr, "w:pgSz", SECTPR_ORDER)
+	width, height := getIntAttr(pgSz, "w:w"), getIntAttr(pgSz, "w:h")
+	if width == 0 || height == 0 {
+		width, height = DEFAULT_PAGE_WIDTH, DEFAULT_PAGE_HEIGHT
+	}
+	if (sectBreak.orientation == "landscape") != (width > height) {
+		width, height = height, width
+	}
+	pgSz.Attrs["w:w"] = fmt.Sprint(width)
+	pgSz.Attrs["w:h"] = fmt.Sprint(height)
+	if sectBreak.orientation == "landscape" {
+		pgSz.Attrs["w:orient"] = "landscape"
+	} else {
+		delete(pgSz.Attrs, "w:orient")
+	}
+}
@@ -0,0 +1,716 @@
+package godocx
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+const (
+	CHART_NAMESPACE     = "http://schemas.openxmlformats.org/drawingml/2006/chart"
+	CHART_CONTENT_TYPE  = "application/vnd.openxmlformats-officedocument.drawingml.chart+xml"
+	CHART_RELATION_TYPE = "http://schemas.openxmlformats.org/officeDocument/2006/relationships/chart"
+
+	DEFAULT_CHART_WIDTH  = 15 // cm
+	DEFAULT_CHART_HEIGHT = 9  // cm
+)
+
+var ChartTypes = []string{"bar", "column", "line", "pie", "scatter"}
+
+// Chart ids of the axes, referenced by the plots
+const (
+	chartAxisX = "1001"
+	chartAxisY = "1002"
+)
+
+func chartVal(tag string, value string) *NonTextNode {
+	return NewNonTextNode(tag, map[string]string{"val": value}, nil)
+}
+
+func chartText(tag string, text string) *NonTextNode {
+	return NewNonTextNode(tag, map[string]string{}, []Node{NewTextNode(text)})
+}
+
+func formatChartNumber(value float64) string {
+	if math.IsNaN(value) || math.IsInf(value, 0) {
+		return ""
+	}
+	return strconv.FormatFloat(value, 'f', -1, 64)
+}
+
+// newChartTitle builds a `c:title`; vertical titles are rotated (for the value axis)
+func newChartTitle(title string, vertical bool) *NonTextNode {
+	bodyPr := map[string]string{}
+	if vertical {
+		bodyPr["rot"] = "-5400000"
+		bodyPr["vert"] = "horz"
+	}
+	return NewNonTextNode("c:title", map[string]string{}, []Node{
+		NewNonTextNode("c:tx", map[string]string{}, []Node{
+			NewNonTextNode("c:rich", map[string]string{}, []Node{
+				NewNonTextNode("a:bodyPr", bodyPr, nil),
+				NewNonTextNode("a:p", map[string]string{}, []Node{
+					NewNonTextNode("a:r", map[string]string{}, []Node{chartText("a:t", title)}),
+				}),
+			}),
+		}),
+		chartVal("c:overlay", "0"),
+	})
+}
+
+func newStrLit(values []string) *NonTextNode {
+	points := []Node{chartVal("c:ptCount", fmt.Sprint(len(values)))}
+	for i, value := range values {
+		points = append(points, NewNonTextNode("c:pt", map[string]string{"idx": fmt.Sprint(i)}, []Node{chartText("c:v", value)}))
+	}
+	return NewNonTextNode("c:strLit", map[string]string{}, points)
+}
+
+func newNumLit(values []float64) *NonTextNode {
+	points := []Node{chartText("c:formatCode", "General"), chartVal("c:ptCount", fmt.Sprint(len(values)))}
+	for i, value := range values {
+		// Missing values (NaN) are left out
+		if v := formatChartNumber(value); v != "" {
+			points = append(points, NewNonTextNode("c:pt", map[string]string{"idx": fmt.Sprint(i)}, []Node{chartText("c:v", v)}))
+		}
+	}
+	return NewNonTextNode("c:numLit", map[string]string{}, points)
+}
+
+// newSeriesShape gives a series its colour: the fill of bars and pie slices, the line of line charts,
+// the markers of scatter charts
+func newSeriesShape(chartType string, color string) []Node {
+	nodes := []Node{}
+	solidFill := func() *NonTextNode {
+		return NewNonTextNode("a:solidFill", map[string]string{}, []Node{chartVal("a:srgbClr", color)})
+	}
+	switch chartType {
+	case "line":
+		if color != "" {
+			nodes = append(nodes, NewNonTextNode("c:spPr", map[string]string{}, []Node{
+				NewNonTextNode("a:ln", map[string]string{"w": "28575", "cap": "rnd"}, []Node{solidFill()}),
+			}))
+		}
+		nodes = append(nodes, NewNonTextNode("c:marker", map[string]string{}, []Node{chartVal("c:symbol", "none")}))
+	case "scatter":
+		// Points only, no line
+		nodes = append(nodes, NewNonTextNode("c:spPr", map[string]string{}, []Node{
+			NewNonTextNode("a:ln", map[string]string{"w": "19050"}, []Node{NewNonTextNode("a:noFill", map[string]string{}, nil)}),
+		}))
+		marker := []Node{chartVal("c:symbol", "circle"), chartVal("c:size", "5")}
+		if color != "" {
+			marker = append(marker, NewNonTextNode("c:spPr", map[string]string{}, []Node{solidFill()}))
+		}
+		nodes = append(nodes, NewNonTextNode("c:marker", map[string]string{}, marker))
+	default:
+		if color != "" {
+			nodes = append(nodes, NewNonTextNode("c:spPr", map[string]string{}, []Node{solidFill()}))
+		}
+	}
+	return nodes
+}
+
+func newChartSeries(pars *ChartPars, idx int, series *ChartSeries) *NonTextNode {
+	children := []Node{
+		chartVal("c:idx", fmt.Sprint(idx)),
+		chartVal("c:order", fmt.Sprint(idx)),
+		NewNonTextNode("c:tx", map[string]string{}, []Node{chartText("c:v", series.Name)}),
+	}
+	color := ""
+	if pars.Type != "pie" {
+		// Pie charts get a colour per slice
+		// Validated by validateChartPars
+		color, _ = toHexColor(series.Color)
+	}
+	children = append(children, newSeriesShape(pars.Type, color)...)
+	switch pars.Type {
+	case "bar", "column":
+		children = append(children, chartVal("c:invertIfNegative", "0"))
+	case "pie":
+		children = append(children, chartVal("c:explosion", "0"))
+	}
+	if pars.Type == "scatter" {
+		children = append(children,
+			NewNonTextNode("c:xVal", map[string]string{}, []Node{newNumLit(series.XValues)}),
+			NewNonTextNode("c:yVal", map[string]string{}, []Node{newNumLit(series.Values)}),
+		)
+	} else {
+		children = append(children,
+			NewNonTextNode("c:cat", map[string]string{}, []Node{newStrLit(pars.Categories)}),
+			NewNonTextNode("c:val", map[string]string{}, []Node{newNumLit(series.Values)}),
+		)
+	}
+	if pars.Type == "line" || pars.Type == "scatter" {
+		children = append(children, chartVal("c:smooth", "0"))
+	}
+	return NewNonTextNode("c:ser", map[string]string{}, children)
+}
+
+// newChartAxis builds a category (`c:catAx`) or value (`c:valAx`) axis
+func newChartAxis(tag string, id string, crossAx string, position string, title string) *NonTextNode {
+	children := []Node{
+		chartVal("c:axId", id),
+		NewNonTextNode("c:scaling", map[string]string{}, []Node{chartVal("c:orientation", "minMax")}),
+		chartVal("c:delete", "0"),
+		chartVal("c:axPos", position),
+	}
+	if tag == "c:valAx" {
+		children = append(children, NewNonTextNode("c:majorGridlines", map[string]string{}, nil))
+	}
+	if title != "" {
+		children = append(children, newChartTitle(title, position == "l"))
+	}
+	if tag == "c:valAx" {
+		children = append(children, NewNonTextNode("c:numFmt", map[string]string{"formatCode": "General", "sourceLinked": "0"}, nil))
+	}
+	children = append(children,
+		chartVal("c:majorTickMark", "out"),
+		chartVal("c:minorTickMark", "none"),
+		chartVal("c:tickLblPos", "nextTo"),
+		chartVal("c:crossAx", crossAx),
+		chartVal("c:crosses", "autoZero"),
+	)
+	if tag == "c:catAx" {
+		children = append(children, chartVal("c:auto", "1"), chartVal("c:lblAlgn", "ctr"), chartVal("c:lblOffset", "100"))
+	} else {
+		children = append(children, chartVal("c:crossBetween", "between"))
+	}
+	return NewNonTextNode(tag, map[string]string{}, children)
+}
+
+func validateChartPars(pars *ChartPars) error {
+	if !slices.Contains(ChartTypes, pars.Type) {
+		return fmt.Errorf("A chart type (one of %v) needs to be provided", ChartTypes)
+	}
+	if len(pars.Series) == 0 {
+		return errors.New("A chart needs at least one series")
+	}
+	for _, series := range pars.Series {
+		if color, err := toHexColor(series.Color); err != nil || color == "auto" {
+			return fmt.Errorf("Series %q has an invalid color: %q (expected 6 hexadecimal digits)", series.Name, series.Color)
+		}
+	}
+	return validateChartSeries(pars, pars.Type == "scatter")
+}
+
+// newChartSpace builds the content of a chart part (`c:chartSpace`). The data is only
+// stored as literal values: no workbook is embedded.
+func newChartSpace(pars *ChartPars) *NonTextNode {
+	plotTags := map[string]string{
+		"bar":     "c:barChart",
+		"column":  "c:barChart",
+		"line":    "c:lineChart",
+		"pie":     "c:pieChart",
+		"scatter": "c:scatterChart",
+	}
+	plot := []Node{}
+	switch pars.Type {
+	case "bar", "column":
+		barDir := "col"
+		if pars.Type == "bar" {
+			barDir = "bar"
+		}
+		plot = append(plot, chartVal("c:barDir", barDir), chartVal("c:grouping", "clustered"))
+	case "line":
+		plot = append(plot, chartVal("c:grouping", "standard"))
+	case "scatter":
+		plot = append(plot, chartVal("c:scatterStyle", "lineMarker"))
+	}
+	plot = append(plot, chartVal("c:varyColors", boolToAttr(pars.Type == "pie")))
+	for i := range pars.Series {
+		plot = append(plot, newChartSeries(pars, i, &pars.Series[i]))
+	}
+	switch pars.Type {
+	case "bar", "column":
+		plot = append(plot, chartVal("c:gapWidth", "150"))
+	case "line":
+		plot = append(plot, chartVal("c:marker", "1"))
+	case "pie":
+		plot = append(plot, chartVal("c:firstSliceAng", "0"))
+	}
+
+	plotArea := []Node{NewNonTextNode("c:layout", map[string]string{}, nil)}
+	if pars.Type == "pie" {
+		plotArea = append(plotArea, NewNonTextNode(plotTags[pars.Type], map[string]string{}, plot))
+	} else {
+		plot = append(plot, chartVal("c:axId", chartAxisX), chartVal("c:axId", chartAxisY))
+		plotArea = append(plotArea, NewNonTextNode(plotTags[pars.Type], map[string]string{}, plot))
+		xTag, xPos, yPos := "c:catAx", "b", "l"
+		if pars.Type == "scatter" {
+			xTag = "c:valAx"
+		}
+		if pars.Type == "bar" {
+			// Horizontal bars: the categories are on the left
+			xPos, yPos = "l", "b"
+		}
+		plotArea = append(plotArea,
+			newChartAxis(xTag, chartAxisX, chartAxisY, xPos, pars.XAxisTitle),
+			newChartAxis("c:valAx", chartAxisY, chartAxisX, yPos, pars.YAxisTitle),
+		)
+	}
+
+	chart := []Node{}
+	if pars.Title != "" {
+		chart = append(chart, newChartTitle(pars.Title, false), chartVal("c:autoTitleDeleted", "0"))
+	} else {
+		chart = append(chart, chartVal("c:autoTitleDeleted", "1"))
+	}
+	chart = append(chart, NewNonTextNode("c:plotArea", map[string]string{}, plotArea))
+	if !pars.HideLegend {
+		chart = append(chart, NewNonTextNode("c:legend", map[string]string{}, []Node{
+			chartVal("c:legendPos", "b"),
+			chartVal("c:overlay", "0"),
+		}))
+	}
+	chart = append(chart, chartVal("c:plotVisOnly", "1"), chartVal("c:dispBlanksAs", "gap"))
+
+	return NewNonTextNode("c:chartSpace", map[string]string{
+		"xmlns:c": CHART_NAMESPACE,
+		"xmlns:a": "http://schemas.openxmlformats.org/drawingml/2006/main",
+		"xmlns:r": "http://schemas.openxmlformats.org/officeDocument/2006/relationships",
+	}, []Node{
+		chartVal("c:roundedCorners", "0"),
+		NewNonTextNode("c:chart", map[string]string{}, chart),
+	})
+}
+
+// processChart generates a chart part, and the drawing that displays it
+func processChart(ctx *Context, pars *ChartPars) error {
+	if err := validateChartPars(pars); err != nil {
+		return err
+	}
+	width, height := float64(pars.Width), float64(pars.Height)
+	if width <= 0 {
+		width = DEFAULT_CHART_WIDTH
+	}
+	if height <= 0 {
+		height = DEFAULT_CHART_HEIGHT
+	}
+	cx := fmt.Sprint(int(math.Round(width * EMU_PER_CM)))
+	cy := fmt.Sprint(int(math.Round(height * EMU_PER_CM)))
+
+	ctx.chartId += 1
+	relId := fmt.Sprintf("chart%d", ctx.chartId)
+	ctx.charts[relId] = newChartSpace(pars)
+
+	ctx.imageAndShapeIdIncrement += 1
+	id := fmt.Sprint(ctx.imageAndShapeIdIncrement)
+	alt := pars.Alt
+	if alt == "" {
+		alt = pars.Title
+	}
+	node := NewNonTextNode
+	drawing := node("w:drawing", map[string]string{}, []Node{
+		node("wp:inline", map[string]string{"distT": "0", "distB": "0", "distL": "0", "distR": "0"}, []Node{
+			node("wp:extent", map[string]string{"cx": cx, "cy": cy}, nil),
+			node("wp:effectExtent", map[string]string{"l": "0", "t": "0", "r": "0", "b": "0"}, nil),
+			node("wp:docPr", map[string]string{"id": id, "name": "Chart " + id, "descr": alt}, nil),
+			node("wp:cNvGraphicFramePr", map[string]string{}, nil),
+			node("a:graphic", map[string]string{"xmlns:a": "http://schemas.openxmlformats.org/drawingml/2006/main"}, []Node{
+				node("a:graphicData", map[string]string{"uri": CHART_NAMESPACE}, []Node{
+					node("c:chart", map[string]string{
+						"xmlns:c": CHART_NAMESPACE,
+						"xmlns:r": "http://schemas.openxmlformats.org/officeDocument/2006/relationships",
+						"r:id":    relId,
+					}, nil),
+				}),
+			}),
+		}),
+	})
+	// Like images, the drawing replaces the `w:t` of the command
+	ctx.pendingImageNode = &struct {
+		image   *NonTextNode
+		caption []*NonTextNode
+	}{image: drawing}
+	return nil
+}
+
+// Order of the children of a series (`c:ser`), all chart types together
+var SER_ORDER = []string{
+	"c:idx", "c:order", "c:tx", "c:spPr", "c:invertIfNegative", "c:pictureOptions", "c:marker", "c:explosion",
+	"c:dPt", "c:dLbls", "c:trendline", "c:errBars", "c:cat", "c:val", "c:xVal", "c:yVal", "c:shape",
+	"c:smooth", "c:bubbleSize", "c:bubble3D", "c:extLst",
+}
+
+// processPlaceholderChart binds a chart of the template to new data, when its alt
+// text (`descr` of its `wp:docPr`) is a CHART command.
+// The chart part is recorded by bindPlaceholderChart, once the drawing is fully generated.
+func processPlaceholderChart(data *ReportData, docPr *NonTextNode, ctx *Context) error {
+	cmdName, rest, err := getAltTextCommand(docPr.Attrs["descr"], ctx)
+	if err != nil {
+		return err
+	}
+	if cmdName != "CHART" {
+		return nil
+	}
+	varValue, err := runAndGetValue(rest, ctx, data)
+	if err != nil {
+		return err
+	}
+	chartPars, ok := varValue.(*ChartPars)
+	if !ok {
+		return errors.New("Not a chart as result of " + rest)
+	}
+	docPr.Attrs["descr"] = chartPars.Alt
+	ctx.pendingPlaceholderChart = chartPars
+	return nil
+}
+
+// bindPlaceholderChart records the chart part (relationship id) of a generated drawing
+func bindPlaceholderChart(drawing *NonTextNode, chartPars *ChartPars, ctx *Context) {
+	for _, chart := range findDescendants(drawing, "c:chart") {
+		if relId := chart.Attrs["r:id"]; relId != "" {
+			ctx.chartUpdates[relId] = chartPars
+		}
+	}
+}
+
+// resolveRelTarget gives the path in the zip of the target of a relationship
+func resolveRelTarget(baseDir string, target string) string {
+	if strings.HasPrefix(target, "/") {
+		return strings.TrimPrefix(target, "/")
+	}
+	return path.Join(baseDir, target)
+}
+
+func getNodeText(node Node) string {
+	var text strings.Builder
+	for _, child := range node.Children() {
+		if textNode, ok := child.(*TextNode); ok {
+			text.WriteString(textNode.Text)
+		} else {
+			text.WriteString(getNodeText(child))
+		}
+	}
+	return text.String()
+}
+
+// getChartTitle returns the text of the title of a chart part
+func getChartTitle(chartSpace *NonTextNode) string {
+	chart := findChild(chartSpace, "c:chart")
+	if chart == nil {
+		return ""
+	}
+	title := findChild(chart, "c:title")
+	if title == nil {
+		return ""
+	}
+	return strings.TrimSpace(getNodeText(title))
+}
+
+// bindChartsByTitle binds the charts of the template that are listed (by title) in the
+// ChartsByTitle option, unless they are already bound through their alt text
+func bindChartsByTitle(data *ReportData, ctx *Context, documentComponent string, zip *ZipArchive, chartUpdates map[string]*ChartPars) error {
+	rels, err := getRelsFromZip(zip, fmt.Sprintf("%s/_rels/%s.rels", TEMPLATE_PATH, documentComponent))
+	if err != nil {
+		return err
+	}
+	for _, rel := range findChildren(rels, "Relationship") {
+		if rel.Attrs["Type"] != CHART_RELATION_TYPE || chartUpdates[rel.Attrs["Id"]] != nil {
+			continue
+		}
+		chartSpace, err := parsePath(zip, resolveRelTarget(TEMPLATE_PATH, rel.Attrs["Target"]))
+		if err != nil {
+			return err
+		}
+		expr, ok := ctx.options.ChartsByTitle[getChartTitle(chartSpace)]
+		if !ok {
+			continue
+		}
+		varValue, err := runAndGetValue(expr, ctx, data)
+		if err != nil {
+			return err
+		}
+		chartPars, ok := varValue.(*ChartPars)
+		if !ok {
+			return errors.New("Not a chart as result of " + expr)
+		}
+		chartUpdates[rel.Attrs["Id"]] = chartPars
+	}
+	return nil
+}
+
+// UpdateCharts replaces the data of the template charts: the cached values of the chart
+// parts, and their embedded workbook if any. Their styling is kept.
+func UpdateCharts(chartUpdates map[string]*ChartPars, documentComponent string, zip *ZipArchive) error {
+	slog.Debug("Updating charts for " + documentComponent + "...")
+	if len(chartUpdates) == 0 {
+		return nil
+	}
+	rels, err := getRelsFromZip(zip, fmt.Sprintf("%s/_rels/%s.rels", TEMPLATE_PATH, documentComponent))
+	if err != nil {
+		return err
+	}
+	xmlOptions := XmlOptions{LiteralXmlDelimiter: DEFAULT_LITERAL_XML_DELIMITER}
+	for _, rel := range findChildren(rels, "Relationship") {
+		chartPars, ok := chartUpdates[rel.Attrs["Id"]]
+		if !ok || rel.Attrs["Type"] != CHART_RELATION_TYPE {
+			continue
+		}
+		chartPath := resolveRelTarget(TEMPLATE_PATH, rel.Attrs["Target"])
+		chartSpace, err := parsePath(zip, chartPath)
+		if err != nil {
+			return err
+		}
+		rows, err := updateChartSpace(chartSpace, chartPars)
+		if err != nil {
+			return fmt.Errorf("ChartError: %s: %w", chartPath, err)
+		}
+		zip.SetFile(chartPath, BuildXml(chartSpace, xmlOptions, ""))
+
+		// Embedded workbook, holding the data of the chart
+		externalData := findChild(chartSpace, "c:externalData")
+		chartRelsPath := path.Join(path.Dir(chartPath), "_rels", path.Base(chartPath)+".rels")
+		if externalData == nil || !zip.HasFile(chartRelsPath) {
+			continue
+		}
+		chartRels, err := getRelsFromZip(zip, chartRelsPath)
+		if err != nil {
+			return err
+		}
+		for _, chartRel := range findChildren(chartRels, "Relationship") {
+			if chartRel.Attrs["Id"] != externalData.Attrs["r:id"] || chartRel.Attrs["TargetMode"] == "External" ||
+				!strings.HasSuffix(chartRel.Attrs["Type"], "/package") {
+				continue
+			}
+			workbook, err := buildWorkbook(rows)
+			if err != nil {
+				return err
+			}
+			zip.SetFile(resolveRelTarget(path.Dir(chartPath), chartRel.Attrs["Target"]), workbook)
+		}
+	}
+	return nil
+}
+
+// setChartTitle replaces the text of the title of a chart, keeping its formatting
+func setChartTitle(chart *NonTextNode, text string) {
+	title := findChild(chart, "c:title")
+	if title == nil {
+		setChildInOrder(chart, newChartTitle(text, false), []string{"c:title", "c:autoTitleDeleted"})
+		setChildInOrder(chart, chartVal("c:autoTitleDeleted", "0"), []string{"c:title", "c:autoTitleDeleted"})
+		return
+	}
+	runs := findDescendants(title, "a:r")
+	if len(runs) == 0 {
+		setChildInOrder(title, findChild(newChartTitle(text, false), "c:tx"), []string{"c:tx"})
+		return
+	}
+	setChildInOrder(runs[0], chartText("a:t", text), []string{"a:rPr", "a:t"})
+	paragraph := runs[0].Parent()
+	for _, run := range runs[1:] {
+		removeNode(run)
+	}
+	for _, p := range findDescendants(title, "a:p") {
+		if p != paragraph {
+			removeNode(p)
+		}
+	}
+}
+
+func chartRange(column int, fromRow int, count int) string {
+	col := columnName(column)
+	if count <= 1 {
+		return fmt.Sprintf("%s!$%s$%d", WORKBOOK_SHEET_NAME, col, fromRow)
+	}
+	return fmt.Sprintf("%s!$%s$%d:$%s$%d", WORKBOOK_SHEET_NAME, col, fromRow, col, fromRow+count-1)
+}
+
+func newStrCache(tag string, values []string) *NonTextNode {
+	cache := newStrLit(values)
+	cache.Tag = tag
+	return cache
+}
+
+func newNumCache(tag string, values []float64, formatCode string) *NonTextNode {
+	cache := newNumLit(values)
+	cache.Tag = tag
+	findChild(cache, "c:formatCode").SetChildren([]Node{NewTextNode(formatCode)})
+	return cache
+}
+
+// newSeriesData builds the new content of `c:cat`, `c:val`, `c:xVal` or `c:yVal`, of the same
+// kind as the existing one: literal values, or a reference (to the embedded workbook) with its cache
+func newSeriesData(tag string, existing *NonTextNode, labels []string, values []float64, formula string) *NonTextNode {
+	literal, numeric := false, labels == nil
+	formatCode := "General"
+	if existing != nil {
+		literal = findChild(existing, "c:strLit") != nil || findChild(existing, "c:numLit") != nil
+		if numData := findChild(existing, "c:numRef"); numData != nil || findChild(existing, "c:numLit") != nil {
+			if numData == nil {
+				numData = findChild(existing, "c:numLit")
+			} else {
+				numData = findChild(numData, "c:numCache")
+			}
+			if code := findChild(numData, "c:formatCode"); code != nil && getNodeText(code) != "" {
+				formatCode = getNodeText(code)
+			}
+			if labels != nil {
+				// Numeric categories (e.g. years) stay numeric if possible
+				values = make([]float64, len(labels))
+				numeric = true
+				for i, label := range labels {
+					value, err := strconv.ParseFloat(strings.TrimSpace(label), 64)
+					if err != nil {
+						numeric = false
+						break
+					}
+					values[i] = value
+				}
+			}
+		}
+	}
+	var content *NonTextNode
+	switch {
+	case literal && numeric:
+		content = newNumCache("c:numLit", values, formatCode)
+	case literal:
+		content = newStrLit(labels)
+	case numeric:
+		content = NewNonTextNode("c:numRef", map[string]string{}, []Node{chartText("c:f", formula), newNumCache("c:numCache", values, formatCode)})
+	default:
+		content = NewNonTextNode("c:strRef", map[string]string{}, []Node{chartText("c:f", formula), newStrCache("c:strCache", labels)})
+	}
+	return NewNonTextNode(tag, map[string]string{}, []Node{content})
+}
+
+func validateChartSeries(chartPars *ChartPars, isScatter bool) error {
+	for _, series := range chartPars.Series {
+		if isScatter {
+			if len(series.XValues) != len(series.Values) {
+				return fmt.Errorf("Series %q has %d values, but %d x values", series.Name, len(series.Values), len(series.XValues))
+			}
+		} else if len(series.Values) != len(chartPars.Categories) {
+			return fmt.Errorf("Series %q has %d values, but there are %d categories", series.Name, len(series.Values), len(chartPars.Categories))
+		}
+	}
+	return nil
+}
+
+// updateChartSpace replaces the title and the series of a chart part. Extra series of the
+// template are removed, missing ones are copied from the last one.
+// It returns the data in a canonical layout (categories or x values in the first column,
+// a column per series), to which the references of the chart now point.
+func updateChartSpace(chartSpace *NonTextNode, chartPars *ChartPars) ([][]any, error) {
+	chart := findChild(chartSpace, "c:chart")
+	var plotArea *NonTextNode
+	if chart != nil {
+		plotArea = findChild(chart, "c:plotArea")
+	}
+	if plotArea == nil {
+		return nil, errors.New("Chart without plot area")
+	}
+	series := findDescendants(plotArea, "c:ser")
+	if len(series) == 0 {
+		return nil, errors.New("The template chart has no series")
+	}
+	if len(chartPars.Series) == 0 {
+		return nil, errors.New("A chart needs at least one series")
+	}
+	isScatter := findChild(series[0], "c:xVal") != nil
+	if err := validateChartSeries(chartPars, isScatter); err != nil {
+		return nil, err
+	}
+	if chartPars.Title != "" {
+		setChartTitle(chart, chartPars.Title)
+	}
+
+	for _, ser := range series[min(len(series), len(chartPars.Series)):] {
+		removeNode(ser)
+	}
+	series = series[:min(len(series), len(chartPars.Series))]
+	maxIdx := 0
+	for _, ser := range series {
+		maxIdx = max(maxIdx, getIntAttr(findChild(ser, "c:idx"), "val"))
+	}
+	for len(series) < len(chartPars.Series) {
+		last := series[len(series)-1]
+		ser := cloneNode(last).(*NonTextNode)
+		// Without explicit formatting, Word gives each series its own colour
+		for _, tag := range []string{"c:spPr", "c:dPt", "c:dLbls", "c:trendline"} {
+			for _, child := range findChildren(ser, tag) {
+				removeNode(child)
+			}
+		}
+		maxIdx++
+		setChildInOrder(ser, chartVal("c:idx", fmt.Sprint(maxIdx)), SER_ORDER)
+		setChildInOrder(ser, chartVal("c:order", fmt.Sprint(maxIdx)), SER_ORDER)
+		insertAfter(last, ser)
+		series = append(series, ser)
+	}
+
+	columnCount := len(series) + 1
+	if isScatter {
+		// A column of x values per series
+		columnCount = 2 * len(series)
+	}
+	rowCount := len(chartPars.Categories)
+	for _, s := range chartPars.Series {
+		rowCount = max(rowCount, len(s.Values))
+	}
+	rows := make([][]any, rowCount+1)
+	for i := range rows {
+		rows[i] = make([]any, columnCount)
+	}
+	if !isScatter {
+		for i, category := range chartPars.Categories {
+			rows[i+1][0] = category
+		}
+	}
+	for i, ser := range series {
+		data := &chartPars.Series[i]
+		column := i + 1
+		if isScatter {
+			column = 2*i + 1
+			for j, x := range data.XValues {
+				rows[j+1][column-1] = x
+			}
+		}
+
+		name := data.Name
+		if tx := findChild(ser, "c:tx"); name == "" && tx != nil {
+			name = getNodeText(findDescendantOrSelf(tx, "c:v"))
+		}
+		rows[0][column] = name
+		var tx *NonTextNode
+		if existing := findChild(ser, "c:tx"); existing != nil && findChild(existing, "c:v") != nil {
+			tx = NewNonTextNode("c:tx", map[string]string{}, []Node{chartText("c:v", name)})
+		} else {
+			tx = NewNonTextNode("c:tx", map[string]string{}, []Node{
+				NewNonTextNode("c:strRef", map[string]string{}, []Node{
+					chartText("c:f", chartRange(column, 1, 1)),
+					newStrCache("c:strCache", []string{name}),
+				}),
+			})
+		}
+		setChildInOrder(ser, tx, SER_ORDER)
+
+		for j, value := range data.Values {
+			rows[j+1][column] = value
+		}
+		formula := chartRange(column, 2, len(data.Values))
+		if isScatter {
+			setChildInOrder(ser, newSeriesData("c:xVal", findChild(ser, "c:xVal"), nil, data.XValues, chartRange(column-1, 2, len(data.XValues))), SER_ORDER)
+			setChildInOrder(ser, newSeriesData("c:yVal", findChild(ser, "c:yVal"), nil, data.Values, formula), SER_ORDER)
+		} else {
+			setChildInOrder(ser, newSeriesData("c:cat", findChild(ser, "c:cat"), chartPars.Categories, nil, chartRange(0, 2, len(chartPars.Categories))), SER_ORDER)
+			setChildInOrder(ser, newSeriesData("c:val", findChild(ser, "c:val"), nil, data.Values, formula), SER_ORDER)
+		}
+	}
+	return rows, nil
+}
+
+func findDescendantOrSelf(node *NonTextNode, tag string) *NonTextNode {
+	if node.Tag == tag {
+		return node
+	}
+	if found := findDescendants(node, tag); len(found) > 0 {
+		return found[0]
+	}
+	return node
+}
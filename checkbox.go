@@ -0,0 +1,90 @@
+package godocx
+
+import (
+	"strings"
+)
+
+const (
+	W14_NAMESPACE = "http://schemas.microsoft.com/office/word/2010/wordml"
+	CHECKBOX_FONT = "MS Gothic"
+)
+
+var FFDATA_CHECKBOX_ORDER = []string{"w:size", "w:sizeAuto", "w:default", "w:checked"}
+
+// CHECKBOX [control] <expression>: returns the glyph of the check box, if any. In a paragraph with
+// legacy check box form fields, the commands set these fields in order instead.
+func processCheckbox(data *ReportData, node Node, ctx *Context, rest string) (string, error) {
+	asControl := false
+	if first, expression, ok := strings.Cut(strings.TrimSpace(rest), " "); ok && strings.EqualFold(first, "control") {
+		asControl, rest = true, expression
+	}
+	value, err := runAndGetValue(rest, ctx, data)
+	if err != nil {
+		return "", err
+	}
+	checked := isTruthy(value)
+
+	if p := findAncestor(node, P_TAG); p != nil && len(ctx.pendingFormCheckboxes) < len(findDescendants(p, "w:checkBox")) {
+		ctx.pendingFormCheckboxes = append(ctx.pendingFormCheckboxes, checked)
+		return "", nil
+	}
+	if asControl {
+		addRunNodes(ctx, newCheckboxControl(checked, ctx.textRunPropsNode))
+		return "", nil
+	}
+	if checked {
+		return CHECKBOX_CHECKED, nil
+	}
+	return CHECKBOX_UNCHECKED, nil
+}
+
+// newCheckboxControl returns a check box content control, with the formatting of the command
+func newCheckboxControl(checked bool, rPr *NonTextNode) *NonTextNode {
+	node := NewNonTextNode
+	checkedVal, glyph := "0", CHECKBOX_UNCHECKED
+	if checked {
+		checkedVal, glyph = "1", CHECKBOX_CHECKED
+	}
+	runProps := node(RPR_TAG, nil, nil)
+	if rPr != nil {
+		runProps = cloneNode(rPr).(*NonTextNode)
+	}
+	setChildInOrder(runProps, node("w:rFonts", map[string]string{
+		"w:ascii": CHECKBOX_FONT, "w:eastAsia": CHECKBOX_FONT, "w:hAnsi": CHECKBOX_FONT, "w:hint": "eastAsia",
+	}, nil), RPR_ORDER)
+	sdtPr := node("w:sdtPr", nil, []Node{
+		node("w14:checkbox", nil, []Node{
+			node("w14:checked", map[string]string{"w14:val": checkedVal}, nil),
+			node("w14:checkedState", map[string]string{"w14:val": "2612", "w14:font": CHECKBOX_FONT}, nil),
+			node("w14:uncheckedState", map[string]string{"w14:val": "2610", "w14:font": CHECKBOX_FONT}, nil),
+		}),
+	})
+	if rPr != nil {
+		sdtPr.SetChildren(append([]Node{cloneNode(rPr)}, sdtPr.Children()...))
+		sdtPr.Children()[0].SetParent(sdtPr)
+	}
+	// The namespace is declared here, as the document may not declare it
+	return node(SDT_TAG, map[string]string{"xmlns:w14": W14_NAMESPACE}, []Node{
+		sdtPr,
+		node("w:sdtContent", nil, []Node{
+			node(R_TAG, nil, []Node{
+				runProps,
+				node(T_TAG, nil, []Node{NewTextNode(glyph)}),
+			}),
+		}),
+	})
+}
+
+// setFormCheckboxes sets the legacy check box form fields of a paragraph, in order
+func setFormCheckboxes(p *NonTextNode, values []bool) {
+	for i, checkbox := range findDescendants(p, "w:checkBox") {
+		if i >= len(values) {
+			return
+		}
+		checked := "0"
+		if values[i] {
+			checked = "1"
+		}
+		setChildInOrder(checkbox, NewNonTextNode("w:checked", map[string]string{"w:val": checked}, nil), FFDATA_CHECKBOX_ORDER)
+	}
+}
@@ -0,0 +1,147 @@
+package godocx
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+var (
+	// Elements removed by CleanTemplate: former properties, comment ranges, proofing marks and
+	// rendering hints
+	CLEANED_TAGS = []string{
+		"w:rPrChange", "w:pPrChange", "w:sectPrChange", "w:tblPrChange", "w:tblPrExChange", "w:trPrChange",
+		"w:tcPrChange", "w:tblGridChange", "w:numberingChange",
+		"w:moveFromRangeStart", "w:moveFromRangeEnd", "w:moveToRangeStart", "w:moveToRangeEnd",
+		"w:commentRangeStart", "w:commentRangeEnd", "w:commentReference", "w:annotationRef",
+		"w:proofErr", "w:lastRenderedPageBreak",
+	}
+	COMMENTS_RELATION_TYPES = []string{
+		COMMENTS_RELATION_TYPE,
+		"http://schemas.microsoft.com/office/2011/relationships/commentsExtended",
+		"http://schemas.microsoft.com/office/2016/09/relationships/commentsIds",
+		"http://schemas.microsoft.com/office/2018/08/relationships/commentsExtensible",
+	}
+)
+
+// CleanTemplate accepts the tracked changes of a document, and removes its comments, proofing
+// marks and rendering hints, which split the runs of text (and thus the commands)
+func CleanTemplate(root Node) {
+	cleanNode(root)
+}
+
+func cleanNode(node Node) {
+	children := []Node{}
+	var deletedMarkP *NonTextNode // paragraph whose mark is deleted: joined to the next one
+	for _, child := range node.Children() {
+		nonTextChild, ok := child.(*NonTextNode)
+		if !ok {
+			children = append(children, child)
+			continue
+		}
+		if deletedMarkP != nil && nonTextChild.Tag != P_TAG {
+			children = append(children, deletedMarkP)
+			deletedMarkP = nil
+		}
+		switch {
+		case slices.Contains(CLEANED_TAGS, nonTextChild.Tag):
+		case nonTextChild.Tag == "w:ins" || nonTextChild.Tag == "w:moveTo":
+			// Inserted content is kept, the markers of properties (paragraph marks, rows...) are removed
+			if !isPropertiesNode(node) {
+				cleanNode(nonTextChild)
+				children = append(children, nonTextChild.Children()...)
+			}
+		case nonTextChild.Tag == "w:del" || nonTextChild.Tag == "w:moveFrom":
+		case nonTextChild.Tag == TR_TAG && hasRevisionMarker(nonTextChild, "w:trPr", "w:del"):
+		case nonTextChild.Tag == P_TAG:
+			deletedMark := hasRevisionMarker(nonTextChild, "w:pPr", "w:del")
+			cleanNode(nonTextChild)
+			if deletedMarkP != nil {
+				joinParagraphs(deletedMarkP, nonTextChild)
+				deletedMarkP = nil
+			}
+			if deletedMark {
+				deletedMarkP = nonTextChild
+			} else {
+				children = append(children, nonTextChild)
+			}
+		case nonTextChild.Tag == R_TAG:
+			cleanNode(nonTextChild)
+			// Runs left without content (e.g. comment references) are removed
+			if slices.ContainsFunc(nonTextChild.Children(), func(child Node) bool {
+				nonTextChild, ok := child.(*NonTextNode)
+				return !ok || nonTextChild.Tag != RPR_TAG
+			}) {
+				children = append(children, nonTextChild)
+			}
+		default:
+			cleanNode(nonTextChild)
+			children = append(children, nonTextChild)
+		}
+	}
+	if deletedMarkP != nil {
+		children = append(children, deletedMarkP)
+	}
+	for _, child := range children {
+		child.SetParent(node)
+	}
+	node.SetChildren(children)
+}
+
+func isPropertiesNode(node Node) bool {
+	nonTextNode, ok := node.(*NonTextNode)
+	return ok && strings.HasSuffix(nonTextNode.Tag, "Pr")
+}
+
+// hasRevisionMarker returns whether a paragraph (mark) or row is marked as inserted or deleted
+func hasRevisionMarker(node *NonTextNode, propertiesTag string, marker string) bool {
+	properties := findChild(node, propertiesTag)
+	if properties == nil {
+		return false
+	}
+	if propertiesTag == "w:pPr" {
+		properties = findChild(properties, RPR_TAG)
+	}
+	return properties != nil && findChild(properties, marker) != nil
+}
+
+// joinParagraphs moves the content of a paragraph whose mark is deleted to the start of the next one
+func joinParagraphs(p *NonTextNode, next *NonTextNode) {
+	content := slices.DeleteFunc(slices.Clone(p.Children()), func(child Node) bool {
+		nonTextChild, ok := child.(*NonTextNode)
+		return ok && nonTextChild.Tag == "w:pPr"
+	})
+	children := slices.Clone(next.Children())
+	idx := 0
+	if len(children) > 0 {
+		if pPr, ok := children[0].(*NonTextNode); ok && pPr.Tag == "w:pPr" {
+			idx = 1
+		}
+	}
+	children = slices.Insert(children, idx, content...)
+	for _, child := range children {
+		child.SetParent(next)
+	}
+	next.SetChildren(children)
+}
+
+// ClearComments empties the comments parts of a document (see CleanTemplate)
+func ClearComments(documentComponent string, zip *ZipArchive) error {
+	rels, err := getRelsFromZip(zip, fmt.Sprintf("%s/_rels/%s.rels", TEMPLATE_PATH, documentComponent))
+	if err != nil {
+		return err
+	}
+	for _, relType := range COMMENTS_RELATION_TYPES {
+		partPath := findRelTarget(rels, relType)
+		if partPath == "" {
+			continue
+		}
+		part, err := parsePath(zip, partPath)
+		if err != nil {
+			return err
+		}
+		part.SetChildren(nil)
+		zip.SetFile(partPath, BuildXml(part, XmlOptions{LiteralXmlDelimiter: DEFAULT_LITERAL_XML_DELIMITER}, ""))
+	}
+	return nil
+}
@@ -0,0 +1,257 @@
+package godocx
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"image"
+	"image/draw"
+	"image/jpeg"
+	"image/png"
+	"math"
+	"slices"
+
+	xdraw "golang.org/x/image/draw"
+)
+
+// DEFAULT_JPEG_QUALITY is the quality used when re-encoding JPEG images without an explicit JpegQuality
+const DEFAULT_JPEG_QUALITY = 85
+
+// getImageCompression returns the compression settings of an image: its own, or the global ones
+func getImageCompression(ctx *Context, pars *ImagePars) *ImageCompression {
+	if pars.Compression != nil {
+		return pars.Compression
+	}
+	return ctx.options.ImageCompression
+}
+
+// compressImage downscales, re-encodes and strips the metadata of a (prepared) PNG or
+// JPEG image, according to its compression settings. The natural size of the image is
+// kept, by updating the resolution stored in the image.
+func compressImage(ctx *Context, pars *ImagePars) error {
+	settings := getImageCompression(ctx, pars)
+	if settings == nil || (pars.Extension != ".png" && canonicalExtension(pars.Extension) != ".jpg") {
+		return nil
+	}
+	isJpeg := pars.Extension != ".png"
+	orientation := 1
+	if isJpeg {
+		orientation = readJpegOrientation(pars.Data)
+	}
+	info, err := readImageInfo(pars.Data, pars.Extension)
+	if err != nil {
+		return err
+	}
+	if pars.Dpi > 0 {
+		info.dpiX, info.dpiY = float64(pars.Dpi), float64(pars.Dpi)
+	}
+
+	targetWidth, targetHeight := int(info.width), int(info.height)
+	if settings.MaxDpi > 0 {
+		width, height, err := getImageSizeFromInfo(pars, info)
+		if err != nil {
+			return err
+		}
+		if pars.Width > 0 || pars.Height > 0 {
+			// Keep the aspect ratio of the original image, regardless of rounding
+			pars.Width, pars.Height = float32(width), float32(height)
+		}
+		scale := min(1, width/2.54*float64(settings.MaxDpi)/info.width, height/2.54*float64(settings.MaxDpi)/info.height)
+		targetWidth = max(1, int(math.Round(info.width*scale)))
+		targetHeight = max(1, int(math.Round(info.height*scale)))
+	}
+	resize := targetWidth != int(info.width) || targetHeight != int(info.height)
+	reencode := resize || orientation != 1 || (isJpeg && settings.JpegQuality > 0)
+	if !reencode && !settings.StripMetadata {
+		return nil
+	}
+
+	key := fmt.Sprintf("%s|%v|%dx%d|%v", imageHash(&Image{Extension: pars.Extension, Data: pars.Data}), *settings, targetWidth, targetHeight, pars.Dpi)
+	if compressed, ok := ctx.convertedImages[key]; ok {
+		pars.Data, pars.Dpi = compressed.Data, 0
+		return nil
+	}
+	var data []byte
+	if isJpeg && !reencode {
+		// Lossless
+		data = stripJpegMetadata(pars.Data)
+	} else {
+		decoded, _, err := image.Decode(bytes.NewReader(pars.Data))
+		if err != nil {
+			return fmt.Errorf("Cannot decode image: %w", err)
+		}
+		img := orientImage(decoded, orientation)
+		if resize {
+			resized := image.NewRGBA(image.Rect(0, 0, targetWidth, targetHeight))
+			xdraw.CatmullRom.Scale(resized, resized.Bounds(), img, img.Bounds(), xdraw.Src, nil)
+			img = resized
+		}
+		out := new(bytes.Buffer)
+		if isJpeg {
+			quality := settings.JpegQuality
+			if quality <= 0 {
+				quality = DEFAULT_JPEG_QUALITY
+			}
+			err = jpeg.Encode(out, img, &jpeg.Options{Quality: min(quality, 100)})
+		} else {
+			encoder := png.Encoder{CompressionLevel: png.BestCompression}
+			err = encoder.Encode(out, img)
+		}
+		if err != nil {
+			return fmt.Errorf("Cannot encode image: %w", err)
+		}
+		data = out.Bytes()
+	}
+	// The resolution changes with the pixel size, so that the natural size doesn't
+	dpiX := info.dpiX * float64(targetWidth) / info.width
+	dpiY := info.dpiY * float64(targetHeight) / info.height
+	if isJpeg {
+		data = setJpegDpi(data, dpiX, dpiY)
+	} else {
+		data = setPngDpi(data, dpiX, dpiY)
+	}
+	ctx.convertedImages[key] = &Image{Extension: pars.Extension, Data: data}
+	pars.Data, pars.Dpi = data, 0
+	return nil
+}
+
+// readJpegOrientation reads the EXIF orientation of a JPEG image (1 to 8, 1 being upright)
+func readJpegOrientation(data []byte) int {
+	pos := 2
+	for pos+4 <= len(data) && data[pos] == 0xFF {
+		marker := data[pos+1]
+		length := int(binary.BigEndian.Uint16(data[pos+2 : pos+4]))
+		if marker == 0xDA || length < 2 || pos+2+length > len(data) {
+			break
+		}
+		segment := data[pos+4 : pos+2+length]
+		if marker == 0xE1 && len(segment) >= 14 && string(segment[0:6]) == "Exif\x00\x00" {
+			return readExifOrientation(segment[6:])
+		}
+		pos += 2 + length
+	}
+	return 1
+}
+
+// readExifOrientation reads the Orientation tag (0x0112) of the first IFD of an EXIF (TIFF) block
+func readExifOrientation(tiff []byte) int {
+	var order binary.ByteOrder
+	switch string(tiff[0:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return 1
+	}
+	ifd := int(order.Uint32(tiff[4:8]))
+	if ifd+2 > len(tiff) {
+		return 1
+	}
+	count := int(order.Uint16(tiff[ifd : ifd+2]))
+	for i := range count {
+		entry := ifd + 2 + i*12
+		if entry+12 > len(tiff) {
+			break
+		}
+		if order.Uint16(tiff[entry:entry+2]) == 0x0112 {
+			orientation := int(order.Uint16(tiff[entry+8 : entry+10]))
+			if orientation < 1 || orientation > 8 {
+				return 1
+			}
+			return orientation
+		}
+	}
+	return 1
+}
+
+// orientImage applies an EXIF orientation to an image, so that it is upright
+func orientImage(img image.Image, orientation int) image.Image {
+	if orientation <= 1 || orientation > 8 {
+		return img
+	}
+	bounds := img.Bounds()
+	src := image.NewRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	draw.Draw(src, src.Bounds(), img, bounds.Min, draw.Src)
+	w, h := bounds.Dx(), bounds.Dy()
+	dstWidth, dstHeight := w, h
+	if orientation >= 5 {
+		dstWidth, dstHeight = h, w
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, dstWidth, dstHeight))
+	for y := range h {
+		for x := range w {
+			var dx, dy int
+			switch orientation {
+			case 2: // mirrored
+				dx, dy = w-1-x, y
+			case 3: // rotated by 180°
+				dx, dy = w-1-x, h-1-y
+			case 4: // mirrored vertically
+				dx, dy = x, h-1-y
+			case 5: // transposed
+				dx, dy = y, x
+			case 6: // rotated by 90° clockwise
+				dx, dy = h-1-y, x
+			case 7: // transversed
+				dx, dy = h-1-y, w-1-x
+			case 8: // rotated by 90° counterclockwise
+				dx, dy = y, w-1-x
+			}
+			copy(dst.Pix[dst.PixOffset(dx, dy):dst.PixOffset(dx, dy)+4], src.Pix[src.PixOffset(x, y):src.PixOffset(x, y)+4])
+		}
+	}
+	return dst
+}
+
+// stripJpegMetadata removes the EXIF/XMP/ICC... (`APP1` to `APP15`) and comment segments of a JPEG image
+func stripJpegMetadata(data []byte) []byte {
+	out := bytes.NewBuffer(data[:2:2])
+	pos := 2
+	for pos+4 <= len(data) && data[pos] == 0xFF {
+		marker := data[pos+1]
+		length := int(binary.BigEndian.Uint16(data[pos+2 : pos+4]))
+		if marker == 0xDA || length < 2 || pos+2+length > len(data) {
+			break
+		}
+		if !(marker >= 0xE1 && marker <= 0xEF) && marker != 0xFE {
+			out.Write(data[pos : pos+2+length])
+		}
+		pos += 2 + length
+	}
+	out.Write(data[pos:])
+	return out.Bytes()
+}
+
+// setJpegDpi replaces the JFIF `APP0` segment of a JPEG image, to store its resolution
+func setJpegDpi(data []byte, dpiX, dpiY float64) []byte {
+	segment := []byte{0xFF, 0xE0, 0, 16, 'J', 'F', 'I', 'F', 0, 1, 1, 1, 0, 0, 0, 0, 0, 0}
+	binary.BigEndian.PutUint16(segment[12:14], uint16(min(math.Round(dpiX), math.MaxUint16)))
+	binary.BigEndian.PutUint16(segment[14:16], uint16(min(math.Round(dpiY), math.MaxUint16)))
+	out := bytes.NewBuffer(append(data[:2:2], segment...))
+	pos := 2
+	// Skip the existing APP0 segment, if any
+	if pos+4 <= len(data) && data[pos] == 0xFF && data[pos+1] == 0xE0 {
+		pos += 2 + max(2, int(binary.BigEndian.Uint16(data[pos+2:pos+4])))
+	}
+	out.Write(data[min(pos, len(data)):])
+	return out.Bytes()
+}
+
+// setPngDpi adds a `pHYs` chunk to a PNG image (that doesn't have one), to store its resolution
+func setPngDpi(data []byte, dpiX, dpiY float64) []byte {
+	// Signature (8) and IHDR chunk (25)
+	const headerLength = 33
+	if len(data) < headerLength {
+		return data
+	}
+	chunk := make([]byte, 21)
+	binary.BigEndian.PutUint32(chunk[0:4], 9)
+	copy(chunk[4:8], "pHYs")
+	binary.BigEndian.PutUint32(chunk[8:12], uint32(math.Round(dpiX/0.0254)))
+	binary.BigEndian.PutUint32(chunk[12:16], uint32(math.Round(dpiY/0.0254)))
+	chunk[16] = 1 // pixels per meter
+	binary.BigEndian.PutUint32(chunk[17:21], crc32.ChecksumIEEE(chunk[4:17]))
+	return slices.Concat(data[:headerLength], chunk, data[headerLength:])
+}
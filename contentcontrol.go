@@ -0,0 +1,293 @@
+package godocx
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	SDT_TAG                = "w:sdt"
+	PLACEHOLDER_TEXT_STYLE = "PlaceholderText"
+	CHECKBOX_CHECKED       = "☒"
+	CHECKBOX_UNCHECKED     = "☐"
+)
+
+// FillContentControls fills the content controls (`w:sdt`) bound to a data path by their tag (or
+// alias). The filled controls are kept, without their placeholder, or replaced with their content
+// if unwrap is set. The other controls are left as they are.
+func FillContentControls(node Node, data *ReportData, unwrap bool) error {
+	if data == nil {
+		return nil
+	}
+	return fillContentControls(node, *data, unwrap)
+}
+
+func fillContentControls(node Node, data ReportData, unwrap bool) error {
+	children := []Node{}
+	for _, child := range node.Children() {
+		nonTextChild, ok := child.(*NonTextNode)
+		if !ok || nonTextChild.Tag != SDT_TAG {
+			if ok {
+				if err := fillContentControls(nonTextChild, data, unwrap); err != nil {
+					return err
+				}
+			}
+			children = append(children, child)
+			continue
+		}
+		filled, err := fillContentControl(nonTextChild, data, unwrap)
+		if err != nil {
+			return err
+		}
+		children = append(children, filled...)
+	}
+	for _, child := range children {
+		child.SetParent(node)
+	}
+	node.SetChildren(children)
+	return nil
+}
+
+// fillContentControl returns the nodes replacing sdt: the filled control, or its content
+func fillContentControl(sdt *NonTextNode, data ReportData, unwrap bool) ([]Node, error) {
+	sdtPr, content := findChild(sdt, "w:sdtPr"), findChild(sdt, "w:sdtContent")
+	if sdtPr == nil || content == nil {
+		return []Node{sdt}, nil
+	}
+	path, value, ok := getContentControlValue(sdtPr, data)
+	if !ok {
+		err := fillContentControls(content, data, unwrap)
+		return []Node{sdt}, err
+	}
+
+	if err := setContentControlValue(sdtPr, content, value, data, unwrap); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if unwrap {
+		return content.Children(), nil
+	}
+	return []Node{sdt}, nil
+}
+
+// setContentControlValue fills a content control according to its type, and removes its placeholder
+func setContentControlValue(sdtPr *NonTextNode, content *NonTextNode, value VarValue, data ReportData, unwrap bool) error {
+	if findChild(sdtPr, "w15:repeatingSection") != nil {
+		if err := fillRepeatingSection(content, value, data, unwrap); err != nil {
+			return err
+		}
+	} else if checkbox := findChild(sdtPr, "w14:checkbox"); checkbox != nil {
+		fillCheckbox(checkbox, content, isTruthy(value))
+	} else if date := findChild(sdtPr, "w:date"); date != nil {
+		fillDate(date, content, value)
+	} else if list := findChild(sdtPr, "w:dropDownList"); list != nil {
+		setContentControlText(content, getListItemText(list, value))
+	} else if list := findChild(sdtPr, "w:comboBox"); list != nil {
+		setContentControlText(content, getListItemText(list, value))
+	} else {
+		setContentControlText(content, formatContentControlText(value))
+	}
+	if showingPlaceholder := findChild(sdtPr, "w:showingPlcHdr"); showingPlaceholder != nil {
+		removeNode(showingPlaceholder)
+	}
+	return nil
+}
+
+// getContentControlValue returns the data path of a content control (its tag or alias) and its value
+func getContentControlValue(sdtPr *NonTextNode, data ReportData) (string, VarValue, bool) {
+	for _, tag := range []string{"w:tag", "w:alias"} {
+		node := findChild(sdtPr, tag)
+		if node == nil || strings.TrimSpace(node.Attrs["w:val"]) == "" {
+			continue
+		}
+		path := strings.TrimSpace(node.Attrs["w:val"])
+		if value, ok := data.GetValue(path); ok {
+			return path, value, true
+		}
+	}
+	return "", nil, false
+}
+
+// fillRepeatingSection repeats the first item of a repeating section for each element of value. The
+// controls of an item are bound to the fields of its element, or else to the report data.
+func fillRepeatingSection(content *NonTextNode, value VarValue, data ReportData, unwrap bool) error {
+	reflected := reflect.ValueOf(value)
+	if reflected.Kind() != reflect.Slice {
+		return errors.New("Not an array")
+	}
+	children := slices.Clone(content.Children())
+	idx := slices.IndexFunc(children, isRepeatingSectionItem)
+	if idx < 0 {
+		return errors.New("Missing repeating section item")
+	}
+	template := children[idx]
+
+	items := []Node{}
+	for i := range reflected.Len() {
+		item := cloneNode(template).(*NonTextNode)
+		itemContent := findChild(item, "w:sdtContent")
+		if err := fillContentControls(itemContent, getItemData(data, reflected.Index(i).Interface()), unwrap); err != nil {
+			return err
+		}
+		if showingPlaceholder := findChild(findChild(item, "w:sdtPr"), "w:showingPlcHdr"); showingPlaceholder != nil {
+			removeNode(showingPlaceholder)
+		}
+		if unwrap {
+			items = append(items, itemContent.Children()...)
+		} else {
+			items = append(items, item)
+		}
+	}
+	children = slices.Insert(slices.DeleteFunc(children, isRepeatingSectionItem), idx, items...)
+	for _, child := range children {
+		child.SetParent(content)
+	}
+	content.SetChildren(children)
+	return nil
+}
+
+func isRepeatingSectionItem(node Node) bool {
+	sdt, ok := node.(*NonTextNode)
+	if !ok || sdt.Tag != SDT_TAG {
+		return false
+	}
+	sdtPr := findChild(sdt, "w:sdtPr")
+	return sdtPr != nil && findChild(sdtPr, "w15:repeatingSectionItem") != nil && findChild(sdt, "w:sdtContent") != nil
+}
+
+// getItemData returns the data of a repeating section item: the report data, with the fields of the item
+func getItemData(data ReportData, item VarValue) ReportData {
+	itemData := maps.Clone(data)
+	switch fields := item.(type) {
+	case map[string]any:
+		maps.Copy(itemData, fields)
+	case ReportData:
+		maps.Copy(itemData, fields)
+	}
+	return itemData
+}
+
+func fillCheckbox(checkbox *NonTextNode, content *NonTextNode, checked bool) {
+	state, glyph := "w14:uncheckedState", CHECKBOX_UNCHECKED
+	if checked {
+		state, glyph = "w14:checkedState", CHECKBOX_CHECKED
+	}
+	if stateNode := findChild(checkbox, state); stateNode != nil {
+		if code, err := strconv.ParseInt(stateNode.Attrs["w14:val"], 16, 32); err == nil {
+			glyph = string(rune(code))
+		}
+	}
+	checkedNode := findChild(checkbox, "w14:checked")
+	if checkedNode == nil {
+		checkedNode = NewNonTextNode("w14:checked", map[string]string{}, nil)
+		checkbox.SetChildren(append([]Node{checkedNode}, checkbox.Children()...))
+		checkedNode.SetParent(checkbox)
+	}
+	checkedNode.Attrs["w14:val"] = "0"
+	if checked {
+		checkedNode.Attrs["w14:val"] = "1"
+	}
+	setContentControlText(content, glyph)
+}
+
+// fillDate shows a date with the format of the control (dates given as text are parsed if possible)
+func fillDate(dateNode *NonTextNode, content *NonTextNode, value VarValue) {
+	date, ok := value.(time.Time)
+	if text, isText := value.(string); isText {
+		for _, layout := range []string{time.RFC3339, time.DateTime, time.DateOnly} {
+			if parsed, err := time.Parse(layout, text); err == nil {
+				date, ok = parsed, true
+				break
+			}
+		}
+	}
+	if !ok {
+		setContentControlText(content, formatContentControlText(value))
+		return
+	}
+	format := DEFAULT_FIELD_DATE_FORMAT
+	if dateFormat := findChild(dateNode, "w:dateFormat"); dateFormat != nil && dateFormat.Attrs["w:val"] != "" {
+		format = dateFormat.Attrs["w:val"]
+	}
+	if dateNode.Attrs == nil {
+		dateNode.Attrs = map[string]string{}
+	}
+	dateNode.Attrs["w:fullDate"] = date.UTC().Format("2006-01-02T15:04:05Z")
+	setContentControlText(content, formatFieldDate(date, format))
+}
+
+// getListItemText returns the display text of the item of a dropdown list or combo box with the
+// given value (or display text), or the value if there is none
+func getListItemText(list *NonTextNode, value VarValue) string {
+	text := formatContentControlText(value)
+	for _, item := range findChildren(list, "w:listItem") {
+		if item.Attrs["w:value"] == text || item.Attrs["w:displayText"] == text {
+			if item.Attrs["w:displayText"] != "" {
+				return item.Attrs["w:displayText"]
+			}
+			return item.Attrs["w:value"]
+		}
+	}
+	return text
+}
+
+func formatContentControlText(value VarValue) string {
+	if value == nil {
+		return ""
+	}
+	return fmt.Sprint(value)
+}
+
+// setContentControlText replaces the content of a control with a single run of text, keeping the
+// formatting of its first paragraph and run, without the placeholder style. The tables of block
+// controls are kept, with an empty paragraph per cell
+func setContentControlText(content *NonTextNode, text string) {
+	var container Node = content
+	if paragraphs := findDescendants(content, P_TAG); len(paragraphs) > 0 {
+		container = paragraphs[0]
+		for _, p := range paragraphs[1:] {
+			cell := findAncestor(p, TC_TAG)
+			if cell == nil || findChild(cell, P_TAG) != p {
+				removeNode(p)
+				continue
+			}
+			// A cell needs a paragraph
+			for _, r := range findDescendants(p, R_TAG) {
+				removeNode(r)
+			}
+		}
+	}
+	var run *NonTextNode
+	if runs := findDescendants(container, R_TAG); len(runs) > 0 {
+		run = runs[0]
+		for _, r := range runs[1:] {
+			removeNode(r)
+		}
+	} else {
+		run = NewNonTextNode(R_TAG, nil, nil)
+		AddChild(container, run)
+	}
+
+	children := []Node{}
+	if rPr := findChild(run, RPR_TAG); rPr != nil {
+		if rStyle := findChild(rPr, "w:rStyle"); rStyle != nil && rStyle.Attrs["w:val"] == PLACEHOLDER_TEXT_STYLE {
+			removeNode(rStyle)
+		}
+		children = append(children, rPr)
+	}
+	for i, line := range strings.Split(text, "\n") {
+		if i > 0 {
+			children = append(children, NewNonTextNode("w:br", nil, nil))
+		}
+		children = append(children, NewNonTextNode(T_TAG, map[string]string{"xml:space": "preserve"}, []Node{NewTextNode(line)}))
+	}
+	for _, child := range children {
+		child.SetParent(run)
+	}
+	run.SetChildren(children)
+}
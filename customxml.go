@@ -0,0 +1,299 @@
+package godocx
+
+import (
+	"fmt"
+	"maps"
+	"path"
+	"reflect"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	CUSTOM_XML_RELATION_TYPE       = "http://schemas.openxmlformats.org/officeDocument/2006/relationships/customXml"
+	CUSTOM_XML_PROPS_RELATION_TYPE = "http://schemas.openxmlformats.org/officeDocument/2006/relationships/customXmlProps"
+)
+
+var (
+	prefixMappingRegexp = regexp.MustCompile(`xmlns:(\w+)\s*=\s*['"]([^'"]*)['"]`)
+	xpathStepRegexp     = regexp.MustCompile(`^(@?)(?:(\w+):)?([\w.-]+)(?:\[(\d+)\])?$`)
+)
+
+// CustomXmlParts are the custom XML parts of a report, by store item id
+type CustomXmlParts map[string]*NonTextNode
+
+// PopulateCustomXml writes the data trees of the CustomXml option into the custom XML parts of the
+// report with the same root namespace, and returns these parts (see RefreshDataBindings)
+func PopulateCustomXml(data *ReportData, ctx *Context, documentComponent string, zip *ZipArchive) (CustomXmlParts, error) {
+	parts := CustomXmlParts{}
+	if len(ctx.options.CustomXml) == 0 {
+		return parts, nil
+	}
+	rels, err := getRelsFromZip(zip, fmt.Sprintf("%s/_rels/%s.rels", TEMPLATE_PATH, documentComponent))
+	if err != nil {
+		return nil, err
+	}
+	populated := map[string]bool{}
+	for _, rel := range findChildren(rels, "Relationship") {
+		if rel.Attrs["Type"] != CUSTOM_XML_RELATION_TYPE {
+			continue
+		}
+		partPath := resolveRelTarget(TEMPLATE_PATH, rel.Attrs["Target"])
+		root, err := parsePath(zip, partPath)
+		if err != nil {
+			return nil, err
+		}
+		namespace := getNamespace(root, getPrefix(root.Tag))
+		expression, ok := ctx.options.CustomXml[namespace]
+		if !ok {
+			continue
+		}
+		value, err := runAndGetValue(expression, ctx, data)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", namespace, err)
+		}
+		setCustomXmlValue(root, value)
+		zip.SetFile(partPath, BuildXml(root, XmlOptions{LiteralXmlDelimiter: DEFAULT_LITERAL_XML_DELIMITER}, ""))
+		populated[namespace] = true
+
+		itemId, err := getStoreItemId(partPath, zip)
+		if err != nil {
+			return nil, err
+		}
+		if itemId != "" {
+			parts[itemId] = root
+		}
+	}
+	for _, namespace := range slices.Sorted(maps.Keys(ctx.options.CustomXml)) {
+		if !populated[namespace] {
+			return nil, fmt.Errorf("No custom XML part with namespace %s", namespace)
+		}
+	}
+	return parts, nil
+}
+
+// getStoreItemId returns the id of a custom XML part, from its properties part
+func getStoreItemId(partPath string, zip *ZipArchive) (string, error) {
+	dir, name := path.Split(partPath)
+	relsPath := path.Join(dir, "_rels", name+".rels")
+	if !zip.HasFile(relsPath) {
+		return "", nil
+	}
+	rels, err := getRelsFromZip(zip, relsPath)
+	if err != nil {
+		return "", err
+	}
+	for _, rel := range findChildren(rels, "Relationship") {
+		if rel.Attrs["Type"] != CUSTOM_XML_PROPS_RELATION_TYPE {
+			continue
+		}
+		props, err := parsePath(zip, resolveRelTarget(dir, rel.Attrs["Target"]))
+		if err != nil {
+			return "", err
+		}
+		return normalizeStoreItemId(props.Attrs["ds:itemID"]), nil
+	}
+	return "", nil
+}
+
+func normalizeStoreItemId(id string) string {
+	return strings.ToUpper(strings.Trim(strings.TrimSpace(id), "{}"))
+}
+
+// setCustomXmlValue writes a value into an element: maps give child elements (or attributes, for
+// the keys starting with `@`), arrays repeated elements, and other values text
+func setCustomXmlValue(element *NonTextNode, value VarValue) {
+	var fields map[string]any
+	switch v := value.(type) {
+	case map[string]any:
+		fields = v
+	case ReportData:
+		fields = v
+	default:
+		element.SetChildren(nil)
+		AddChild(element, NewTextNode(formatCustomXmlText(value)))
+		return
+	}
+
+	prefix := ""
+	if elementPrefix := getPrefix(element.Tag); elementPrefix != "" {
+		prefix = elementPrefix + ":"
+	}
+	for _, key := range slices.Sorted(maps.Keys(fields)) {
+		if strings.HasPrefix(key, "@") {
+			if element.Attrs == nil {
+				element.Attrs = map[string]string{}
+			}
+			element.Attrs[key[1:]] = formatCustomXmlText(fields[key])
+			continue
+		}
+		tag := prefix + key
+		values := []VarValue{fields[key]}
+		if reflected := reflect.ValueOf(fields[key]); reflected.Kind() == reflect.Slice {
+			values = make([]VarValue, reflected.Len())
+			for i := range reflected.Len() {
+				values[i] = reflected.Index(i).Interface()
+			}
+		}
+
+		// The elements replace the existing ones, which they are based on
+		existing := findChildren(element, tag)
+		children := slices.Clone(element.Children())
+		idx := len(children)
+		if len(existing) > 0 {
+			idx = slices.Index(children, Node(existing[0]))
+		}
+		children = slices.DeleteFunc(children, func(child Node) bool {
+			nonTextChild, ok := child.(*NonTextNode)
+			return ok && nonTextChild.Tag == tag
+		})
+		newElements := make([]Node, len(values))
+		for i, value := range values {
+			var child *NonTextNode
+			if i < len(existing) {
+				child = existing[i]
+			} else if len(existing) > 0 {
+				child = cloneNode(existing[0]).(*NonTextNode)
+			} else {
+				child = NewNonTextNode(tag, map[string]string{}, nil)
+			}
+			setCustomXmlValue(child, value)
+			newElements[i] = child
+		}
+		children = slices.Insert(children, idx, newElements...)
+		for _, child := range children {
+			child.SetParent(element)
+		}
+		element.SetChildren(children)
+	}
+}
+
+func formatCustomXmlText(value VarValue) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case time.Time:
+		return v.UTC().Format("2006-01-02T15:04:05Z")
+	}
+	return fmt.Sprint(value)
+}
+
+// RefreshDataBindings updates the text of the content controls bound to the custom XML parts
+// (`w:dataBinding`), so that it matches their data
+func RefreshDataBindings(node Node, parts CustomXmlParts) {
+	if len(parts) == 0 {
+		return
+	}
+	for _, sdt := range findDescendants(node, SDT_TAG) {
+		sdtPr, content := findChild(sdt, "w:sdtPr"), findChild(sdt, "w:sdtContent")
+		if sdtPr == nil || content == nil {
+			continue
+		}
+		binding := findChild(sdtPr, "w:dataBinding")
+		if binding == nil {
+			continue
+		}
+		root, ok := parts[normalizeStoreItemId(binding.Attrs["w:storeItemID"])]
+		if !ok {
+			continue
+		}
+		text, ok := evalXpath(root, binding.Attrs["w:xpath"], getPrefixMappings(binding.Attrs["w:prefixMappings"]))
+		if !ok {
+			continue
+		}
+		var value VarValue = text
+		if findChild(sdtPr, "w14:checkbox") != nil {
+			value = text == "true" || text == "1"
+		}
+		setContentControlValue(sdtPr, content, value, nil, false)
+	}
+}
+
+func getPrefixMappings(prefixMappings string) map[string]string {
+	namespaces := map[string]string{}
+	for _, match := range prefixMappingRegexp.FindAllStringSubmatch(prefixMappings, -1) {
+		namespaces[match[1]] = match[2]
+	}
+	return namespaces
+}
+
+// evalXpath returns the text of the node of a simple XPath (e.g. `/ns0:contract[1]/ns0:client[1]/@id`),
+// as used by data bindings
+func evalXpath(root *NonTextNode, xpath string, namespaces map[string]string) (string, bool) {
+	steps := strings.Split(strings.TrimPrefix(strings.TrimSpace(xpath), "/"), "/")
+	current := []*NonTextNode{{BaseNode: BaseNode{ChildNodes: []Node{root}}}}
+	for i, step := range steps {
+		match := xpathStepRegexp.FindStringSubmatch(step)
+		if match == nil {
+			return "", false
+		}
+		isAttribute, prefix, name := match[1] != "", match[2], match[3]
+		if isAttribute {
+			if i != len(steps)-1 || len(current) == 0 {
+				return "", false
+			}
+			for key, value := range current[0].Attrs {
+				if getLocalName(key) == name && (prefix == "" || getNamespace(current[0], getPrefix(key)) == namespaces[prefix]) {
+					return value, true
+				}
+			}
+			return "", false
+		}
+
+		found := []*NonTextNode{}
+		for _, node := range current {
+			for _, child := range node.Children() {
+				element, ok := child.(*NonTextNode)
+				if ok && getLocalName(element.Tag) == name && getNamespace(element, getPrefix(element.Tag)) == namespaces[prefix] {
+					found = append(found, element)
+				}
+			}
+		}
+		if position, err := strconv.Atoi(match[4]); err == nil {
+			if position < 1 || position > len(found) {
+				return "", false
+			}
+			found = found[position-1 : position]
+		}
+		if len(found) == 0 {
+			return "", false
+		}
+		current = found
+	}
+	return getNodeText(current[0]), true
+}
+
+func getPrefix(tag string) string {
+	prefix, _, ok := strings.Cut(tag, ":")
+	if !ok {
+		return ""
+	}
+	return prefix
+}
+
+func getLocalName(tag string) string {
+	_, name, ok := strings.Cut(tag, ":")
+	if !ok {
+		return tag
+	}
+	return name
+}
+
+// getNamespace returns the namespace of a prefix ("" for the default one), as declared by node or its ancestors
+func getNamespace(node Node, prefix string) string {
+	attr := "xmlns"
+	if prefix != "" {
+		attr = "xmlns:" + prefix
+	}
+	for ; node != nil; node = node.Parent() {
+		if element, ok := node.(*NonTextNode); ok {
+			if namespace, ok := element.Attrs[attr]; ok {
+				return namespace
+			}
+		}
+	}
+	return ""
+}
@@ -4,12 +4,9 @@ go 1.24.0
 
 replace github.com/ctengiz/godocx-template => ../../
 
-require (
-	github.com/ctengiz/godocx-template v0.0.0-00010101000000-000000000000
-	github.com/skip2/go-qrcode v0.0.0-20200617195104-da1b6568686e
-)
+require github.com/ctengiz/godocx-template v0.0.0-00010101000000-000000000000
 
 require (
-	github.com/gomarkdown/markdown v0.0.0-20241205020045-f7e15b2f3e62 // indirect
+	golang.org/x/image v0.32.0 // indirect
 	golang.org/x/text v0.30.0 // indirect
 )
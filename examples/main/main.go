@@ -11,7 +11,6 @@ import (
 	_ "embed"
 
 	. "github.com/ctengiz/godocx-template"
-	"github.com/skip2/go-qrcode"
 )
 
 //go:embed dataset.json
@@ -50,21 +49,6 @@ func main() {
 						Extension: ".png",
 					}
 				},
-				"qr": func(args ...any) VarValue {
-					if url, ok := args[0].(string); ok {
-						png, err := qrcode.Encode(url, qrcode.Medium, 256)
-						if err != nil {
-							return "Err"
-						}
-						return &ImagePars{
-							Data:      png,
-							Extension: ".png",
-							Width:     6,
-							Height:    6,
-						}
-					}
-					return ""
-				},
 			},
 		})
 	if err != nil {
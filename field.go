@@ -0,0 +1,315 @@
+package godocx
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	DEFAULT_FIELD_DATE_FORMAT = "M/d/yyyy"
+	DEFAULT_FIELD_TIME_FORMAT = "h:mm am/pm"
+)
+
+var (
+	fieldDateFormatRegexp = regexp.MustCompile(`\\@\s*(?:"([^"]*)"|(\S+))`)
+	fieldFormatRegexp     = regexp.MustCompile(`\\\*\s*(\w+)`)
+	fieldSeqResetRegexp   = regexp.MustCompile(`\\r\s*(\d+)`)
+	fieldDateTokenRegexp  = regexp.MustCompile(`'[^']*'|AM/PM|am/pm|y+|M+|d+|H+|h+|m+|s+`)
+	romanNumerals         = []struct {
+		value  int
+		symbol string
+	}{
+		{1000, "M"}, {900, "CM"}, {500, "D"}, {400, "CD"}, {100, "C"}, {90, "XC"},
+		{50, "L"}, {40, "XL"}, {10, "X"}, {9, "IX"}, {5, "V"}, {4, "IV"}, {1, "I"},
+	}
+)
+
+// processField handles `FIELD <instruction>`: a complex field, with the formatting of the command
+// run, and a cached result computed when possible (SEQ, DATE, TIME, MERGEFIELD, REF)
+func processField(data *ReportData, ctx *Context, rest string) error {
+	instruction, err := interpolate(rest, ctx, data)
+	if err != nil {
+		return err
+	}
+	args := strings.Fields(instruction)
+	if len(args) == 0 {
+		return errors.New("Missing field instruction")
+	}
+	fieldType := strings.ToUpper(args[0])
+	if len(args) < 2 && (fieldType == "SEQ" || fieldType == "MERGEFIELD" || fieldType == "REF") {
+		return fmt.Errorf("Missing argument of %s field", fieldType)
+	}
+
+	var result string
+	switch fieldType {
+	case "SEQ":
+		identifier := args[1]
+		if match := fieldSeqResetRegexp.FindStringSubmatch(instruction); match != nil {
+			ctx.seqCounters[identifier], _ = strconv.Atoi(match[1])
+		} else if !slices.Contains(args, `\c`) {
+			ctx.seqCounters[identifier]++
+		}
+		if !slices.Contains(args, `\h`) {
+			result = formatFieldNumber(ctx.seqCounters[identifier], instruction)
+		}
+	case "DATE", "TIME", "CREATEDATE", "SAVEDATE", "PRINTDATE":
+		format := DEFAULT_FIELD_DATE_FORMAT
+		if fieldType == "TIME" {
+			format = DEFAULT_FIELD_TIME_FORMAT
+		}
+		if match := fieldDateFormatRegexp.FindStringSubmatch(instruction); match != nil {
+			format = match[1] + match[2]
+		}
+		result = formatFieldDate(time.Now(), format)
+	case "PAGE", "NUMPAGES", "SECTIONPAGES", "PAGEREF":
+		result = TOC_PAGE_PLACEHOLDER
+	case "MERGEFIELD":
+		name := strings.Trim(args[1], `"`)
+		value, err := runAndGetValue(name, ctx, data)
+		if err != nil {
+			result = "«" + name + "»"
+		} else {
+			result = formatFieldText(fmt.Sprint(value), instruction)
+		}
+	}
+
+	begin, separate, end := newFieldRuns(" "+instruction+" ", result)
+	if fieldType == "REF" {
+		// The text of the bookmark is known once the whole part has been generated
+		resultText := NewTextNode("")
+		separate = []Node{NewNonTextNode(R_TAG, nil, []Node{NewNonTextNode(T_TAG, map[string]string{"xml:space": "preserve"}, []Node{resultText})})}
+		ctx.pendingRefs[resultText] = toBookmarkName(strings.Trim(args[1], `"`))
+	}
+	runs := slices.Concat(begin, separate, []Node{end})
+	if ctx.textRunPropsNode != nil {
+		for _, run := range runs {
+			rPr := cloneNode(ctx.textRunPropsNode)
+			rPr.SetParent(run)
+			run.SetChildren(slices.Insert(run.Children(), 0, rPr))
+		}
+	}
+	addRunNodes(ctx, runs...)
+	return nil
+}
+
+func newFieldRuns(instruction string, result string) (begin []Node, separate []Node, end Node) {
+	node := NewNonTextNode
+	begin = []Node{
+		node(R_TAG, nil, []Node{node("w:fldChar", map[string]string{"w:fldCharType": "begin"}, nil)}),
+		node(R_TAG, nil, []Node{node("w:instrText", map[string]string{"xml:space": "preserve"}, []Node{NewTextNode(instruction)})}),
+		node(R_TAG, nil, []Node{node("w:fldChar", map[string]string{"w:fldCharType": "separate"}, nil)}),
+	}
+	if result != "" {
+		separate = []Node{node(R_TAG, nil, []Node{node(T_TAG, map[string]string{"xml:space": "preserve"}, []Node{NewTextNode(result)})})}
+	}
+	end = node(R_TAG, nil, []Node{node("w:fldChar", map[string]string{"w:fldCharType": "end"}, nil)})
+	return
+}
+
+// field is a complex field (`w:fldChar` begin/separate/end) of a part
+type field struct {
+	begin       *NonTextNode
+	separate    *NonTextNode
+	end         *NonTextNode
+	instruction string
+}
+
+// findFields returns the complex fields of node, the outer ones after those they contain
+func findFields(node Node) []*field {
+	fields := []*field{}
+	stack := []*field{}
+	var visit func(node Node)
+	visit = func(node Node) {
+		for _, child := range node.Children() {
+			nonTextChild, ok := child.(*NonTextNode)
+			if !ok {
+				continue
+			}
+			switch {
+			case nonTextChild.Tag == "w:fldChar" && nonTextChild.Attrs["w:fldCharType"] == "begin":
+				stack = append(stack, &field{begin: nonTextChild})
+			case len(stack) == 0:
+				visit(nonTextChild)
+			case nonTextChild.Tag == "w:fldChar" && nonTextChild.Attrs["w:fldCharType"] == "separate":
+				stack[len(stack)-1].separate = nonTextChild
+			case nonTextChild.Tag == "w:fldChar" && nonTextChild.Attrs["w:fldCharType"] == "end":
+				current := stack[len(stack)-1]
+				current.end = nonTextChild
+				stack = stack[:len(stack)-1]
+				fields = append(fields, current)
+			case nonTextChild.Tag == "w:instrText":
+				stack[len(stack)-1].instruction += getNodeText(nonTextChild)
+			default:
+				visit(nonTextChild)
+			}
+		}
+	}
+	visit(node)
+	return fields
+}
+
+// fixRefFields sets the result of the REF fields generated by FIELD to the text of their bookmark
+func fixRefFields(out Node, refs map[*TextNode]string) {
+	if len(refs) == 0 {
+		return
+	}
+	texts := getBookmarkTexts(out)
+	for resultText, name := range refs {
+		if text, ok := texts[name]; ok {
+			resultText.Text = text
+			delete(refs, resultText)
+		}
+	}
+}
+
+// getBookmarkTexts returns the text of the bookmarks of node, by name
+func getBookmarkTexts(node Node) map[string]string {
+	texts := map[string]string{}
+	active := map[string]string{} // [id]name
+	var visit func(node Node)
+	visit = func(node Node) {
+		for _, child := range node.Children() {
+			nonTextChild, ok := child.(*NonTextNode)
+			if !ok {
+				continue
+			}
+			switch nonTextChild.Tag {
+			case "w:bookmarkStart":
+				if _, ok := texts[nonTextChild.Attrs["w:name"]]; !ok {
+					texts[nonTextChild.Attrs["w:name"]] = ""
+					active[nonTextChild.Attrs["w:id"]] = nonTextChild.Attrs["w:name"]
+				}
+			case "w:bookmarkEnd":
+				delete(active, nonTextChild.Attrs["w:id"])
+			case T_TAG:
+				text := getNodeText(nonTextChild)
+				for _, name := range active {
+					texts[name] += text
+				}
+			default:
+				visit(nonTextChild)
+			}
+		}
+	}
+	visit(node)
+	return texts
+}
+
+// formatFieldNumber formats a number with the `\* ROMAN`, `\* alphabetic`... switches of a field
+func formatFieldNumber(n int, instruction string) string {
+	for _, match := range fieldFormatRegexp.FindAllStringSubmatch(instruction, -1) {
+		switch match[1] {
+		case "ROMAN", "roman":
+			roman := toRoman(n)
+			if match[1] == "roman" {
+				roman = strings.ToLower(roman)
+			}
+			return roman
+		case "ALPHABETIC", "alphabetic":
+			if n <= 0 {
+				return ""
+			}
+			letter := string(rune('A' + (n-1)%26))
+			if match[1] == "alphabetic" {
+				letter = strings.ToLower(letter)
+			}
+			return strings.Repeat(letter, (n-1)/26+1)
+		}
+	}
+	return strconv.Itoa(n)
+}
+
+func toRoman(n int) string {
+	var roman strings.Builder
+	for _, numeral := range romanNumerals {
+		for n >= numeral.value {
+			roman.WriteString(numeral.symbol)
+			n -= numeral.value
+		}
+	}
+	return roman.String()
+}
+
+// formatFieldText applies the `\* Upper`, `\* Lower`, `\* Caps` and `\* FirstCap` switches of a field
+func formatFieldText(text string, instruction string) string {
+	for _, match := range fieldFormatRegexp.FindAllStringSubmatch(instruction, -1) {
+		switch strings.ToLower(match[1]) {
+		case "upper":
+			return strings.ToUpper(text)
+		case "lower":
+			return strings.ToLower(text)
+		case "caps":
+			words := strings.Fields(text)
+			for i, word := range words {
+				runes := []rune(word)
+				words[i] = strings.ToUpper(string(runes[0])) + string(runes[1:])
+			}
+			return strings.Join(words, " ")
+		case "firstcap":
+			if runes := []rune(text); len(runes) > 0 {
+				return strings.ToUpper(string(runes[0])) + string(runes[1:])
+			}
+		}
+	}
+	return text
+}
+
+// formatFieldDate formats a date with a Word date picture (e.g. `dd/MM/yyyy HH:mm`)
+func formatFieldDate(date time.Time, format string) string {
+	return fieldDateTokenRegexp.ReplaceAllStringFunc(format, func(token string) string {
+		pad := len(token) > 1
+		number := func(n int) string {
+			if pad {
+				return fmt.Sprintf("%02d", n)
+			}
+			return strconv.Itoa(n)
+		}
+		switch token[0] {
+		case '\'':
+			return token[1 : len(token)-1]
+		case 'A':
+			return date.Format("PM")
+		case 'a':
+			return date.Format("pm")
+		case 'y':
+			if len(token) <= 2 {
+				return fmt.Sprintf("%02d", date.Year()%100)
+			}
+			return strconv.Itoa(date.Year())
+		case 'M':
+			switch len(token) {
+			case 1, 2:
+				return number(int(date.Month()))
+			case 3:
+				return date.Month().String()[:3]
+			}
+			return date.Month().String()
+		case 'd':
+			switch len(token) {
+			case 1, 2:
+				return number(date.Day())
+			case 3:
+				return date.Weekday().String()[:3]
+			}
+			return date.Weekday().String()
+		case 'H':
+			return number(date.Hour())
+		case 'h':
+			hour := date.Hour() % 12
+			if hour == 0 {
+				hour = 12
+			}
+			return number(hour)
+		case 'm':
+			return number(date.Minute())
+		case 's':
+			return number(date.Second())
+		}
+		return token
+	})
+}
@@ -2,5 +2,7 @@ module github.com/ctengiz/godocx-template
 
 go 1.24.0
 
-require golang.org/x/text v0.30.0
-
+require (
+	golang.org/x/image v0.32.0
+	golang.org/x/text v0.30.0
+)
@@ -0,0 +1,619 @@
+package godocx
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	"image/png"
+	"log/slog"
+	"math"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	_ "golang.org/x/image/bmp"
+	_ "golang.org/x/image/tiff"
+	_ "golang.org/x/image/webp"
+)
+
+// DEFAULT_IMAGE_DPI is the resolution assumed for images that don't specify one
+const DEFAULT_IMAGE_DPI = 96
+
+type imageInfo struct {
+	width  float64 // pixels
+	height float64 // pixels
+	dpiX   float64
+	dpiY   float64
+}
+
+// widthCm and heightCm give the natural size of the image, at its own resolution
+func (info *imageInfo) widthCm() float64 {
+	return info.width / info.dpiX * 2.54
+}
+
+func (info *imageInfo) heightCm() float64 {
+	return info.height / info.dpiY * 2.54
+}
+
+// readImageInfo reads the pixel size and the resolution of an image from its header, as
+// displayed (i.e. swapped for JPEG images rotated by 90° by their EXIF orientation)
+func readImageInfo(data []byte, extension string) (*imageInfo, error) {
+	var info *imageInfo
+	if extension == ".svg" {
+		var err error
+		info, err = readSvgInfo(data)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		config, format, err := image.DecodeConfig(bytes.NewReader(data))
+		if err != nil {
+			return nil, fmt.Errorf("Cannot read image size: %w", err)
+		}
+		info = &imageInfo{width: float64(config.Width), height: float64(config.Height)}
+		switch format {
+		case "png":
+			info.dpiX, info.dpiY = readPngDpi(data)
+		case "jpeg":
+			info.dpiX, info.dpiY = readJpegDpi(data)
+			if readJpegOrientation(data) >= 5 {
+				// Rotated by 90° when displayed
+				info.width, info.height = info.height, info.width
+				info.dpiX, info.dpiY = info.dpiY, info.dpiX
+			}
+		}
+	}
+	if info.dpiX <= 0 || info.dpiY <= 0 {
+		info.dpiX, info.dpiY = DEFAULT_IMAGE_DPI, DEFAULT_IMAGE_DPI
+	}
+	if info.width <= 0 || info.height <= 0 {
+		return nil, errors.New("Cannot read image size: empty image")
+	}
+	return info, nil
+}
+
+// readPngDpi reads the `pHYs` chunk of a PNG file
+func readPngDpi(data []byte) (float64, float64) {
+	// Skip the signature, then walk the chunks: length (4), type (4), data, crc (4)
+	pos := 8
+	for pos+8 <= len(data) {
+		length := int(binary.BigEndian.Uint32(data[pos : pos+4]))
+		chunkType := string(data[pos+4 : pos+8])
+		if chunkType == "IDAT" || chunkType == "IEND" || pos+8+length > len(data) {
+			break
+		}
+		if chunkType == "pHYs" && length >= 9 {
+			chunk := data[pos+8 : pos+8+length]
+			// Unit 1: pixels per meter
+			if chunk[8] == 1 {
+				return float64(binary.BigEndian.Uint32(chunk[0:4])) * 0.0254,
+					float64(binary.BigEndian.Uint32(chunk[4:8])) * 0.0254
+			}
+			break
+		}
+		pos += 12 + length
+	}
+	return 0, 0
+}
+
+// readJpegDpi reads the density of the JFIF `APP0` segment of a JPEG file
+func readJpegDpi(data []byte) (float64, float64) {
+	pos := 2
+	for pos+4 <= len(data) && data[pos] == 0xFF {
+		marker := data[pos+1]
+		length := int(binary.BigEndian.Uint16(data[pos+2 : pos+4]))
+		// Start of scan: no more metadata
+		if marker == 0xDA || length < 2 || pos+2+length > len(data) {
+			break
+		}
+		segment := data[pos+4 : pos+2+length]
+		if marker == 0xE0 && len(segment) >= 12 && string(segment[0:5]) == "JFIF\x00" {
+			units := segment[7]
+			x := float64(binary.BigEndian.Uint16(segment[8:10]))
+			y := float64(binary.BigEndian.Uint16(segment[10:12]))
+			switch units {
+			case 1: // dots per inch
+				return x, y
+			case 2: // dots per cm
+				return x * 2.54, y * 2.54
+			}
+			break
+		}
+		pos += 2 + length
+	}
+	return 0, 0
+}
+
+var svgLengthRegexp = regexp.MustCompile(`^\s*([0-9.]+)\s*([a-z%]*)\s*$`)
+
+// svgLengthToPx converts an SVG length to CSS pixels (96 dpi)
+func svgLengthToPx(length string) (float64, bool) {
+	match := svgLengthRegexp.FindStringSubmatch(strings.ToLower(length))
+	if match == nil {
+		return 0, false
+	}
+	value, err := strconv.ParseFloat(match[1], 64)
+	if err != nil {
+		return 0, false
+	}
+	switch match[2] {
+	case "", "px":
+		return value, true
+	case "pt":
+		return value * 96 / 72, true
+	case "pc":
+		return value * 16, true
+	case "in":
+		return value * 96, true
+	case "cm":
+		return value * 96 / 2.54, true
+	case "mm":
+		return value * 96 / 25.4, true
+	}
+	return 0, false
+}
+
+// readSvgInfo reads the size of an SVG image from the `width`/`height` attributes
+// of its root element, or from its `viewBox`
+func readSvgInfo(data []byte) (*imageInfo, error) {
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			return nil, fmt.Errorf("Cannot read SVG size: %w", err)
+		}
+		start, ok := token.(xml.StartElement)
+		if !ok {
+			continue
+		}
+		if start.Name.Local != "svg" {
+			return nil, errors.New("Cannot read SVG size: root element is not <svg>")
+		}
+		info := &imageInfo{dpiX: DEFAULT_IMAGE_DPI, dpiY: DEFAULT_IMAGE_DPI}
+		var viewBox []float64
+		for _, attr := range start.Attr {
+			switch attr.Name.Local {
+			case "width":
+				info.width, _ = svgLengthToPx(attr.Value)
+			case "height":
+				info.height, _ = svgLengthToPx(attr.Value)
+			case "viewBox":
+				for _, field := range strings.FieldsFunc(attr.Value, func(r rune) bool { return r == ',' || r == ' ' }) {
+					value, err := strconv.ParseFloat(field, 64)
+					if err == nil {
+						viewBox = append(viewBox, value)
+					}
+				}
+			}
+		}
+		if len(viewBox) == 4 && viewBox[2] > 0 && viewBox[3] > 0 {
+			if info.width <= 0 && info.height <= 0 {
+				info.width, info.height = viewBox[2], viewBox[3]
+			} else if info.width <= 0 {
+				info.width = info.height * viewBox[2] / viewBox[3]
+			} else if info.height <= 0 {
+				info.height = info.width * viewBox[3] / viewBox[2]
+			}
+		}
+		return info, nil
+	}
+}
+
+// sniffImageFormat detects the format of an image from its content, and returns
+// its canonical extension (or "" when unknown)
+func sniffImageFormat(data []byte) string {
+	switch {
+	case bytes.HasPrefix(data, []byte("\x89PNG\r\n\x1a\n")):
+		return ".png"
+	case bytes.HasPrefix(data, []byte("\xff\xd8\xff")):
+		return ".jpg"
+	case bytes.HasPrefix(data, []byte("GIF87a")), bytes.HasPrefix(data, []byte("GIF89a")):
+		return ".gif"
+	case bytes.HasPrefix(data, []byte("BM")):
+		return ".bmp"
+	case bytes.HasPrefix(data, []byte("II*\x00")), bytes.HasPrefix(data, []byte("MM\x00*")):
+		return ".tiff"
+	case len(data) >= 12 && bytes.HasPrefix(data, []byte("RIFF")) && string(data[8:12]) == "WEBP":
+		return ".webp"
+	}
+	head := bytes.TrimLeft(bytes.TrimPrefix(data, []byte("\xef\xbb\xbf")), " \t\r\n")
+	if bytes.HasPrefix(head, []byte("<")) && bytes.Contains(head[:min(len(head), 4096)], []byte("<svg")) {
+		return ".svg"
+	}
+	return ""
+}
+
+func canonicalExtension(extension string) string {
+	switch extension {
+	case ".jpeg":
+		return ".jpg"
+	case ".tif":
+		return ".tiff"
+	}
+	return extension
+}
+
+func imageHash(img *Image) string {
+	hash := sha256.New()
+	hash.Write([]byte(img.Extension))
+	hash.Write(img.Data)
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// prepareImage checks that the data of an image (and of its thumbnail) matches its
+// extension, converts the formats that Word doesn't support to PNG, renders a
+// PNG fallback for SVG images without a thumbnail, and compresses the image.
+// The given ImagePars are left untouched: a prepared copy is returned.
+func prepareImage(ctx *Context, pars *ImagePars) (*ImagePars, error) {
+	if err := validateImagePars(pars); err != nil {
+		return nil, err
+	}
+	img, err := prepareImageData(ctx, &Image{Extension: pars.Extension, Data: pars.Data})
+	if err != nil {
+		return nil, err
+	}
+	prepared := *pars
+	prepared.Extension, prepared.Data = img.Extension, img.Data
+	if pars.Thumbnail != nil {
+		thumbnail, err := prepareImageData(ctx, &pars.Thumbnail.Image)
+		if err != nil {
+			return nil, fmt.Errorf("Thumbnail: %w", err)
+		}
+		if thumbnail.Extension == ".svg" {
+			return nil, errors.New("Thumbnail: an SVG image cannot be used as a thumbnail")
+		}
+		prepared.Thumbnail = &Thumbnail{Image: *thumbnail, Width: pars.Thumbnail.Width, Height: pars.Thumbnail.Height}
+	} else if prepared.Extension == ".svg" {
+		prepared.Thumbnail = &Thumbnail{Image: *getSvgFallback(ctx, img)}
+	}
+	if err := compressImage(ctx, &prepared); err != nil {
+		return nil, err
+	}
+	return &prepared, nil
+}
+
+func prepareImageData(ctx *Context, img *Image) (*Image, error) {
+	if err := validateExtension(img.Extension); err != nil {
+		return nil, err
+	}
+	format := sniffImageFormat(img.Data)
+	if format == "" {
+		return nil, fmt.Errorf("Unrecognized image data (expected a %s image)", img.Extension)
+	}
+	if format != canonicalExtension(img.Extension) {
+		return nil, fmt.Errorf("The image data is a %s image, but its extension is %s", format, img.Extension)
+	}
+	if !slices.Contains(ConvertedImageExtensions, format) {
+		return img, nil
+	}
+	key := imageHash(img)
+	if converted, ok := ctx.convertedImages[key]; ok {
+		return converted, nil
+	}
+	decoded, _, err := image.Decode(bytes.NewReader(img.Data))
+	if err != nil {
+		return nil, fmt.Errorf("Cannot convert %s image: %w", format, err)
+	}
+	out := new(bytes.Buffer)
+	if err := png.Encode(out, decoded); err != nil {
+		return nil, fmt.Errorf("Cannot convert %s image: %w", format, err)
+	}
+	converted := &Image{Extension: ".png", Data: out.Bytes()}
+	ctx.convertedImages[key] = converted
+	return converted, nil
+}
+
+// getSvgFallback renders the PNG version of an SVG image.
+// The fallback is required: without it the SVG won't render (even in newer versions of
+// Word that don't use it). When the SVG cannot be rendered, a blank image is used instead.
+func getSvgFallback(ctx *Context, svg *Image) *Image {
+	key := imageHash(svg)
+	if fallback, ok := ctx.convertedImages[key]; ok {
+		return fallback
+	}
+	data, err := renderSvgToPng(svg.Data, MAX_SVG_FALLBACK_SIZE)
+	if err != nil {
+		slog.Warn(fmt.Sprintf("Cannot render SVG fallback, using a blank image: %v", err))
+		out := new(bytes.Buffer)
+		png.Encode(out, image.NewNRGBA(image.Rect(0, 0, 1, 1)))
+		data = out.Bytes()
+	}
+	fallback := &Image{Extension: ".png", Data: data}
+	ctx.convertedImages[key] = fallback
+	return fallback
+}
+
+// getImageSize computes the size of the image on the page (in cm) from the ImagePars:
+//   - Width and Height: used as is
+//   - only Width or only Height: the other one follows the aspect ratio of the image
+//   - none of them: natural size of the image, given its resolution (or Dpi)
+//
+// The result is then scaled down to fit in MaxWidth x MaxHeight, if given.
+func getImageSize(pars *ImagePars) (float64, float64, error) {
+	if pars.Width > 0 && pars.Height > 0 {
+		return fitInBox(float64(pars.Width), float64(pars.Height), float64(pars.MaxWidth), float64(pars.MaxHeight))
+	}
+	info, err := readImageInfo(pars.Data, pars.Extension)
+	if err != nil {
+		return 0, 0, err
+	}
+	if pars.Dpi > 0 {
+		info.dpiX, info.dpiY = float64(pars.Dpi), float64(pars.Dpi)
+	}
+	return getImageSizeFromInfo(pars, info)
+}
+
+func getImageSizeFromInfo(pars *ImagePars, info *imageInfo) (float64, float64, error) {
+	width, height := float64(pars.Width), float64(pars.Height)
+	if width <= 0 || height <= 0 {
+		naturalWidth, naturalHeight := info.widthCm(), info.heightCm()
+		if width > 0 {
+			height = width * naturalHeight / naturalWidth
+		} else if height > 0 {
+			width = height * naturalWidth / naturalHeight
+		} else {
+			width, height = naturalWidth, naturalHeight
+		}
+	}
+	return fitInBox(width, height, float64(pars.MaxWidth), float64(pars.MaxHeight))
+}
+
+// fitInBox scales width x height down, keeping the aspect ratio, so that it fits in the box
+// (a zero box dimension is unconstrained)
+func fitInBox(width, height, maxWidth, maxHeight float64) (float64, float64, error) {
+	if width <= 0 || height <= 0 {
+		return 0, 0, errors.New("Invalid image size")
+	}
+	scale := 1.0
+	if maxWidth > 0 && width*scale > maxWidth {
+		scale = maxWidth / width
+	}
+	if maxHeight > 0 && height*scale > maxHeight {
+		scale = maxHeight / height
+	}
+	return width * scale, height * scale, nil
+}
+
+// Placeholder picture of the template, whose content is to be replaced
+type placeholderImage struct {
+	pars      *ImagePars
+	blipRelId string
+	svgRelId  string
+}
+
+// getAltTextCommand extracts the command of an alt text, e.g. `+++IMAGE logo+++`
+func getAltTextCommand(descr string, ctx *Context) (cmdName string, rest string, err error) {
+	delimiters := ctx.options.CmdDelimiter
+	start := strings.Index(descr, delimiters.Open)
+	if start < 0 {
+		return "", "", nil
+	}
+	cmdText := descr[start+len(delimiters.Open):]
+	end := strings.Index(cmdText, delimiters.Close)
+	if end < 0 || strings.TrimSpace(cmdText[:end]) == "" {
+		return "", "", nil
+	}
+	cmd, err := getCommand(cmdText[:end], ctx.shorthands, ctx.options.FixSmartQuotes)
+	if err != nil {
+		return "", "", err
+	}
+	cmdName, rest = splitCommand(cmd)
+	return cmdName, rest, nil
+}
+
+// processPlaceholderImage binds a picture of the template to an image, when its alt
+// text (`descr` of its `wp:docPr`) is an IMAGE command (or just an expression).
+// The picture itself is updated by applyPlaceholderImage, once fully generated.
+func processPlaceholderImage(data *ReportData, docPr *NonTextNode, ctx *Context) error {
+	cmdName, rest, err := getAltTextCommand(docPr.Attrs["descr"], ctx)
+	if err != nil {
+		return err
+	}
+	if cmdName != "IMAGE" && cmdName != "INS" {
+		return nil
+	}
+	varValue, err := runAndGetValue(rest, ctx, data)
+	if err != nil {
+		return err
+	}
+	imagePars, ok := varValue.(*ImagePars)
+	if !ok {
+		return errors.New("Not an image as result of " + rest)
+	}
+	imagePars, err = prepareImage(ctx, imagePars)
+	if err != nil {
+		return fmt.Errorf("ImageError: %w", err)
+	}
+
+	placeholder := &placeholderImage{pars: imagePars}
+	placeholder.blipRelId = imageToContext(ctx, getImageData(imagePars))
+	if imagePars.Extension == ".svg" {
+		// For SVG the thumb is placed where the image normally goes.
+		placeholder.svgRelId = placeholder.blipRelId
+		placeholder.blipRelId = imageToContext(ctx, &imagePars.Thumbnail.Image)
+	}
+	docPr.Attrs["descr"] = imagePars.Alt
+	ctx.pendingPlaceholderImage = placeholder
+	return nil
+}
+
+// applyPlaceholderImage swaps the picture of a generated `wp:inline` / `wp:anchor`,
+// keeping everything else (position, wrapping, cropping, borders, effects...).
+// Unless the ImagePars specify a size, the image is fitted in the placeholder
+// (or stretched to fill it, with Stretch).
+func applyPlaceholderImage(drawing *NonTextNode, placeholder *placeholderImage) {
+	pars := placeholder.pars
+	extent := findChild(drawing, "wp:extent")
+	boxWidth := float64(getIntAttr(extent, "cx")) / EMU_PER_CM
+	boxHeight := float64(getIntAttr(extent, "cy")) / EMU_PER_CM
+
+	width, height := boxWidth, boxHeight
+	if pars.Width > 0 || pars.Height > 0 {
+		if w, h, err := getImageSize(pars); err == nil {
+			width, height = w, h
+		}
+	} else if !pars.Stretch && boxWidth > 0 && boxHeight > 0 {
+		if info, err := readImageInfo(pars.Data, pars.Extension); err == nil {
+			scale := min(boxWidth/info.widthCm(), boxHeight/info.heightCm())
+			width, height = info.widthCm()*scale, info.heightCm()*scale
+		}
+	}
+	cx := fmt.Sprint(int(math.Round(width * EMU_PER_CM)))
+	cy := fmt.Sprint(int(math.Round(height * EMU_PER_CM)))
+
+	if extent != nil {
+		extent.Attrs["cx"], extent.Attrs["cy"] = cx, cy
+	}
+	for _, pic := range findDescendants(drawing, "pic:pic") {
+		for _, xfrm := range findDescendants(pic, "a:xfrm") {
+			if ext := findChild(xfrm, "a:ext"); ext != nil {
+				ext.Attrs["cx"], ext.Attrs["cy"] = cx, cy
+			}
+		}
+		for _, cNvPr := range findDescendants(pic, "pic:cNvPr") {
+			cNvPr.Attrs["descr"] = pars.Alt
+		}
+		blips := findDescendants(pic, BLIP_TAG)
+		if len(blips) == 0 {
+			continue
+		}
+		blip := blips[0]
+		blip.Attrs["r:embed"] = placeholder.blipRelId
+		delete(blip.Attrs, "r:link")
+
+		extLst := findChild(blip, "a:extLst")
+		if extLst != nil {
+			extLst.SetChildren(slices.DeleteFunc(extLst.Children(), func(n Node) bool {
+				ext, ok := n.(*NonTextNode)
+				return ok && ext.Attrs["uri"] == SVG_BLIP_EXT_URI
+			}))
+		}
+		if placeholder.svgRelId != "" {
+			if extLst == nil {
+				extLst = NewNonTextNode("a:extLst", map[string]string{}, nil)
+				AddChild(blip, extLst)
+			}
+			AddChild(extLst, newSvgBlipExt(placeholder.svgRelId))
+		}
+	}
+}
+
+var (
+	anchorRelativeFromH = []string{"page", "margin", "column", "character", "leftMargin", "rightMargin", "insideMargin", "outsideMargin"}
+	anchorRelativeFromV = []string{"page", "margin", "paragraph", "line", "topMargin", "bottomMargin", "insideMargin", "outsideMargin"}
+	anchorAlignH        = []string{"left", "center", "right", "inside", "outside"}
+	anchorAlignV        = []string{"top", "center", "bottom", "inside", "outside"}
+)
+
+// ANCHOR_WRAP_DISTANCE is the distance between a wrapped image and the text (0.32cm, Word's default)
+const ANCHOR_WRAP_DISTANCE = "114300"
+
+func newAnchorPosition(tag string, relativeFrom string, allowedRelativeFrom []string, align string, allowedAlign []string, offset float32) (*NonTextNode, error) {
+	if !slices.Contains(allowedRelativeFrom, relativeFrom) {
+		return nil, fmt.Errorf("Invalid anchor position (one of %v): %s", allowedRelativeFrom, relativeFrom)
+	}
+	var position *NonTextNode
+	if align != "" {
+		if !slices.Contains(allowedAlign, align) {
+			return nil, fmt.Errorf("Invalid anchor alignment (one of %v): %s", allowedAlign, align)
+		}
+		position = NewNonTextNode("wp:align", map[string]string{}, []Node{NewTextNode(align)})
+	} else {
+		position = NewNonTextNode("wp:posOffset", map[string]string{}, []Node{
+			NewTextNode(fmt.Sprint(int(math.Round(float64(offset) * EMU_PER_CM)))),
+		})
+	}
+	return NewNonTextNode(tag, map[string]string{"relativeFrom": relativeFrom}, []Node{position}), nil
+}
+
+func newWrapNode(wrap string) (*NonTextNode, error) {
+	node := NewNonTextNode
+	switch wrap {
+	case "", "none":
+		return node("wp:wrapNone", map[string]string{}, nil), nil
+	case "square":
+		return node("wp:wrapSquare", map[string]string{"wrapText": "bothSides"}, nil), nil
+	case "topAndBottom":
+		return node("wp:wrapTopAndBottom", map[string]string{}, nil), nil
+	case "tight":
+		// The wrap polygon is mandatory: use the bounds of the image (21600 = full size)
+		point := func(tag string, x string, y string) Node {
+			return node(tag, map[string]string{"x": x, "y": y}, nil)
+		}
+		return node("wp:wrapTight", map[string]string{"wrapText": "bothSides"}, []Node{
+			node("wp:wrapPolygon", map[string]string{"edited": "0"}, []Node{
+				point("wp:start", "0", "0"),
+				point("wp:lineTo", "0", "21600"),
+				point("wp:lineTo", "21600", "21600"),
+				point("wp:lineTo", "21600", "0"),
+				point("wp:lineTo", "0", "0"),
+			}),
+		}), nil
+	}
+	return nil, fmt.Errorf("Invalid wrap mode (one of [square tight topAndBottom none]): %s", wrap)
+}
+
+// newAnchorNode builds a floating `wp:anchor` drawing
+func newAnchorNode(anchor *ImageAnchor, id int, extent, docPr, graphicFramePr, graphic Node) (*NonTextNode, error) {
+	relativeFromH := anchor.RelativeFromH
+	if relativeFromH == "" {
+		relativeFromH = "column"
+	}
+	relativeFromV := anchor.RelativeFromV
+	if relativeFromV == "" {
+		relativeFromV = "paragraph"
+	}
+	positionH, err := newAnchorPosition("wp:positionH", relativeFromH, anchorRelativeFromH, anchor.AlignH, anchorAlignH, anchor.OffsetX)
+	if err != nil {
+		return nil, err
+	}
+	positionV, err := newAnchorPosition("wp:positionV", relativeFromV, anchorRelativeFromV, anchor.AlignV, anchorAlignV, anchor.OffsetY)
+	if err != nil {
+		return nil, err
+	}
+	wrap, err := newWrapNode(anchor.Wrap)
+	if err != nil {
+		return nil, err
+	}
+	distance := "0"
+	if wrap.Tag != "wp:wrapNone" {
+		distance = ANCHOR_WRAP_DISTANCE
+	}
+	return NewNonTextNode(ANCHOR_TAG, map[string]string{
+		"distT":          "0",
+		"distB":          "0",
+		"distL":          distance,
+		"distR":          distance,
+		"simplePos":      "0",
+		"relativeHeight": fmt.Sprint(251658240 + id),
+		"behindDoc":      boolToAttr(anchor.BehindDoc),
+		"locked":         "0",
+		"layoutInCell":   "1",
+		"allowOverlap":   "1",
+	}, []Node{
+		NewNonTextNode("wp:simplePos", map[string]string{"x": "0", "y": "0"}, nil),
+		positionH,
+		positionV,
+		extent,
+		NewNonTextNode("wp:effectExtent", map[string]string{"l": "0", "t": "0", "r": "0", "b": "0"}, nil),
+		wrap,
+		docPr,
+		graphicFramePr,
+		graphic,
+	}), nil
+}
+
+func boolToAttr(value bool) string {
+	if value {
+		return "1"
+	}
+	return "0"
+}
@@ -0,0 +1,590 @@
+package godocx
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"maps"
+	"path"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+const (
+	NUMBERING_CONTENT_TYPE  = "application/vnd.openxmlformats-officedocument.wordprocessingml.numbering+xml"
+	NUMBERING_RELATION_TYPE = "http://schemas.openxmlformats.org/officeDocument/2006/relationships/numbering"
+	STYLES_RELATION_TYPE    = "http://schemas.openxmlformats.org/officeDocument/2006/relationships/styles"
+	IMAGE_RELATION_TYPE     = "http://schemas.openxmlformats.org/officeDocument/2006/relationships/image"
+	FOOTNOTES_CONTENT_TYPE  = "application/vnd.openxmlformats-officedocument.wordprocessingml.footnotes+xml"
+	FOOTNOTES_RELATION_TYPE = "http://schemas.openxmlformats.org/officeDocument/2006/relationships/footnotes"
+	ENDNOTES_CONTENT_TYPE   = "application/vnd.openxmlformats-officedocument.wordprocessingml.endnotes+xml"
+	ENDNOTES_RELATION_TYPE  = "http://schemas.openxmlformats.org/officeDocument/2006/relationships/endnotes"
+)
+
+// notesPart describes a part of notes (footnotes, endnotes or comments) referenced by id from
+// the document
+type notesPart struct {
+	relationType string
+	contentType  string
+	target       string
+	tag          string
+	noteTag      string
+	refTags      []string
+}
+
+var notesParts = []notesPart{
+	{FOOTNOTES_RELATION_TYPE, FOOTNOTES_CONTENT_TYPE, "footnotes.xml", "w:footnotes", "w:footnote", []string{"w:footnoteReference"}},
+	{ENDNOTES_RELATION_TYPE, ENDNOTES_CONTENT_TYPE, "endnotes.xml", "w:endnotes", "w:endnote", []string{"w:endnoteReference"}},
+	{COMMENTS_RELATION_TYPE, COMMENTS_CONTENT_TYPE, "comments.xml", "w:comments", "w:comment", []string{"w:commentRangeStart", "w:commentRangeEnd", "w:commentReference"}},
+}
+
+// Include is a template rendered by the INCLUDE command: its body content, spliced in the
+// report, and its package, from which styles, numbering definitions and parts are merged
+type Include struct {
+	name      string
+	template  *ParseTemplateResult
+	nodes     []Node
+	copied    map[string]string // [path in the included package]path in the report
+	revisions *Revisions        // generated comments, numbered after the includes are merged
+}
+type Includes []*Include
+
+// processInclude renders an included template with the current data scope, and keeps its
+// body content to replace the paragraph of the INCLUDE command
+func processInclude(data *ReportData, name string, ctx *Context) error {
+	if ctx.options.IncludeLoader == nil {
+		return errors.New("No IncludeLoader in the options, cannot load " + name)
+	}
+	if slices.Contains(ctx.includeStack, name) {
+		return fmt.Errorf("Recursive inclusion of %s", name)
+	}
+	templateData, ok := ctx.includeData[name]
+	if !ok {
+		var err error
+		templateData, err = ctx.options.IncludeLoader(name)
+		if err != nil {
+			return fmt.Errorf("Cannot load %s: %w", name, err)
+		}
+		ctx.includeData[name] = templateData
+	}
+	zip, err := NewZipArchiveFromBytes(templateData, io.Discard)
+	if err != nil {
+		return fmt.Errorf("Cannot open %s: %w", name, err)
+	}
+	parseResult, err := ParseTemplate(zip)
+	if err != nil {
+		return fmt.Errorf("ParseTemplate failed for %s: %w", name, err)
+	}
+	if ctx.options.CleanTemplate {
+		CleanTemplate(parseResult.Root)
+	}
+	preppedTemplate, err := PreprocessTemplate(parseResult.Root, *ctx.options.CmdDelimiter)
+	if err != nil {
+		return fmt.Errorf("PreprocessTemplate failed for %s: %w", name, err)
+	}
+
+	// The included template shares the data scope of the command, and the generated
+	// images, links, etc. (and their ids) of the report
+	subCtx := NewContext(ctx.options, ctx.imageAndShapeIdIncrement)
+	subCtx.vars = maps.Clone(ctx.vars)
+	subCtx.shorthands = maps.Clone(ctx.shorthands)
+	subCtx.images, subCtx.links, subCtx.htmls, subCtx.charts = ctx.images, ctx.links, ctx.htmls, ctx.charts
+	subCtx.linkId, subCtx.htmlId, subCtx.chartId = ctx.linkId, ctx.htmlId, ctx.chartId
+	subCtx.convertedImages = ctx.convertedImages
+	subCtx.includes, subCtx.includeData = ctx.includes, ctx.includeData
+	subCtx.sectionBreaks, subCtx.seqCounters, subCtx.pendingRefs = ctx.sectionBreaks, ctx.seqCounters, ctx.pendingRefs
+	subCtx.numbering, subCtx.revisions = ctx.numbering, ctx.revisions
+	subCtx.includeStack = append(slices.Clone(ctx.includeStack), name)
+	result, err := walkTemplate(data, preppedTemplate, &subCtx, processCmd)
+	ctx.imageAndShapeIdIncrement = subCtx.imageAndShapeIdIncrement
+	ctx.linkId, ctx.htmlId, ctx.chartId = subCtx.linkId, subCtx.htmlId, subCtx.chartId
+	ctx.includes = subCtx.includes
+	if err != nil {
+		return fmt.Errorf("%s: %w", name, err)
+	}
+	// Template charts bound to data are updated in the included package, before being copied
+	err = UpdateCharts(result.ChartUpdates, parseResult.MainDocument, zip)
+	if err != nil {
+		return fmt.Errorf("%s: %w", name, err)
+	}
+
+	body := findChild(result.Report, "w:body")
+	if body == nil {
+		return fmt.Errorf("No body in %s", name)
+	}
+	// The section properties of the included template are not kept: its content
+	// follows the layout of the report
+	nodes := slices.DeleteFunc(slices.Clone(body.Children()), func(node Node) bool {
+		nonTextNode, ok := node.(*NonTextNode)
+		return ok && nonTextNode.Tag == "w:sectPr"
+	})
+	ctx.includes = append(ctx.includes, &Include{name: name, template: parseResult, nodes: nodes, copied: map[string]string{}, revisions: ctx.revisions})
+	ctx.pendingIncludeNodes = nodes
+	return nil
+}
+
+// ProcessIncludes merges the packages of the included templates into the report: relationships
+// and the parts they target, styles, numbering definitions, footnotes, endnotes and comments,
+// renumbering the ids that collide.
+// It must run before the images are processed, as the included images are added to them.
+func ProcessIncludes(includes Includes, report Node, images Images, documentComponent string, zip *ZipArchive, contentTypes *NonTextNode) error {
+	slog.Debug("Processing includes for " + documentComponent + "...")
+	if len(includes) == 0 {
+		return nil
+	}
+	relsPath := fmt.Sprintf("%s/_rels/%s.rels", TEMPLATE_PATH, documentComponent)
+	rels, err := getRelsFromZip(zip, relsPath)
+	if err != nil {
+		return err
+	}
+
+	// The content of nested includes is processed with their own package. Nested includes
+	// are rendered (and listed) first, so that their nodes belong to the innermost include.
+	owners := map[Node]*Include{}
+	for _, include := range includes {
+		for _, node := range include.nodes {
+			if _, ok := owners[node]; !ok {
+				owners[node] = include
+			}
+		}
+	}
+	xmlOptions := XmlOptions{LiteralXmlDelimiter: DEFAULT_LITERAL_XML_DELIMITER}
+	for i, include := range includes {
+		includeNodes := []*NonTextNode{}
+		for _, node := range include.nodes {
+			if owners[node] == include {
+				includeNodes = append(includeNodes, findIncludeDescendants(node, owners)...)
+			}
+		}
+		if includeRoot, ok := include.template.Root.(*NonTextNode); ok {
+			mergeNamespaces(report.(*NonTextNode), includeRoot)
+		}
+
+		numIds, err := mergeNumbering(include, rels, zip, contentTypes)
+		if err != nil {
+			return fmt.Errorf("%s: %w", include.name, err)
+		}
+		err = mergeStyles(include, rels, zip, numIds)
+		if err != nil {
+			return fmt.Errorf("%s: %w", include.name, err)
+		}
+		noteIds := map[string]map[string]string{} // [reference tag][id in the included document]id in the report
+		for _, kind := range notesParts {
+			ids, err := mergeNotes(include, kind, rels, zip, contentTypes)
+			if err != nil {
+				return fmt.Errorf("%s: %w", include.name, err)
+			}
+			for _, refTag := range kind.refTags {
+				noteIds[refTag] = ids
+			}
+		}
+		// The comments generated in the included template are numbered later
+		generated := map[*NonTextNode]bool{}
+		if include.revisions != nil {
+			for _, c := range include.revisions.comments {
+				for _, node := range c.nodes {
+					generated[node] = true
+				}
+			}
+		}
+
+		includeRels, err := getRelsFromZip(include.template.Zip, fmt.Sprintf("%s/_rels/%s.rels", TEMPLATE_PATH, include.template.MainDocument))
+		if err != nil {
+			return fmt.Errorf("%s: %w", include.name, err)
+		}
+		relsById := map[string]*NonTextNode{}
+		for _, rel := range findChildren(includeRels, "Relationship") {
+			relsById[rel.Attrs["Id"]] = rel
+		}
+		relIds := map[string]string{}
+		for _, node := range includeNodes {
+			for attr, value := range node.Attrs {
+				rel, ok := relsById[value]
+				if !strings.HasPrefix(attr, "r:") || !ok {
+					continue
+				}
+				if _, ok := relIds[value]; !ok {
+					relIds[value], err = copyRelationship(include, i+1, rel, rels, images, zip, contentTypes)
+					if err != nil {
+						return fmt.Errorf("%s: %w", include.name, err)
+					}
+				}
+				node.Attrs[attr] = relIds[value]
+			}
+			if node.Tag == "w:numId" {
+				if numId, ok := numIds[node.Attrs["w:val"]]; ok {
+					node.Attrs["w:val"] = numId
+				}
+			}
+			if noteId, ok := noteIds[node.Tag][node.Attrs["w:id"]]; ok && !generated[node] {
+				node.Attrs["w:id"] = noteId
+			}
+		}
+	}
+	// The included bookmarks may have the ids (or names) of the report ones
+	fixBookmarks(report)
+	zip.SetFile(relsPath, BuildXml(rels, xmlOptions, ""))
+	return nil
+}
+
+// findIncludeDescendants returns node and its descendants, except the content of nested includes
+func findIncludeDescendants(node Node, owners map[Node]*Include) []*NonTextNode {
+	nonTextNode, ok := node.(*NonTextNode)
+	if !ok {
+		return nil
+	}
+	found := []*NonTextNode{nonTextNode}
+	for _, child := range node.Children() {
+		if _, nested := owners[child]; !nested {
+			found = append(found, findIncludeDescendants(child, owners)...)
+		}
+	}
+	return found
+}
+
+// mergeNamespaces declares in dst the namespaces of src it lacks
+func mergeNamespaces(dst *NonTextNode, src *NonTextNode) {
+	for attr, value := range src.Attrs {
+		if _, ok := dst.Attrs[attr]; !ok && strings.HasPrefix(attr, "xmlns:") {
+			dst.Attrs[attr] = value
+		}
+	}
+}
+
+// uniqueRelId returns relId, with a suffix if the relationships already have it
+func uniqueRelId(rels Node, relId string) string {
+	newRelId := relId
+	for n := 1; hasRelationship(rels, newRelId); n++ {
+		newRelId = fmt.Sprintf("%s_%d", relId, n)
+	}
+	return newRelId
+}
+
+func hasRelationship(rels Node, relId string) bool {
+	return slices.ContainsFunc(findChildren(rels, "Relationship"), func(rel *NonTextNode) bool {
+		return rel.Attrs["Id"] == relId
+	})
+}
+
+// findRelTarget returns the path of the part targeted by the first relationship of a type
+func findRelTarget(rels Node, relType string) string {
+	for _, rel := range findChildren(rels, "Relationship") {
+		if rel.Attrs["Type"] == relType && rel.Attrs["TargetMode"] != "External" {
+			return resolveRelTarget(TEMPLATE_PATH, rel.Attrs["Target"])
+		}
+	}
+	return ""
+}
+
+// copyRelationship adds a relationship of the included document to the report, copying its
+// target part. Images are added to the images of the report, to be deduplicated with them.
+func copyRelationship(include *Include, includeIdx int, rel *NonTextNode, rels Node, images Images, zip *ZipArchive, contentTypes *NonTextNode) (string, error) {
+	relId := uniqueRelId(rels, fmt.Sprintf("include%d_%s", includeIdx, rel.Attrs["Id"]))
+	newRel := CloneNodeWithoutChildren(rel).(*NonTextNode)
+	newRel.Attrs = maps.Clone(rel.Attrs)
+	newRel.Attrs["Id"] = relId
+	if rel.Attrs["TargetMode"] != "External" {
+		partPath := resolveRelTarget(TEMPLATE_PATH, rel.Attrs["Target"])
+		if rel.Attrs["Type"] == IMAGE_RELATION_TYPE {
+			data, err := include.template.Zip.GetFile(partPath)
+			if err != nil {
+				return "", err
+			}
+			img := &Image{Extension: strings.ToLower(path.Ext(partPath)), Data: data}
+			imageId := "img" + imageHash(img)[:20]
+			images[imageId] = img
+			copyContentType(include, partPath, "", contentTypes)
+			return imageId, nil
+		}
+		newPath, err := copyPart(include, partPath, zip, contentTypes)
+		if err != nil {
+			return "", err
+		}
+		newRel.Attrs["Target"] = path.Join(path.Dir(rel.Attrs["Target"]), path.Base(newPath))
+	}
+	AddChild(rels, newRel)
+	return relId, nil
+}
+
+var partNumberRegexp = regexp.MustCompile(`\d*$`)
+
+// copyPart copies a part of the included package (with the parts it targets) to the report,
+// in the same folder, renamed if needed, and returns its new path
+func copyPart(include *Include, partPath string, zip *ZipArchive, contentTypes *NonTextNode) (string, error) {
+	if newPath, ok := include.copied[partPath]; ok {
+		return newPath, nil
+	}
+	data, err := include.template.Zip.GetFile(partPath)
+	if err != nil {
+		return "", err
+	}
+	newPath := partPath
+	if zip.HasFile(newPath) {
+		ext := path.Ext(partPath)
+		base := partNumberRegexp.ReplaceAllString(strings.TrimSuffix(partPath, ext), "")
+		for n := 1; zip.HasFile(newPath); n++ {
+			newPath = fmt.Sprintf("%s%d%s", base, n, ext)
+		}
+	}
+	include.copied[partPath] = newPath
+	zip.SetFile(newPath, data)
+	copyContentType(include, partPath, newPath, contentTypes)
+
+	relsPath := path.Join(path.Dir(partPath), "_rels", path.Base(partPath)+".rels")
+	if include.template.Zip.HasFile(relsPath) {
+		partRels, err := getRelsFromZip(include.template.Zip, relsPath)
+		if err != nil {
+			return "", err
+		}
+		for _, rel := range findChildren(partRels, "Relationship") {
+			if rel.Attrs["TargetMode"] == "External" {
+				continue
+			}
+			target, err := copyPart(include, resolveRelTarget(path.Dir(partPath), rel.Attrs["Target"]), zip, contentTypes)
+			if err != nil {
+				return "", err
+			}
+			rel.Attrs["Target"] = path.Join(path.Dir(rel.Attrs["Target"]), path.Base(target))
+		}
+		newRelsPath := path.Join(path.Dir(newPath), "_rels", path.Base(newPath)+".rels")
+		zip.SetFile(newRelsPath, BuildXml(partRels, XmlOptions{LiteralXmlDelimiter: DEFAULT_LITERAL_XML_DELIMITER}, ""))
+	}
+	return newPath, nil
+}
+
+// copyContentType declares the content type of a copied part: its override if the included
+// package has one (and newPath is given), otherwise the default of its extension
+func copyContentType(include *Include, partPath string, newPath string, contentTypes *NonTextNode) {
+	extension := strings.TrimPrefix(strings.ToLower(path.Ext(partPath)), ".")
+	var defaultType *NonTextNode
+	for _, contentType := range findChildren(include.template.ContentTypes, "Override") {
+		if contentType.Attrs["PartName"] == "/"+partPath && newPath != "" {
+			AddChild(contentTypes, NewNonTextNode("Override", map[string]string{
+				"PartName":    "/" + newPath,
+				"ContentType": contentType.Attrs["ContentType"],
+			}, nil))
+			return
+		}
+	}
+	for _, contentType := range findChildren(include.template.ContentTypes, "Default") {
+		if strings.ToLower(contentType.Attrs["Extension"]) == extension {
+			defaultType = contentType
+		}
+	}
+	if defaultType == nil || slices.ContainsFunc(findChildren(contentTypes, "Default"), func(node *NonTextNode) bool {
+		return strings.ToLower(node.Attrs["Extension"]) == extension
+	}) {
+		return
+	}
+	AddChild(contentTypes, NewNonTextNode("Default", map[string]string{
+		"Extension":   extension,
+		"ContentType": defaultType.Attrs["ContentType"],
+	}, nil))
+}
+
+// mergeNumbering adds the numbering definitions of the included document to the report, with
+// new ids, and returns the new numIds: [numId in the included document]numId in the report
+func mergeNumbering(include *Include, rels Node, zip *ZipArchive, contentTypes *NonTextNode) (map[string]string, error) {
+	includeRels, err := getRelsFromZip(include.template.Zip, fmt.Sprintf("%s/_rels/%s.rels", TEMPLATE_PATH, include.template.MainDocument))
+	if err != nil {
+		return nil, err
+	}
+	includePath := findRelTarget(includeRels, NUMBERING_RELATION_TYPE)
+	if includePath == "" {
+		return nil, nil
+	}
+	includeNumbering, err := parsePath(include.template.Zip, includePath)
+	if err != nil {
+		return nil, err
+	}
+
+	numbering, numberingPath, err := loadNumbering(rels, zip, contentTypes, NewNonTextNode(includeNumbering.Tag, maps.Clone(includeNumbering.Attrs), nil))
+	if err != nil {
+		return nil, err
+	}
+	mergeNamespaces(numbering, includeNumbering)
+	maxAbstractNumId, maxNumId := getMaxNumberingIds(numbering)
+
+	abstractNumIds := map[string]string{}
+	numIds := map[string]string{}
+	abstractNums := []Node{}
+	nums := []Node{}
+	for _, abstractNum := range findChildren(includeNumbering, "w:abstractNum") {
+		maxAbstractNumId++
+		abstractNumIds[abstractNum.Attrs["w:abstractNumId"]] = fmt.Sprint(maxAbstractNumId)
+		clone := cloneNode(abstractNum).(*NonTextNode)
+		clone.Attrs["w:abstractNumId"] = fmt.Sprint(maxAbstractNumId)
+		abstractNums = append(abstractNums, clone)
+	}
+	for _, num := range findChildren(includeNumbering, "w:num") {
+		maxNumId++
+		numIds[num.Attrs["w:numId"]] = fmt.Sprint(maxNumId)
+		clone := cloneNode(num).(*NonTextNode)
+		clone.Attrs["w:numId"] = fmt.Sprint(maxNumId)
+		if abstractNumId := findChild(clone, "w:abstractNumId"); abstractNumId != nil {
+			abstractNumId.Attrs["w:val"] = abstractNumIds[abstractNumId.Attrs["w:val"]]
+		}
+		nums = append(nums, clone)
+	}
+
+	insertNumberingDefinitions(numbering, abstractNums, nums)
+	zip.SetFile(numberingPath, BuildXml(numbering, XmlOptions{LiteralXmlDelimiter: DEFAULT_LITERAL_XML_DELIMITER}, ""))
+	return numIds, nil
+}
+
+// mergeStyles adds the styles of the included document that the report doesn't have.
+// Styles with the same id keep the definition of the report.
+func mergeStyles(include *Include, rels Node, zip *ZipArchive, numIds map[string]string) error {
+	includeRels, err := getRelsFromZip(include.template.Zip, fmt.Sprintf("%s/_rels/%s.rels", TEMPLATE_PATH, include.template.MainDocument))
+	if err != nil {
+		return err
+	}
+	includePath := findRelTarget(includeRels, STYLES_RELATION_TYPE)
+	stylesPath := findRelTarget(rels, STYLES_RELATION_TYPE)
+	if includePath == "" || stylesPath == "" {
+		return nil
+	}
+	includeStyles, err := parsePath(include.template.Zip, includePath)
+	if err != nil {
+		return err
+	}
+	styles, err := parsePath(zip, stylesPath)
+	if err != nil {
+		return err
+	}
+
+	styleIds := map[string]bool{}
+	for _, style := range findChildren(styles, "w:style") {
+		styleIds[style.Attrs["w:styleId"]] = true
+	}
+	added := false
+	for _, style := range findChildren(includeStyles, "w:style") {
+		if styleIds[style.Attrs["w:styleId"]] {
+			continue
+		}
+		clone := cloneNode(style)
+		for _, numId := range findDescendants(clone, "w:numId") {
+			if newNumId, ok := numIds[numId.Attrs["w:val"]]; ok {
+				numId.Attrs["w:val"] = newNumId
+			}
+		}
+		AddChild(styles, clone)
+		added = true
+	}
+	if added {
+		mergeNamespaces(styles, includeStyles)
+		zip.SetFile(stylesPath, BuildXml(styles, XmlOptions{LiteralXmlDelimiter: DEFAULT_LITERAL_XML_DELIMITER}, ""))
+	}
+	return nil
+}
+
+// mergeNotes adds the footnotes, endnotes or comments of the included document to the report
+// (creating its part if missing), with new ids, and returns them: [id in the included
+// document]id in the report. The separators of the notes are only added with a new part.
+func mergeNotes(include *Include, kind notesPart, rels Node, zip *ZipArchive, contentTypes *NonTextNode) (map[string]string, error) {
+	includeRels, err := getRelsFromZip(include.template.Zip, fmt.Sprintf("%s/_rels/%s.rels", TEMPLATE_PATH, include.template.MainDocument))
+	if err != nil {
+		return nil, err
+	}
+	includePath := findRelTarget(includeRels, kind.relationType)
+	if includePath == "" {
+		return nil, nil
+	}
+	includeNotes, err := parsePath(include.template.Zip, includePath)
+	if err != nil {
+		return nil, err
+	}
+
+	notesPath := findRelTarget(rels, kind.relationType)
+	var notes *NonTextNode
+	if notesPath == "" {
+		notesPath = TEMPLATE_PATH + "/" + kind.target
+		notes = NewNonTextNode(kind.tag, maps.Clone(includeNotes.Attrs), nil)
+		for _, note := range findChildren(includeNotes, kind.noteTag) {
+			if noteType := note.Attrs["w:type"]; noteType != "" && noteType != "normal" {
+				AddChild(notes, cloneNode(note))
+			}
+		}
+		AddChild(rels, NewNonTextNode("Relationship", map[string]string{
+			"Id":     uniqueRelId(rels, strings.TrimSuffix(kind.target, ".xml")),
+			"Type":   kind.relationType,
+			"Target": kind.target,
+		}, nil))
+		AddChild(contentTypes, NewNonTextNode("Override", map[string]string{
+			"PartName":    "/" + notesPath,
+			"ContentType": kind.contentType,
+		}, nil))
+	} else {
+		notes, err = parsePath(zip, notesPath)
+		if err != nil {
+			return nil, err
+		}
+		mergeNamespaces(notes, includeNotes)
+	}
+	maxId := 0
+	for _, note := range findChildren(notes, kind.noteTag) {
+		if id, err := strconv.Atoi(note.Attrs["w:id"]); err == nil {
+			maxId = max(maxId, id)
+		}
+	}
+
+	// The relationships of the notes (e.g. hyperlinks) are copied to the notes part of the report
+	includeNotesRels, err := getRelsFromZip(include.template.Zip, path.Join(path.Dir(includePath), "_rels", path.Base(includePath)+".rels"))
+	if err != nil {
+		return nil, err
+	}
+	relsById := map[string]*NonTextNode{}
+	for _, rel := range findChildren(includeNotesRels, "Relationship") {
+		relsById[rel.Attrs["Id"]] = rel
+	}
+	notesRelsPath := path.Join(path.Dir(notesPath), "_rels", path.Base(notesPath)+".rels")
+	notesRels, err := getRelsFromZip(zip, notesRelsPath)
+	if err != nil {
+		return nil, err
+	}
+	relIds := map[string]string{}
+
+	ids := map[string]string{}
+	for _, note := range findChildren(includeNotes, kind.noteTag) {
+		if noteType := note.Attrs["w:type"]; noteType != "" && noteType != "normal" {
+			continue
+		}
+		maxId++
+		ids[note.Attrs["w:id"]] = fmt.Sprint(maxId)
+		clone := cloneNode(note).(*NonTextNode)
+		clone.Attrs["w:id"] = fmt.Sprint(maxId)
+		for _, node := range findIncludeDescendants(clone, nil) {
+			for attr, value := range node.Attrs {
+				rel, ok := relsById[value]
+				if !strings.HasPrefix(attr, "r:") || !ok {
+					continue
+				}
+				if _, ok := relIds[value]; !ok {
+					relIds[value] = uniqueRelId(notesRels, fmt.Sprintf("include_%s", value))
+					newRel := NewNonTextNode("Relationship", maps.Clone(rel.Attrs), nil)
+					newRel.Attrs["Id"] = relIds[value]
+					if rel.Attrs["TargetMode"] != "External" {
+						newPath, err := copyPart(include, resolveRelTarget(path.Dir(includePath), rel.Attrs["Target"]), zip, contentTypes)
+						if err != nil {
+							return nil, err
+						}
+						newRel.Attrs["Target"] = path.Join(path.Dir(rel.Attrs["Target"]), path.Base(newPath))
+					}
+					AddChild(notesRels, newRel)
+				}
+				node.Attrs[attr] = relIds[value]
+			}
+		}
+		AddChild(notes, clone)
+	}
+
+	xmlOptions := XmlOptions{LiteralXmlDelimiter: DEFAULT_LITERAL_XML_DELIMITER}
+	zip.SetFile(notesPath, BuildXml(notes, xmlOptions, ""))
+	if len(relIds) > 0 {
+		zip.SetFile(notesRelsPath, BuildXml(notesRels, xmlOptions, ""))
+	}
+	return ids, nil
+}
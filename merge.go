@@ -0,0 +1,171 @@
+package godocx
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"path"
+	"slices"
+	"strings"
+)
+
+var MergeSeparators = []string{"page", "section", "none"}
+
+type MergeOptions struct {
+	// optional: ["page", "section", "none"], defaults to "page".
+	// With "section", each document keeps its own page layout, headers and footers;
+	// otherwise those of the first document are used.
+	Separator string
+}
+
+// CreateMergedReport generates a report for each data set, and merges them into one document
+func CreateMergedReport(templatePath string, data []*ReportData, options CreateReportOptions, mergeOptions MergeOptions) ([]byte, error) {
+	template, err := NewTemplate(templatePath, options)
+	if err != nil {
+		return nil, err
+	}
+	documents := make([][]byte, len(data))
+	for i, reportData := range data {
+		document, err := template.Render(reportData)
+		if err != nil {
+			return nil, fmt.Errorf("Report %d: %w", i+1, err)
+		}
+		documents[i] = document
+	}
+	return MergeDocuments(documents, mergeOptions)
+}
+
+// MergeDocuments concatenates the bodies of .docx documents (e.g. generated by CreateReport)
+// into the first one, merging their images, relationships, styles, numbering definitions,
+// footnotes, endnotes and comments
+func MergeDocuments(documents [][]byte, options MergeOptions) ([]byte, error) {
+	if len(documents) == 0 {
+		return nil, errors.New("No documents to merge")
+	}
+	separator := options.Separator
+	if separator == "" {
+		separator = "page"
+	}
+	if !slices.Contains(MergeSeparators, separator) {
+		return nil, fmt.Errorf("Invalid separator %q (one of %v)", separator, MergeSeparators)
+	}
+
+	outBuffer := new(bytes.Buffer)
+	zip, err := NewZipArchiveFromBytes(documents[0], outBuffer)
+	if err != nil {
+		return nil, fmt.Errorf("Document 1: %w", err)
+	}
+	parseResult, err := ParseTemplate(zip)
+	if err != nil {
+		return nil, fmt.Errorf("Document 1: %w", err)
+	}
+	body := findChild(parseResult.Root, "w:body")
+	if body == nil {
+		return nil, errors.New("Document 1: no body")
+	}
+	bodySectPr := popSectPr(body)
+
+	node := NewNonTextNode
+	includes := Includes{}
+	for i, document := range documents[1:] {
+		name := fmt.Sprintf("Document %d", i+2)
+		documentZip, err := NewZipArchiveFromBytes(document, io.Discard)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		documentParseResult, err := ParseTemplate(documentZip)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		documentBody := findChild(documentParseResult.Root, "w:body")
+		if documentBody == nil {
+			return nil, fmt.Errorf("%s: no body", name)
+		}
+		sectPr := popSectPr(documentBody)
+		nodes := slices.Clone(documentBody.Children())
+
+		switch separator {
+		case "page":
+			AddChild(body, node(P_TAG, nil, []Node{
+				node(R_TAG, nil, []Node{node("w:br", map[string]string{"w:type": "page"}, nil)}),
+			}))
+		case "section":
+			// The previous section ends with a paragraph holding its properties
+			if bodySectPr != nil {
+				AddChild(body, node(P_TAG, nil, []Node{node("w:pPr", nil, []Node{bodySectPr})}))
+			}
+			bodySectPr = sectPr
+			if sectPr != nil {
+				nodes = append(nodes, sectPr)
+			}
+		}
+		for _, child := range nodes {
+			if child != sectPr {
+				AddChild(body, child)
+			}
+		}
+		includes = append(includes, &Include{name: name, template: documentParseResult, nodes: nodes, copied: map[string]string{}})
+	}
+	if bodySectPr != nil {
+		AddChild(body, bodySectPr)
+	}
+
+	images := Images{}
+	err = ProcessIncludes(includes, parseResult.Root, images, parseResult.MainDocument, zip, parseResult.ContentTypes)
+	if err != nil {
+		return nil, fmt.Errorf("ProcessIncludes failed: %w", err)
+	}
+	err = ProcessImages(images, parseResult.MainDocument, zip)
+	if err != nil {
+		return nil, fmt.Errorf("ProcessImages failed: %w", err)
+	}
+
+	// Drawing ids must be unique in the document, its headers and footers included
+	xmlOptions := XmlOptions{LiteralXmlDelimiter: DEFAULT_LITERAL_XML_DELIMITER}
+	drawingId := renumberDrawings(parseResult.Root, 0)
+	for _, extraPath := range slices.Sorted(maps.Keys(parseResult.Extras)) {
+		drawingId = renumberDrawings(parseResult.Extras[extraPath], drawingId)
+		zip.SetFile(extraPath, BuildXml(parseResult.Extras[extraPath], xmlOptions, ""))
+	}
+	for _, include := range includes {
+		for _, partPath := range slices.Sorted(maps.Values(include.copied)) {
+			if name := path.Base(partPath); !strings.HasPrefix(name, "header") && !strings.HasPrefix(name, "footer") {
+				continue
+			}
+			part, err := parsePath(zip, partPath)
+			if err != nil {
+				return nil, err
+			}
+			drawingId = renumberDrawings(part, drawingId)
+			zip.SetFile(partPath, BuildXml(part, xmlOptions, ""))
+		}
+	}
+
+	zip.SetFile(fmt.Sprintf("%s/%s", TEMPLATE_PATH, parseResult.MainDocument), BuildXml(parseResult.Root, xmlOptions, ""))
+	zip.SetFile(CONTENT_TYPES_PATH, BuildXml(parseResult.ContentTypes, xmlOptions, ""))
+	err = zip.Close()
+	if err != nil {
+		return nil, fmt.Errorf("Error closing zip : %w", err)
+	}
+	return outBuffer.Bytes(), nil
+}
+
+// popSectPr removes the section properties at the end of a body, and returns them
+func popSectPr(body *NonTextNode) *NonTextNode {
+	sectPr := findChild(body, "w:sectPr")
+	if sectPr != nil {
+		removeNode(sectPr)
+	}
+	return sectPr
+}
+
+// renumberDrawings gives consecutive ids to the drawings of a part, after lastId, and returns the last one
+func renumberDrawings(part Node, lastId int) int {
+	for _, docPr := range findDescendants(part, DOCPR_TAG) {
+		lastId++
+		docPr.Attrs["id"] = fmt.Sprint(lastId)
+	}
+	return lastId
+}
@@ -0,0 +1,369 @@
+package godocx
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+var (
+	ListKinds    = []string{"bullet", "number"}
+	NUMPR_ORDER  = []string{"w:ilvl", "w:numId"}
+	LIST_BULLETS = []string{"•", "◦", "▪"}
+	LIST_FORMATS = []string{"decimal", "lowerLetter", "lowerRoman"}
+)
+
+const (
+	MAX_LIST_LEVEL  = 8
+	LIST_INDENT     = 720 // twips, by level
+	LIST_HANGING    = 360
+	DEFAULT_LIST    = "bullet"
+	numberingLevels = MAX_LIST_LEVEL + 1
+)
+
+// Numbering collects the lists generated during the walk, whose numbering definitions are
+// added to the numbering part afterwards (see ProcessNumbering)
+type Numbering struct {
+	scope      int                  // incremented by each RESTART-NUMBERING
+	paragraphs map[*NonTextNode]int // [output paragraph]scope, after a RESTART-NUMBERING
+	lists      []*generatedList
+}
+
+type generatedList struct {
+	kind   string
+	numIds []*NonTextNode
+}
+
+type listItem struct {
+	level int
+	text  string
+}
+
+type pendingList struct {
+	kind  string
+	items []listItem
+	rPr   *NonTextNode
+}
+
+func newNumbering() *Numbering {
+	return &Numbering{paragraphs: map[*NonTextNode]int{}}
+}
+
+func (n *Numbering) isEmpty() bool {
+	return n == nil || (len(n.paragraphs) == 0 && len(n.lists) == 0)
+}
+
+// LIST [bullet|number] <expression>
+func processList(data *ReportData, ctx *Context, rest string) error {
+	kind := DEFAULT_LIST
+	if first, expression, ok := strings.Cut(strings.TrimSpace(rest), " "); ok {
+		if idx := slices.IndexFunc(ListKinds, func(k string) bool { return strings.EqualFold(k, first) }); idx >= 0 {
+			kind, rest = ListKinds[idx], expression
+		}
+	}
+	value, err := runAndGetValue(rest, ctx, data)
+	if err != nil {
+		return err
+	}
+	items, err := getListItems(value, 0)
+	if err != nil {
+		return fmt.Errorf("%w: %s", err, rest)
+	}
+	list := &pendingList{kind: kind, items: items}
+	if ctx.textRunPropsNode != nil {
+		list.rPr = cloneNode(ctx.textRunPropsNode).(*NonTextNode)
+	}
+	ctx.pendingList = list
+	return nil
+}
+
+// getListItems flattens nested arrays: an array in an array is a sub-list of the previous item
+func getListItems(value VarValue, level int) ([]listItem, error) {
+	reflected := reflect.ValueOf(value)
+	if reflected.Kind() != reflect.Slice {
+		return nil, errors.New("Not an array")
+	}
+	items := []listItem{}
+	for i := range reflected.Len() {
+		item := reflected.Index(i).Interface()
+		if reflect.ValueOf(item).Kind() == reflect.Slice {
+			subItems, err := getListItems(item, min(level+1, MAX_LIST_LEVEL))
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, subItems...)
+		} else {
+			items = append(items, listItem{level: level, text: fmt.Sprint(item)})
+		}
+	}
+	return items, nil
+}
+
+// newListParagraphs returns the paragraphs of a LIST command, with the properties of its paragraph
+func newListParagraphs(list *pendingList, p *NonTextNode, numbering *Numbering) []Node {
+	node := NewNonTextNode
+	generated := &generatedList{kind: list.kind}
+	paragraphs := make([]Node, len(list.items))
+	for i, item := range list.items {
+		paragraph := node(P_TAG, nil, nil)
+		if pPr := findChild(p, "w:pPr"); pPr != nil {
+			AddChild(paragraph, cloneNode(pPr))
+		}
+		pPr := ensureChildInOrder(paragraph, "w:pPr", P_ORDER)
+		numId := node("w:numId", map[string]string{"w:val": "0"}, nil)
+		setChildInOrder(pPr, node("w:numPr", nil, []Node{
+			node("w:ilvl", map[string]string{"w:val": fmt.Sprint(item.level)}, nil),
+			numId,
+		}), PPR_ORDER)
+		generated.numIds = append(generated.numIds, numId)
+
+		run := node(R_TAG, nil, nil)
+		if list.rPr != nil {
+			AddChild(run, cloneNode(list.rPr))
+		}
+		AddChild(run, node(T_TAG, map[string]string{"xml:space": "preserve"}, []Node{NewTextNode(item.text)}))
+		AddChild(paragraph, run)
+		paragraphs[i] = paragraph
+	}
+	numbering.lists = append(numbering.lists, generated)
+	return paragraphs
+}
+
+// ProcessNumbering adds the numbering definitions of the generated lists: a new instance of
+// the numbering of the list paragraphs following a RESTART-NUMBERING (once per numbering
+// and command), and those of the LIST commands
+func ProcessNumbering(numbering *Numbering, report Node, documentComponent string, zip *ZipArchive, contentTypes *NonTextNode) error {
+	if numbering.isEmpty() {
+		return nil
+	}
+	relsPath := fmt.Sprintf("%s/_rels/%s.rels", TEMPLATE_PATH, documentComponent)
+	rels, err := getRelsFromZip(zip, relsPath)
+	if err != nil {
+		return err
+	}
+	numberingPart, numberingPath, err := loadNumbering(rels, zip, contentTypes, NewNonTextNode("w:numbering", map[string]string{"xmlns:w": WORDML_NAMESPACE}, nil))
+	if err != nil {
+		return err
+	}
+	styleNumbering, err := getStyleNumbering(rels, zip)
+	if err != nil {
+		return err
+	}
+	maxAbstractNumId, maxNumId := getMaxNumberingIds(numberingPart)
+	abstractNums, nums := []Node{}, []Node{}
+	node := NewNonTextNode
+
+	abstractNumIds := map[string]string{} // [numId]abstractNumId
+	for _, num := range findChildren(numberingPart, "w:num") {
+		if abstractNumId := findChild(num, "w:abstractNumId"); abstractNumId != nil {
+			abstractNumIds[num.Attrs["w:numId"]] = abstractNumId.Attrs["w:val"]
+		}
+	}
+	restartedIds := map[string]string{} // [scope/numId]new numId
+	for _, p := range findDescendants(report, P_TAG) {
+		scope, ok := numbering.paragraphs[p]
+		if !ok {
+			continue
+		}
+		numId, ilvl, fromStyle := getParagraphNumbering(p, styleNumbering)
+		abstractNumId, ok := abstractNumIds[numId]
+		if !ok {
+			continue
+		}
+		key := fmt.Sprintf("%d/%s", scope, numId)
+		if _, ok := restartedIds[key]; !ok {
+			maxNumId++
+			restartedIds[key] = fmt.Sprint(maxNumId)
+			num := node("w:num", map[string]string{"w:numId": fmt.Sprint(maxNumId)}, []Node{
+				node("w:abstractNumId", map[string]string{"w:val": abstractNumId}, nil),
+			})
+			starts := getLevelStarts(numberingPart, abstractNumId)
+			for level := range numberingLevels {
+				AddChild(num, node("w:lvlOverride", map[string]string{"w:ilvl": fmt.Sprint(level)}, []Node{
+					node("w:startOverride", map[string]string{"w:val": fmt.Sprint(starts[level])}, nil),
+				}))
+			}
+			nums = append(nums, num)
+		}
+		pPr := ensureChildInOrder(p, "w:pPr", P_ORDER)
+		numPr := ensureChildInOrder(pPr, "w:numPr", PPR_ORDER)
+		if fromStyle {
+			setChildInOrder(numPr, node("w:ilvl", map[string]string{"w:val": ilvl}, nil), NUMPR_ORDER)
+		}
+		setChildInOrder(numPr, node("w:numId", map[string]string{"w:val": restartedIds[key]}, nil), NUMPR_ORDER)
+	}
+
+	listAbstractNumIds := map[string]string{} // [kind]abstractNumId
+	for _, list := range numbering.lists {
+		if _, ok := listAbstractNumIds[list.kind]; !ok {
+			maxAbstractNumId++
+			listAbstractNumIds[list.kind] = fmt.Sprint(maxAbstractNumId)
+			abstractNums = append(abstractNums, newListAbstractNum(list.kind, fmt.Sprint(maxAbstractNumId)))
+		}
+		maxNumId++
+		nums = append(nums, node("w:num", map[string]string{"w:numId": fmt.Sprint(maxNumId)}, []Node{
+			node("w:abstractNumId", map[string]string{"w:val": listAbstractNumIds[list.kind]}, nil),
+		}))
+		for _, numId := range list.numIds {
+			numId.Attrs["w:val"] = fmt.Sprint(maxNumId)
+		}
+	}
+
+	insertNumberingDefinitions(numberingPart, abstractNums, nums)
+	zip.SetFile(numberingPath, BuildXml(numberingPart, XmlOptions{LiteralXmlDelimiter: DEFAULT_LITERAL_XML_DELIMITER}, ""))
+	zip.SetFile(relsPath, BuildXml(rels, XmlOptions{LiteralXmlDelimiter: DEFAULT_LITERAL_XML_DELIMITER}, ""))
+	return nil
+}
+
+func newListAbstractNum(kind string, abstractNumId string) *NonTextNode {
+	node := NewNonTextNode
+	abstractNum := node("w:abstractNum", map[string]string{"w:abstractNumId": abstractNumId}, []Node{
+		node("w:multiLevelType", map[string]string{"w:val": "hybridMultilevel"}, nil),
+	})
+	for level := range numberingLevels {
+		numFmt, lvlText := "bullet", LIST_BULLETS[level%len(LIST_BULLETS)]
+		if kind == "number" {
+			numFmt, lvlText = LIST_FORMATS[level%len(LIST_FORMATS)], fmt.Sprintf("%%%d.", level+1)
+		}
+		AddChild(abstractNum, node("w:lvl", map[string]string{"w:ilvl": fmt.Sprint(level)}, []Node{
+			node("w:start", map[string]string{"w:val": "1"}, nil),
+			node("w:numFmt", map[string]string{"w:val": numFmt}, nil),
+			node("w:lvlText", map[string]string{"w:val": lvlText}, nil),
+			node("w:lvlJc", map[string]string{"w:val": "left"}, nil),
+			node("w:pPr", nil, []Node{
+				node("w:ind", map[string]string{"w:left": fmt.Sprint(LIST_INDENT * (level + 1)), "w:hanging": fmt.Sprint(LIST_HANGING)}, nil),
+			}),
+		}))
+	}
+	return abstractNum
+}
+
+// loadNumbering returns the numbering part of a document and its path, creating it from root if missing
+func loadNumbering(rels Node, zip *ZipArchive, contentTypes *NonTextNode, root *NonTextNode) (*NonTextNode, string, error) {
+	numberingPath := findRelTarget(rels, NUMBERING_RELATION_TYPE)
+	if numberingPath != "" {
+		numbering, err := parsePath(zip, numberingPath)
+		return numbering, numberingPath, err
+	}
+	numberingPath = TEMPLATE_PATH + "/numbering.xml"
+	AddChild(rels, NewNonTextNode("Relationship", map[string]string{
+		"Id":     uniqueRelId(rels, "numbering"),
+		"Type":   NUMBERING_RELATION_TYPE,
+		"Target": "numbering.xml",
+	}, nil))
+	AddChild(contentTypes, NewNonTextNode("Override", map[string]string{
+		"PartName":    "/" + numberingPath,
+		"ContentType": NUMBERING_CONTENT_TYPE,
+	}, nil))
+	return root, numberingPath, nil
+}
+
+func getMaxNumberingIds(numbering *NonTextNode) (maxAbstractNumId int, maxNumId int) {
+	for _, abstractNum := range findChildren(numbering, "w:abstractNum") {
+		id, _ := strconv.Atoi(abstractNum.Attrs["w:abstractNumId"])
+		maxAbstractNumId = max(maxAbstractNumId, id)
+	}
+	for _, num := range findChildren(numbering, "w:num") {
+		id, _ := strconv.Atoi(num.Attrs["w:numId"])
+		maxNumId = max(maxNumId, id)
+	}
+	return
+}
+
+// insertNumberingDefinitions adds numbering definitions: all the abstract numberings come before the numberings
+func insertNumberingDefinitions(numbering *NonTextNode, abstractNums []Node, nums []Node) {
+	lastAbstractNum := -1
+	for i, child := range numbering.Children() {
+		if nonTextChild, ok := child.(*NonTextNode); ok && nonTextChild.Tag == "w:abstractNum" {
+			lastAbstractNum = i
+		}
+	}
+	children := slices.Insert(slices.Clone(numbering.Children()), lastAbstractNum+1, abstractNums...)
+	lastNum := slices.IndexFunc(children, func(node Node) bool {
+		nonTextNode, ok := node.(*NonTextNode)
+		return ok && nonTextNode.Tag == "w:numIdMacAtCleanup"
+	})
+	if lastNum < 0 {
+		lastNum = len(children)
+	}
+	children = slices.Insert(children, lastNum, nums...)
+	for _, child := range children {
+		child.SetParent(numbering)
+	}
+	numbering.SetChildren(children)
+}
+
+// getLevelStarts returns the start values of the levels of an abstract numbering
+func getLevelStarts(numbering *NonTextNode, abstractNumId string) []int {
+	starts := slices.Repeat([]int{1}, numberingLevels)
+	for _, abstractNum := range findChildren(numbering, "w:abstractNum") {
+		if abstractNum.Attrs["w:abstractNumId"] != abstractNumId {
+			continue
+		}
+		for _, lvl := range findChildren(abstractNum, "w:lvl") {
+			level, err := strconv.Atoi(lvl.Attrs["w:ilvl"])
+			if start := findChild(lvl, "w:start"); err == nil && start != nil && level < numberingLevels {
+				starts[level] = getIntAttr(start, "w:val")
+			}
+		}
+	}
+	return starts
+}
+
+type styleNumPr struct {
+	numId string
+	ilvl  string
+}
+
+// getStyleNumbering returns the numbering of the paragraph styles, by style id
+func getStyleNumbering(rels Node, zip *ZipArchive) (map[string]styleNumPr, error) {
+	styleNumbering := map[string]styleNumPr{}
+	stylesPath := findRelTarget(rels, STYLES_RELATION_TYPE)
+	if stylesPath == "" {
+		return styleNumbering, nil
+	}
+	styles, err := parsePath(zip, stylesPath)
+	if err != nil {
+		return nil, err
+	}
+	for _, style := range findChildren(styles, "w:style") {
+		if numPr := findNumPr(style); numPr != nil {
+			styleNumbering[style.Attrs["w:styleId"]] = styleNumPr{
+				numId: findChild(numPr, "w:numId").Attrs["w:val"],
+				ilvl:  fmt.Sprint(getIntAttr(findChild(numPr, "w:ilvl"), "w:val")),
+			}
+		}
+	}
+	return styleNumbering, nil
+}
+
+// findNumPr returns the `w:numPr` (with a `w:numId`) of a paragraph or style, if any
+func findNumPr(node *NonTextNode) *NonTextNode {
+	pPr := findChild(node, "w:pPr")
+	if pPr == nil {
+		return nil
+	}
+	numPr := findChild(pPr, "w:numPr")
+	if numPr == nil || findChild(numPr, "w:numId") == nil {
+		return nil
+	}
+	return numPr
+}
+
+// getParagraphNumbering returns the numbering of a paragraph, and whether it comes from its style
+func getParagraphNumbering(p *NonTextNode, styleNumbering map[string]styleNumPr) (numId string, ilvl string, fromStyle bool) {
+	if numPr := findNumPr(p); numPr != nil {
+		return findChild(numPr, "w:numId").Attrs["w:val"], "", false
+	}
+	if pPr := findChild(p, "w:pPr"); pPr != nil {
+		if pStyle := findChild(pPr, "w:pStyle"); pStyle != nil {
+			if numPr, ok := styleNumbering[pStyle.Attrs["w:val"]]; ok {
+				return numPr.numId, numPr.ilvl, true
+			}
+		}
+	}
+	return "", "", false
+}
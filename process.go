@@ -4,11 +4,13 @@ import (
 	"errors"
 	"fmt"
 	"log/slog"
+	"math"
 	"reflect"
 	"regexp"
 	"slices"
 	"strconv"
 	"strings"
+	"unicode"
 )
 
 type ReportOutput struct {
@@ -16,6 +18,12 @@ type ReportOutput struct {
 	Images Images
 	Links  Links
 	Htmls  Htmls
+	Charts Charts
+
+	Includes     Includes
+	ChartUpdates map[string]*ChartPars // [relId] of the template charts
+	Numbering    *Numbering
+	Revisions    *Revisions
 }
 
 type ReportData map[string]any
@@ -62,6 +70,26 @@ var (
 		"IMAGE",
 		"LINK",
 		"HTML",
+		"STYLE-CELL",
+		"STYLE-ROW",
+		"CELL-SHADE",
+		"COLUMN-IF",
+		"COLUMN-WIDTH",
+		"CHART",
+		"INCLUDE",
+		"PAGEBREAK",
+		"COLUMNBREAK",
+		"SECTIONBREAK",
+		"BOOKMARK",
+		"LINK-TO",
+		"PAGEREF",
+		"FIELD",
+		"RESTART-NUMBERING",
+		"LIST",
+		"CHECKBOX",
+		"REVISIONS",
+		"COMMENT",
+		"END-COMMENT",
 	}
 )
 
@@ -70,8 +98,8 @@ func ProduceReport(data *ReportData, template Node, ctx Context) (*ReportOutput,
 }
 
 func notBuiltIns(cmd string) bool {
-	upperCmd := strings.ToUpper(cmd)
-	return !slices.ContainsFunc(BUILT_IN_COMMANDS, func(b string) bool { return strings.HasPrefix(upperCmd, b) })
+	cmdName, _ := splitCommand(cmd)
+	return !slices.Contains(BUILT_IN_COMMANDS, cmdName)
 }
 
 func getCommand(command string, shorthands map[string]string, fixSmartQuotes bool) (string, error) {
@@ -131,11 +159,12 @@ func processForIf(data *ReportData, node Node, ctx *Context, cmd string, cmdName
 	var varName string
 
 	if isIf {
-		if node.Name() == "" {
-			node.SetName("__if_" + fmt.Sprint(ctx.gCntIf))
+		// The names are kept in the context, as the template may be rendered concurrently
+		if _, ok := ctx.ifNames[node]; !ok {
+			ctx.ifNames[node] = "__if_" + fmt.Sprint(ctx.gCntIf)
 			ctx.gCntIf++
 		}
-		varName = node.Name()
+		varName = ctx.ifNames[node]
 	} else {
 		re := regexp.MustCompile(`(?i)^(\S+)\s+IN\s+(.+)$`)
 		forMatch = re.FindStringSubmatch(cmdRest)
@@ -170,27 +199,9 @@ func processForIf(data *ReportData, node Node, ctx *Context, cmd string, cmdName
 				return err
 			}
 			// Determine whether to execute the IF block based on the condition result
-			if shouldRun != nil {
-				// Handle all non-nil values uniformly
-				switch v := shouldRun.(type) {
-				case bool:
-					if v {
-						loopOver = []VarValue{1}
-					} else {
-						loopOver = []VarValue{}
-					}
-				case string:
-					if v != "" {
-						loopOver = []VarValue{1}
-					} else {
-						loopOver = []VarValue{}
-					}
-				default:
-					// For numeric types and other types, treat as true if not nil
-					loopOver = []VarValue{1}
-				}
+			if isTruthy(shouldRun) {
+				loopOver = []VarValue{1}
 			} else {
-				// nil value is treated as false
 				loopOver = []VarValue{}
 			}
 		} else {
@@ -256,8 +267,8 @@ func processEndForIf(node Node, ctx *Context, cmd string, cmdName string, cmdRes
 
 	// First time we visit an END-IF node, we assign it the arbitrary name
 	// generated when the IF was processed
-	if isIf && node.Name() == "" {
-		node.SetName(curLoop.varName)
+	if _, ok := ctx.ifNames[node]; isIf && !ok {
+		ctx.ifNames[node] = curLoop.varName
 		ctx.gCntEndIf += 1
 	}
 
@@ -272,6 +283,12 @@ func processEndForIf(node Node, ctx *Context, cmd string, cmdName string, cmdRes
 		return NewInvalidCommandError("Invalid command", cmd)
 	}
 
+	// A loop (or IF) from a cell to another of the row repeats (or removes) table columns
+	if startCell, endCell := findCell(curLoop.refNode), findAncestor(node, TC_TAG); startCell != nil && endCell != nil &&
+		startCell != endCell && startCell.Parent() == endCell.Parent() && ctx.pendingColumn == nil {
+		ctx.pendingColumn = &pendingColumn{}
+	}
+
 	// Get the next item in the loop
 	nextIdx := curLoop.idx + 1
 	var nextItem VarValue
@@ -284,6 +301,7 @@ func processEndForIf(node Node, ctx *Context, cmd string, cmdName string, cmdRes
 		if !isIf {
 			ctx.vars["$"+varName] = nextItem
 			ctx.vars["$idx"] = nextIdx
+			ctx.vars["$last"] = nextIdx == len(curLoop.loopOver)-1
 		}
 		ctx.fJump = true
 		curLoop.idx = nextIdx
@@ -291,6 +309,16 @@ func processEndForIf(node Node, ctx *Context, cmd string, cmdName string, cmdRes
 		// loop finished
 		// ctx.loops.pop()
 		ctx.loops = ctx.loops[:len(ctx.loops)-1]
+		// $idx and $last refer to the enclosing loop again
+		if !isIf {
+			for i := len(ctx.loops) - 1; i >= 0; i-- {
+				if loop := ctx.loops[i]; !loop.isIf && loop.idx >= 0 {
+					ctx.vars["$idx"] = loop.idx
+					ctx.vars["$last"] = loop.idx == len(loop.loopOver)-1
+					break
+				}
+			}
+		}
 	}
 
 	return nil
@@ -302,18 +330,20 @@ func validateImagePars(pars *ImagePars) error {
 }
 
 func validateExtension(ext string) error {
-	if !slices.Contains(ImageExtensions, ext) {
-		return fmt.Errorf("An extension (one of %v) needs to be provided when providing an image or a thumbnail.", ImageExtensions)
+	if !slices.Contains(ImageExtensions, ext) && !slices.Contains(ConvertedImageExtensions, ext) {
+		return fmt.Errorf("An extension (one of %v) needs to be provided when providing an image or a thumbnail.", slices.Concat(ImageExtensions, ConvertedImageExtensions))
 	}
 	return nil
 }
 
+// imageToContext registers an image, and returns its relationship id.
+// The id is derived from the image content, so that identical images share
+// the same media part and relationship.
 func imageToContext(ctx *Context, img *Image) string {
-	// TODO revalidate ? validateImage(img)
-	ctx.imageAndShapeIdIncrement += 1
-	id := fmt.Sprint(ctx.imageAndShapeIdIncrement)
-	relId := fmt.Sprintf("img%s", id)
-	ctx.images[relId] = img
+	relId := "img" + imageHash(img)[:20]
+	if _, exists := ctx.images[relId]; !exists {
+		ctx.images[relId] = img
+	}
 	return relId
 }
 
@@ -324,16 +354,32 @@ func getImageData(pars *ImagePars) *Image {
 	}
 }
 
+func newSvgBlipExt(svgRelId string) *NonTextNode {
+	return NewNonTextNode("a:ext", map[string]string{
+		"uri": SVG_BLIP_EXT_URI,
+	}, []Node{
+		NewNonTextNode("asvg:svgBlip", map[string]string{
+			"xmlns:asvg": "http://schemas.microsoft.com/office/drawing/2016/SVG/main",
+			"r:embed":    svgRelId,
+		}, nil),
+	})
+}
+
 func processImage(ctx *Context, imagePars *ImagePars) error {
-	err := validateImagePars(imagePars)
+	imagePars, err := prepareImage(ctx, imagePars)
 	if err != nil {
 		return err
 	}
 
-	cx := int(imagePars.Width * 360e3)
-	cy := int(imagePars.Height * 360e3)
+	width, height, err := getImageSize(imagePars)
+	if err != nil {
+		return err
+	}
+	cx := int(math.Round(width * EMU_PER_CM))
+	cy := int(math.Round(height * EMU_PER_CM))
 
 	imgRelId := imageToContext(ctx, getImageData(imagePars))
+	ctx.imageAndShapeIdIncrement += 1
 	id := fmt.Sprint(ctx.imageAndShapeIdIncrement)
 	alt := imagePars.Alt
 	if alt == "" {
@@ -358,23 +404,8 @@ func processImage(ctx *Context, imagePars *ImagePars) error {
 	}
 
 	if ctx.images[imgRelId].Extension == ".svg" {
-		// Default to an empty thumbnail, as it is not critical and just part of the docx standard's scaffolding.
-		// Without a thumbnail, the svg won't render (even in newer versions of Word that don't need the thumbnail).
-		thumbnail := imagePars.Thumbnail
-		if thumbnail == nil {
-			thumbnail = &Thumbnail{
-				Image: Image{Extension: ".png", Data: []byte{110, 111, 74, 68, 69, 110, 67, 10}},
-			}
-		}
-		thumbRelId := imageToContext(ctx, &thumbnail.Image)
-		extNodes = append(extNodes, node("a:ext", map[string]string{
-			"uri": "{96DAC541-7B7A-43D3-8B79-37D633B846F1}",
-		}, []Node{
-			node("asvg:svgBlip", map[string]string{
-				"xmlns:asvg": "http://schemas.microsoft.com/office/drawing/2016/SVG/main",
-				"r:embed":    imgRelId,
-			}, nil),
-		}))
+		thumbRelId := imageToContext(ctx, &imagePars.Thumbnail.Image)
+		extNodes = append(extNodes, newSvgBlipExt(imgRelId))
 		// For SVG the thumb is placed where the image normally goes.
 		imgRelId = thumbRelId
 	}
@@ -412,29 +443,38 @@ func processImage(ctx *Context, imagePars *ImagePars) error {
 			}),
 		},
 	)
-	drawing := node("w:drawing", map[string]string{}, []Node{
-		node("wp:inline", map[string]string{"distT": "0", "distB": "0", "distL": "0", "distR": "0"}, []Node{
-			node("wp:extent", map[string]string{"cx": fmt.Sprint(cx), "cy": fmt.Sprint(cy)}, nil),
-			node("wp:docPr", map[string]string{"id": id, "name": `Picture ` + id, "descr": alt}, nil),
-			node("wp:cNvGraphicFramePr", map[string]string{}, []Node{
-				node("a:graphicFrameLocks", map[string]string{
-					"xmlns:a":        "http://schemas.openxmlformats.org/drawingml/2006/main",
-					"noChangeAspect": "1",
-				}, nil),
-			}),
+	extent := node("wp:extent", map[string]string{"cx": fmt.Sprint(cx), "cy": fmt.Sprint(cy)}, nil)
+	docPr := node("wp:docPr", map[string]string{"id": id, "name": `Picture ` + id, "descr": alt}, nil)
+	graphicFramePr := node("wp:cNvGraphicFramePr", map[string]string{}, []Node{
+		node("a:graphicFrameLocks", map[string]string{
+			"xmlns:a":        "http://schemas.openxmlformats.org/drawingml/2006/main",
+			"noChangeAspect": "1",
+		}, nil),
+	})
+	graphic := node(
+		"a:graphic",
+		map[string]string{"xmlns:a": "http://schemas.openxmlformats.org/drawingml/2006/main"},
+		[]Node{
 			node(
-				"a:graphic",
-				map[string]string{"xmlns:a": "http://schemas.openxmlformats.org/drawingml/2006/main"},
-				[]Node{
-					node(
-						"a:graphicData",
-						map[string]string{"uri": "http://schemas.openxmlformats.org/drawingml/2006/picture"},
-						[]Node{pic},
-					),
-				},
+				"a:graphicData",
+				map[string]string{"uri": "http://schemas.openxmlformats.org/drawingml/2006/picture"},
+				[]Node{pic},
 			),
-		}),
-	})
+		},
+	)
+
+	var placement *NonTextNode
+	if imagePars.Anchor != nil {
+		placement, err = newAnchorNode(imagePars.Anchor, ctx.imageAndShapeIdIncrement, extent, docPr, graphicFramePr, graphic)
+		if err != nil {
+			return err
+		}
+	} else {
+		placement = node("wp:inline", map[string]string{"distT": "0", "distB": "0", "distL": "0", "distR": "0"}, []Node{
+			extent, docPr, graphicFramePr, graphic,
+		})
+	}
+	drawing := node("w:drawing", map[string]string{}, []Node{placement})
 
 	ctx.pendingImageNode = &struct {
 		image   *NonTextNode
@@ -488,6 +528,64 @@ func processLink(ctx *Context, linkPars *LinkPars) error {
 	return nil
 }
 
+func processCellStyle(data *ReportData, node Node, ctx *Context, cmdName string, rest string) error {
+	var args map[string]string
+	if cmdName == "CELL-SHADE" {
+		args = map[string]string{"shade": rest}
+	} else {
+		var err error
+		args, err = parseNamedArgs(rest)
+		if err != nil {
+			return err
+		}
+	}
+	style, err := evalCellStyle(args, ctx, data)
+	if err != nil {
+		return err
+	}
+	if cmdName == "STYLE-ROW" {
+		if findAncestor(node, TR_TAG) == nil {
+			return errors.New(cmdName + " used outside of a table row")
+		}
+		ctx.pendingRowStyle = style
+	} else {
+		if findAncestor(node, TC_TAG) == nil {
+			return errors.New(cmdName + " used outside of a table cell")
+		}
+		ctx.pendingCellStyle = style
+	}
+	// Prevent the styled cell or row from being removed, even if it only contains commands
+	ctx.buffers[TR_TAG].fInsertedText = true
+	ctx.buffers[TC_TAG].fInsertedText = true
+	return nil
+}
+
+func processColumn(data *ReportData, node Node, ctx *Context, cmdName string, rest string) error {
+	if findAncestor(node, TC_TAG) == nil {
+		return errors.New(cmdName + " used outside of a table cell")
+	}
+	value, err := runAndGetValue(rest, ctx, data)
+	if err != nil {
+		return err
+	}
+	if ctx.pendingColumn == nil {
+		ctx.pendingColumn = &pendingColumn{}
+	}
+	if cmdName == "COLUMN-IF" {
+		ctx.pendingColumn.hidden = !isTruthy(value)
+	} else {
+		weight, ok := toNumber(value)
+		if !ok || weight <= 0 {
+			return fmt.Errorf("Invalid column width: %v", value)
+		}
+		ctx.pendingColumn.weight = weight
+	}
+	// Prevent the cell from being removed, even if it only contains commands
+	ctx.buffers[TR_TAG].fInsertedText = true
+	ctx.buffers[TC_TAG].fInsertedText = true
+	return nil
+}
+
 func findParentPorTrNode(node Node) (resultNode Node) {
 	parentNode := node.Parent()
 
@@ -521,12 +619,16 @@ func parseFunctionCall(rest string) ([]string, bool) {
 		// parse args char by char to handle string containing commas
 		args := []string{}
 		current := strings.Builder{}
-		isInString := false
+		var quote rune // closing quote of the current string, 0 outside of strings
 		for _, char := range []rune(matches[2]) {
-			if char == '\'' || char == '"' || char == '‘' || char == '’' || char == '“' || char == '”' {
+			if closing, isQuote := closingQuote(char); (quote == 0 && isQuote) || (quote != 0 && char == quote) {
 				current.WriteRune('\'')
-				isInString = !isInString
-			} else if char == ',' && !isInString {
+				if quote == 0 {
+					quote = closing
+				} else {
+					quote = 0
+				}
+			} else if char == ',' && quote == 0 {
 				args = append(args, strings.TrimSpace(current.String()))
 				current.Reset()
 			} else {
@@ -540,6 +642,111 @@ func parseFunctionCall(rest string) ([]string, bool) {
 	return nil, false
 }
 
+// closingQuote returns the character closing a string opened by char, if char is a quote
+func closingQuote(char rune) (rune, bool) {
+	switch char {
+	case '\'', '"', '`', '’', '”':
+		return char, true
+	case '‘':
+		return '’', true
+	case '“':
+		return '”', true
+	}
+	return 0, false
+}
+
+// splitTernary splits `cond ? a : b` at the top-level `?` and its matching `:`. Quoted strings
+// and parentheses are skipped, and nested ternaries are matched by depth, e.g.
+// `a ? b ? c : d : e` is split into `a`, `b ? c : d` and `e`.
+func splitTernary(text string) (cond string, ifTrue string, ifFalse string, ok bool) {
+	runes := []rune(text)
+	var quote rune // closing quote of the current string, 0 outside of strings
+	parens := 0
+	questionIdx := -1
+	nested := 0 // `?` met after the top-level one, waiting for their `:`
+	for i, char := range runes {
+		if quote != 0 {
+			if char == quote {
+				quote = 0
+			}
+			continue
+		}
+		if closing, isQuote := closingQuote(char); isQuote {
+			quote = closing
+			continue
+		}
+		switch {
+		case char == '(':
+			parens++
+		case char == ')':
+			parens--
+		case parens > 0:
+		case char == '?' && questionIdx < 0:
+			questionIdx = i
+		case char == '?':
+			nested++
+		case char == ':' && questionIdx >= 0 && nested > 0:
+			nested--
+		case char == ':' && questionIdx >= 0:
+			cond = strings.TrimSpace(string(runes[:questionIdx]))
+			ifTrue = strings.TrimSpace(string(runes[questionIdx+1 : i]))
+			ifFalse = strings.TrimSpace(string(runes[i+1:]))
+			return cond, ifTrue, ifFalse, cond != "" && ifTrue != "" && ifFalse != ""
+		}
+	}
+	return "", "", "", false
+}
+
+// parseNamedArgs parses `key=<expression> other=<expression>` arguments.
+// Each expression runs until the next `key=` found outside of a quoted string.
+func parseNamedArgs(rest string) (map[string]string, error) {
+	runes := []rune(rest)
+	type argStart struct {
+		key        string
+		keyStart   int
+		valueStart int
+	}
+	starts := []argStart{}
+	var quote rune // closing quote of the current string, 0 outside of strings
+	for i := 0; i < len(runes); i++ {
+		char := runes[i]
+		if quote != 0 {
+			if char == quote {
+				quote = 0
+			}
+			continue
+		}
+		if closing, isQuote := closingQuote(char); isQuote {
+			quote = closing
+			continue
+		}
+		if (i > 0 && !unicode.IsSpace(runes[i-1])) || !unicode.IsLetter(char) {
+			continue
+		}
+		j := i
+		for j < len(runes) && (unicode.IsLetter(runes[j]) || unicode.IsDigit(runes[j]) || runes[j] == '-') {
+			j++
+		}
+		// `key=` but not `key==`
+		if j < len(runes) && runes[j] == '=' && (j+1 >= len(runes) || runes[j+1] != '=') {
+			starts = append(starts, argStart{key: strings.ToLower(string(runes[i:j])), keyStart: i, valueStart: j + 1})
+			i = j
+		}
+	}
+	if len(starts) == 0 || strings.TrimSpace(string(runes[:starts[0].keyStart])) != "" {
+		return nil, errors.New("Invalid named arguments: " + rest)
+	}
+	args := map[string]string{}
+	for i, start := range starts {
+		end := len(runes)
+		if i < len(starts)-1 {
+			end = starts[i+1].keyStart
+		}
+		args[start.key] = strings.TrimSpace(string(runes[start.valueStart:end]))
+	}
+	return args, nil
+}
+
 func isLink(varValue VarValue) (*LinkPars, bool) {
 	if strMap, ok := varValue.(map[string]any); ok {
 		url, hasUrl := strMap["url"].(string)
@@ -567,7 +774,7 @@ func getValue(key string, ctx *Context, data *ReportData) (VarValue, bool) {
 		return getFromVars(ctx, key)
 	}
 	lastI := len(key) - 1
-	if lastI > 0 && (key[0] == '\'' && key[lastI] == '\'') || (key[0] == '`' && key[lastI] == '`') {
+	if lastI > 0 && (key[0] == '\'' || key[0] == '"' || key[0] == '`') && key[lastI] == key[0] {
 		return key[1:lastI], true
 	}
 	if number, err := strconv.ParseInt(key, 10, 64); err == nil {
@@ -593,6 +800,9 @@ func runFunction(funcName string, args []string, ctx *Context, data *ReportData)
 				}
 			}
 			value := function(argValues...)
+			if err, ok := value.(error); ok {
+				return "", err
+			}
 			return value, nil
 		} else {
 			return "", &FunctionNotFoundError{FunctionName: funcName}
@@ -604,6 +814,17 @@ func runFunction(funcName string, args []string, ctx *Context, data *ReportData)
 
 func runAndGetValue(text string, ctx *Context, data *ReportData) (VarValue, error) {
 	var value VarValue
+	// Process ternary expression (cond ? a : b)
+	if cond, ifTrue, ifFalse, ok := splitTernary(text); ok {
+		condValue, err := runAndGetValue(cond, ctx, data)
+		if err != nil {
+			return nil, err
+		}
+		if isTruthy(condValue) {
+			return runAndGetValue(ifTrue, ctx, data)
+		}
+		return runAndGetValue(ifFalse, ctx, data)
+	}
 	// Process conditional expression
 	// Check comparison operators
 	for _, op := range []string{"==", "!=", ">=", "<=", ">", "<"} {
@@ -647,6 +868,15 @@ func runAndGetValue(text string, ctx *Context, data *ReportData) (VarValue, erro
 		}
 	}
 
+	// Process negation (!expression)
+	if trimmed := strings.TrimSpace(text); strings.HasPrefix(trimmed, "!") {
+		value, err := runAndGetValue(trimmed[1:], ctx, data)
+		if err != nil {
+			return nil, err
+		}
+		return !isTruthy(value), nil
+	}
+
 	if args, isFunction := parseFunctionCall(text); isFunction {
 		funcName := args[0]
 		args = args[1:]
@@ -666,6 +896,22 @@ func runAndGetValue(text string, ctx *Context, data *ReportData) (VarValue, erro
 	return value, nil
 }
 
+// Helper function: Determine whether a value should be considered true
+func isTruthy(v VarValue) bool {
+	switch val := v.(type) {
+	case nil:
+		// nil value is treated as false
+		return false
+	case bool:
+		return val
+	case string:
+		return val != ""
+	default:
+		// For numeric types and other types, treat as true if not nil
+		return true
+	}
+}
+
 // Helper function: Convert value to float64 for comparison
 func toNumber(v interface{}) (float64, bool) {
 	switch val := v.(type) {
@@ -797,6 +1043,24 @@ func processCmd(data *ReportData, node Node, ctx *Context) (string, error) {
 			}
 		}
 
+		// CHART <code>
+	} else if cmdName == "CHART" {
+		if !isLoopExploring(ctx) {
+			varValue, err := runAndGetValue(rest, ctx, data)
+			if err != nil {
+				return "", err
+			}
+
+			if chartPars, ok := varValue.(*ChartPars); ok {
+				err := processChart(ctx, chartPars)
+				if err != nil {
+					return "", fmt.Errorf("ChartError: %w", err)
+				}
+			} else {
+				return "", errors.New("Not a chart as result of " + rest)
+			}
+		}
+
 		// LINK <code>
 	} else if cmdName == "LINK" {
 		if !isLoopExploring(ctx) {
@@ -811,6 +1075,101 @@ func processCmd(data *ReportData, node Node, ctx *Context) (string, error) {
 				}
 			}
 		}
+	} else if cmdName == "INCLUDE" {
+		if !isLoopExploring(ctx) {
+			varValue, err := runAndGetValue(rest, ctx, data)
+			if err != nil {
+				return "", err
+			}
+			name, ok := varValue.(string)
+			if !ok {
+				return "", errors.New("Not a template name as result of " + rest)
+			}
+			err = processInclude(data, name, ctx)
+			if err != nil {
+				return "", fmt.Errorf("IncludeError: %w", err)
+			}
+		}
+
+		// PAGEBREAK [condition]
+		// COLUMNBREAK [condition]
+		// SECTIONBREAK [type] [orientation] [condition]
+	} else if cmdName == "PAGEBREAK" || cmdName == "COLUMNBREAK" {
+		if !isLoopExploring(ctx) {
+			err := processBreak(data, ctx, cmdName, rest)
+			if err != nil {
+				return "", fmt.Errorf("BreakError: %w", err)
+			}
+		}
+	} else if cmdName == "SECTIONBREAK" {
+		if !isLoopExploring(ctx) {
+			err := processSectionBreak(data, ctx, rest)
+			if err != nil {
+				return "", fmt.Errorf("BreakError: %w", err)
+			}
+		}
+
+		// BOOKMARK <name>
+		// LINK-TO <bookmark> [label expression]
+		// PAGEREF <bookmark>
+	} else if cmdName == "BOOKMARK" || cmdName == "LINK-TO" || cmdName == "PAGEREF" {
+		if !isLoopExploring(ctx) {
+			var err error
+			switch cmdName {
+			case "BOOKMARK":
+				err = processBookmark(data, ctx, rest)
+			case "LINK-TO":
+				err = processLinkTo(data, ctx, rest)
+			default:
+				err = processPageRef(data, ctx, rest)
+			}
+			if err != nil {
+				return "", fmt.Errorf("BookmarkError: %w", err)
+			}
+		}
+
+		// FIELD <instruction>
+	} else if cmdName == "FIELD" {
+		if !isLoopExploring(ctx) {
+			err := processField(data, ctx, rest)
+			if err != nil {
+				return "", fmt.Errorf("FieldError: %w", err)
+			}
+		}
+	} else if cmdName == "REVISIONS" || cmdName == "COMMENT" || cmdName == "END-COMMENT" {
+		if !isLoopExploring(ctx) {
+			var err error
+			switch cmdName {
+			case "REVISIONS":
+				err = processRevisions(data, ctx, rest)
+			case "COMMENT":
+				err = processComment(data, ctx, rest)
+			default:
+				err = processEndComment(ctx)
+			}
+			if err != nil {
+				return "", fmt.Errorf("RevisionError: %w", err)
+			}
+		}
+	} else if cmdName == "CHECKBOX" {
+		if !isLoopExploring(ctx) {
+			glyph, err := processCheckbox(data, node, ctx, rest)
+			if err != nil {
+				return "", fmt.Errorf("CheckboxError: %w", err)
+			}
+			return glyph, nil
+		}
+	} else if cmdName == "RESTART-NUMBERING" {
+		if !isLoopExploring(ctx) {
+			ctx.numbering.scope++
+		}
+	} else if cmdName == "LIST" {
+		if !isLoopExploring(ctx) {
+			err := processList(data, ctx, rest)
+			if err != nil {
+				return "", fmt.Errorf("ListError: %w", err)
+			}
+		}
 	} else if cmdName == "HTML" {
 		if !isLoopExploring(ctx) {
 			varValue, err := runAndGetValue(rest, ctx, data)
@@ -821,6 +1180,27 @@ func processCmd(data *ReportData, node Node, ctx *Context) (string, error) {
 			return "", nil
 		}
 
+		// STYLE-CELL shade=<expression> color=<expression> bold=<expression> italic=<expression>
+		// STYLE-ROW (same as STYLE-CELL, plus height=<expression>)
+		// CELL-SHADE <expression>
+	} else if cmdName == "STYLE-CELL" || cmdName == "STYLE-ROW" || cmdName == "CELL-SHADE" {
+		if !isLoopExploring(ctx) {
+			err := processCellStyle(data, node, ctx, cmdName, rest)
+			if err != nil {
+				return "", fmt.Errorf("StyleError: %w", err)
+			}
+		}
+
+		// COLUMN-IF <expression>
+		// COLUMN-WIDTH <expression>
+	} else if cmdName == "COLUMN-IF" || cmdName == "COLUMN-WIDTH" {
+		if !isLoopExploring(ctx) {
+			err := processColumn(data, node, ctx, cmdName, rest)
+			if err != nil {
+				return "", fmt.Errorf("ColumnError: %w", err)
+			}
+		}
+
 		// CommandSyntaxError
 	} else {
 		return "", errors.New("CommandSyntaxError: " + cmd)
@@ -926,6 +1306,10 @@ func walkTemplate(data *ReportData, template Node, ctx *Context, processor Comma
 
 			// Execute removal, if needed. The node will no longer be part of the output, but
 			// the parent will be accessible from the child (so that we can still move up the tree)
+			if fRemoveNode && tag == TC_TAG {
+				// The grid of the table must be updated
+				registerColumn(ctx, nonTextNodeOut, &pendingColumn{})
+			}
 			if fRemoveNode && nodeOut.Parent() != nil {
 				nodeOut.Parent().PopChild()
 			}
@@ -992,6 +1376,75 @@ func walkTemplate(data *ReportData, template Node, ctx *Context, processor Comma
 				ctx.pendingLinkNode = nil
 			}
 
+			// If breaks or fields were generated, insert them in the parent `w:r` node, at the
+			// position of their commands
+			if ctx.pendingRunNodes != nil && isNotTextNode && nonTextNodeOut.Tag == R_TAG {
+				parent := nodeOut.Parent()
+				if parent != nil {
+					// pop last children
+					parent.PopChild()
+					for _, runNode := range splitRun(nonTextNodeOut, ctx.pendingRunNodes) {
+						runNode.SetParent(parent)
+						parent.AddChild(runNode)
+					}
+					// Prevent containing paragraph or table row from being removed
+					ctx.buffers[P_TAG].fInsertedText = true
+					ctx.buffers[TR_TAG].fInsertedText = true
+					ctx.buffers[TC_TAG].fInsertedText = true
+				}
+				ctx.pendingRunNodes = nil
+			}
+
+			// Bookmark the parent `w:p` node
+			if len(ctx.pendingBookmarks) > 0 && isNotTextNode && nonTextNodeOut.Tag == P_TAG {
+				for _, name := range ctx.pendingBookmarks {
+					ctx.bookmarkId++
+					addBookmark(nonTextNodeOut, name, fmt.Sprintf("new%d", ctx.bookmarkId))
+				}
+				// Prevent containing paragraph or table row from being removed
+				ctx.buffers[P_TAG].fInsertedText = true
+				ctx.buffers[TR_TAG].fInsertedText = true
+				ctx.buffers[TC_TAG].fInsertedText = true
+				ctx.pendingBookmarks = nil
+			}
+
+			// Set the legacy check boxes of the parent `w:p` node
+			if len(ctx.pendingFormCheckboxes) > 0 && isNotTextNode && nonTextNodeOut.Tag == P_TAG {
+				setFormCheckboxes(nonTextNodeOut, ctx.pendingFormCheckboxes)
+				ctx.pendingFormCheckboxes = nil
+			}
+
+			// If a section break was generated, end the section with the parent `w:p` node
+			if ctx.pendingSectPr != nil && isNotTextNode && nonTextNodeOut.Tag == P_TAG {
+				addSectionBreak(nonTextNodeOut, ctx.pendingSectPr)
+				// Prevent containing paragraph or table row from being removed
+				ctx.buffers[P_TAG].fInsertedText = true
+				ctx.buffers[TR_TAG].fInsertedText = true
+				ctx.buffers[TC_TAG].fInsertedText = true
+				ctx.pendingSectPr = nil
+			}
+
+			// If a list was generated, replace the parent `w:p` node with its items
+			if ctx.pendingList != nil && isNotTextNode && nonTextNodeOut.Tag == P_TAG {
+				parent := nodeOut.Parent()
+				if parent != nil {
+					// pop last children
+					parent.PopChild()
+					for _, listNode := range newListParagraphs(ctx.pendingList, nonTextNodeOut, ctx.numbering) {
+						listNode.SetParent(parent)
+						parent.AddChild(listNode)
+					}
+					// Prevent containing paragraph or table row from being removed
+					ctx.buffers[P_TAG].fInsertedText = true
+					ctx.buffers[TR_TAG].fInsertedText = true
+					ctx.buffers[TC_TAG].fInsertedText = true
+				}
+				ctx.pendingList = nil
+			} else if ctx.numbering.scope > 0 && isNotTextNode && nonTextNodeOut.Tag == P_TAG {
+				// Numbered paragraphs restart after RESTART-NUMBERING (see ProcessNumbering)
+				ctx.numbering.paragraphs[nonTextNodeOut] = ctx.numbering.scope
+			}
+
 			// If a html page was generated, replace the parent `w:p` node with
 			// the html node
 			if ctx.pendingHtmlNode != nil && isNotTextNode && nonTextNodeOut.Tag == P_TAG {
@@ -1010,6 +1463,36 @@ func walkTemplate(data *ReportData, template Node, ctx *Context, processor Comma
 				ctx.pendingHtmlNode = nil
 			}
 
+			// If a template was included, replace the parent `w:p` node with
+			// its content
+			if ctx.pendingIncludeNodes != nil && isNotTextNode && nonTextNodeOut.Tag == P_TAG {
+				parent := nodeOut.Parent()
+				if parent != nil {
+					// pop last children
+					parent.PopChild()
+					for _, includeNode := range ctx.pendingIncludeNodes {
+						includeNode.SetParent(parent)
+						parent.AddChild(includeNode)
+					}
+					// Prevent containing paragraph or table row from being removed
+					ctx.buffers[P_TAG].fInsertedText = true
+					ctx.buffers[TR_TAG].fInsertedText = true
+					ctx.buffers[TC_TAG].fInsertedText = true
+				}
+				ctx.pendingIncludeNodes = nil
+			}
+
+			// If a placeholder picture was bound to an image (or a chart to data), swap
+			// its content once the whole drawing has been generated
+			if isNotTextNode && ctx.pendingPlaceholderImage != nil && (nonTextNodeOut.Tag == INLINE_TAG || nonTextNodeOut.Tag == ANCHOR_TAG) {
+				applyPlaceholderImage(nonTextNodeOut, ctx.pendingPlaceholderImage)
+				ctx.pendingPlaceholderImage = nil
+			}
+			if isNotTextNode && ctx.pendingPlaceholderChart != nil && (nonTextNodeOut.Tag == INLINE_TAG || nonTextNodeOut.Tag == ANCHOR_TAG) {
+				bindPlaceholderChart(nonTextNodeOut, ctx.pendingPlaceholderChart, ctx)
+				ctx.pendingPlaceholderChart = nil
+			}
+
 			// `w:tc` nodes shouldn't be left with no `w:p` or 'w:altChunk' children; if that's the
 			// case, add an empty `w:p` inside
 			filterCase := slices.ContainsFunc(nodeOut.Children(), func(node Node) bool {
@@ -1020,6 +1503,22 @@ func walkTemplate(data *ReportData, template Node, ctx *Context, processor Comma
 				nodeOut.AddChild(NewNonTextNode(P_TAG, nil, nil))
 			}
 
+			// Apply the formatting requested by STYLE-CELL / STYLE-ROW once the
+			// cell (or row) has been completely generated, and record the
+			// COLUMN-IF / COLUMN-WIDTH settings for the table grid
+			if isNotTextNode && nonTextNodeOut.Tag == TC_TAG && ctx.pendingCellStyle != nil {
+				applyCellStyle(nonTextNodeOut, ctx.pendingCellStyle)
+				ctx.pendingCellStyle = nil
+			}
+			if isNotTextNode && nonTextNodeOut.Tag == TC_TAG && ctx.pendingColumn != nil {
+				registerColumn(ctx, nonTextNodeOut, ctx.pendingColumn)
+				ctx.pendingColumn = nil
+			}
+			if isNotTextNode && nonTextNodeOut.Tag == TR_TAG && ctx.pendingRowStyle != nil {
+				applyRowStyle(nonTextNodeOut, ctx.pendingRowStyle)
+				ctx.pendingRowStyle = nil
+			}
+
 			// Save latest `w:rPr` node that was visited (for LINK properties)
 			if isNotTextNode && nonTextNodeOut.Tag == RPR_TAG {
 				ctx.textRunPropsNode = nonTextNodeOut
@@ -1063,6 +1562,19 @@ func walkTemplate(data *ReportData, template Node, ctx *Context, processor Comma
 					slog.Debug("detected a - ", "newNode", debugPrintNode(newNode))
 					updateID(newNode.(*NonTextNode), ctx)
 				}
+				// Placeholder picture, with an IMAGE command as alt text
+				if !isLoopExploring(ctx) && newNodeTag == DOCPR_TAG {
+					err := processPlaceholderImage(data, newNode.(*NonTextNode), ctx)
+					if err == nil {
+						err = processPlaceholderChart(data, newNode.(*NonTextNode), ctx)
+					}
+					if err != nil {
+						if ctx.options.FailFast {
+							return nil, err
+						}
+						retErr = errors.Join(retErr, err)
+					}
+				}
 			}
 
 			// If it's a text node inside a w:t, process it
@@ -1077,6 +1589,11 @@ func walkTemplate(data *ReportData, template Node, ctx *Context, processor Comma
 					newNode.(*TextNode).Text = result
 					slog.Debug("Inserted command result string into node. Updated node: ", "node", debugPrintNode(newNode))
 				}
+				for _, pending := range ctx.pendingRunNodes {
+					if pending.text == nil {
+						pending.text = newNode.(*TextNode)
+					}
+				}
 			}
 			// Execute the move in the output tree
 			nodeOut = newNode
@@ -1117,15 +1634,119 @@ func walkTemplate(data *ReportData, template Node, ctx *Context, processor Comma
 		}
 	}
 
+	if len(ctx.openComments) > 0 {
+		retErr = errors.Join(retErr, errors.New("Unterminated COMMENT"))
+		if ctx.options.FailFast {
+			return nil, retErr
+		}
+	}
+
+	fixTableGrids(out, ctx.tableColumns)
+	fixSectionBreaks(out, ctx.sectionBreaks)
+	fixBookmarks(out)
+	fixRefFields(out, ctx.pendingRefs)
+
 	return &ReportOutput{
 		Report: out,
 		Images: ctx.images,
 		Links:  ctx.links,
 		Htmls:  ctx.htmls,
+		Charts: ctx.charts,
+
+		Includes:     ctx.includes,
+		ChartUpdates: ctx.chartUpdates,
+		Numbering:    ctx.numbering,
+		Revisions:    ctx.revisions,
 	}, retErr
 
 }
 
+// pendingRunNodes are the nodes generated by a command (breaks, fields...), inserted at the
+// position of the command in its `w:r`
+type pendingRunNodes struct {
+	text   *TextNode // output text of the command
+	offset int       // position of the command in the text
+	nodes  []Node
+}
+
+func addRunNodes(ctx *Context, nodes ...Node) {
+	ctx.pendingRunNodes = append(ctx.pendingRunNodes, &pendingRunNodes{offset: -1, nodes: nodes})
+}
+
+// splitRun returns the nodes replacing a `w:r`: the run is split around the generated nodes,
+// keeping its text before and after them with the same properties
+func splitRun(run *NonTextNode, pending []*pendingRunNodes) []Node {
+	result := []Node{}
+	rPr := findChild(run, RPR_TAG)
+	content := []Node{}
+	flush := func() {
+		if slices.ContainsFunc(content, func(child Node) bool {
+			t, ok := child.(*NonTextNode)
+			return !ok || t.Tag != T_TAG || getNodeText(t) != ""
+		}) {
+			piece := CloneNodeWithoutChildren(run)
+			piece.SetParent(nil)
+			if rPr != nil {
+				AddChild(piece, cloneNode(rPr))
+			}
+			for _, child := range content {
+				AddChild(piece, child)
+			}
+			result = append(result, piece)
+		}
+		content = []Node{}
+	}
+
+	inserted := map[*pendingRunNodes]bool{}
+	for _, child := range run.Children() {
+		if child == rPr {
+			continue
+		}
+		t, ok := child.(*NonTextNode)
+		var text *TextNode
+		if ok && t.Tag == T_TAG && len(t.Children()) == 1 {
+			text, _ = t.Children()[0].(*TextNode)
+		}
+		if text == nil || !slices.ContainsFunc(pending, func(p *pendingRunNodes) bool { return p.text == text }) {
+			content = append(content, child)
+			continue
+		}
+		start := 0
+		for _, p := range pending {
+			if p.text != text {
+				continue
+			}
+			offset := min(max(p.offset, start), len(text.Text))
+			content = append(content, newTextPiece(t, text.Text[start:offset]))
+			flush()
+			result = append(result, p.nodes...)
+			inserted[p] = true
+			start = offset
+		}
+		content = append(content, newTextPiece(t, text.Text[start:]))
+	}
+	flush()
+	// Nodes whose command couldn't be located (e.g. after an error) follow the run
+	for _, p := range pending {
+		if !inserted[p] {
+			result = append(result, p.nodes...)
+		}
+	}
+	return result
+}
+
+// newTextPiece returns a copy of the `w:t` node t with the given text
+func newTextPiece(t *NonTextNode, text string) *NonTextNode {
+	piece := CloneNodeWithoutChildren(t).(*NonTextNode)
+	piece.SetParent(nil)
+	if piece.Attrs == nil {
+		piece.Attrs = map[string]string{}
+	}
+	piece.Attrs["xml:space"] = "preserve"
+	AddChild(piece, NewTextNode(text))
+	return piece
+}
+
 func processText(data *ReportData, node *TextNode, ctx *Context, onCommand CommandProcessor) (string, error) {
 	cmdDelimiter := ctx.options.CmdDelimiter
 	failFast := ctx.options.FailFast
@@ -1171,6 +1792,11 @@ func processText(data *ReportData, node *TextNode, ctx *Context, onCommand Comma
 						"fInsertedText": true,
 					})
 				}
+				for _, pending := range ctx.pendingRunNodes {
+					if pending.text == nil && pending.offset < 0 {
+						pending.offset = len(outText)
+					}
+				}
 			}
 			ctx.fCmd = !ctx.fCmd
 		}
@@ -1236,6 +1862,9 @@ func NewContext(options CreateReportOptions, imageAndShapeIdIncrement int) Conte
 		"len":  length,
 		"join": join,
 	}
+	for k, v := range newBarcodeFunctions(options.Barcodes) {
+		builtin[k] = v
+	}
 	for k, v := range options.Functions {
 		builtin[k] = v
 	}
@@ -1265,8 +1894,20 @@ func NewContext(options CreateReportOptions, imageAndShapeIdIncrement int) Conte
 		shorthands:               map[string]string{},
 		options:                  options,
 		// To verfiy we don't have a nested if within the same p or tr tag
+		ifNames:      map[Node]string{},
 		pIfCheckMap:  map[Node]string{},
 		trIfCheckMap: map[Node]string{},
+		tableColumns: map[Node]*tableColumns{},
+
+		convertedImages: map[string]*Image{},
+		charts:          Charts{},
+		chartUpdates:    map[string]*ChartPars{},
+		includeData:     map[string][]byte{},
+		sectionBreaks:   map[*NonTextNode]*sectionBreak{},
+		seqCounters:     map[string]int{},
+		pendingRefs:     map[*TextNode]string{},
+		numbering:       newNumbering(),
+		revisions:       newRevisions(),
 	}
 
 }
@@ -0,0 +1,224 @@
+package godocx
+
+import (
+	"fmt"
+	"maps"
+	"math"
+	"path"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	PACKAGE_RELS_PATH = "_rels/.rels"
+
+	CORE_PROPERTIES_PATH          = "docProps/core.xml"
+	CORE_PROPERTIES_CONTENT_TYPE  = "application/vnd.openxmlformats-package.core-properties+xml"
+	CORE_PROPERTIES_RELATION_TYPE = "http://schemas.openxmlformats.org/package/2006/relationships/metadata/core-properties"
+
+	CUSTOM_PROPERTIES_PATH          = "docProps/custom.xml"
+	CUSTOM_PROPERTIES_CONTENT_TYPE  = "application/vnd.openxmlformats-officedocument.custom-properties+xml"
+	CUSTOM_PROPERTIES_RELATION_TYPE = "http://schemas.openxmlformats.org/officeDocument/2006/relationships/custom-properties"
+	CUSTOM_PROPERTIES_NAMESPACE     = "http://schemas.openxmlformats.org/officeDocument/2006/custom-properties"
+	VTYPES_NAMESPACE                = "http://schemas.openxmlformats.org/officeDocument/2006/docPropsVTypes"
+	// Format id of the user defined properties
+	CUSTOM_PROPERTY_FMTID = "{D5CDD505-2E9C-101B-9397-08002B2CF9AE}"
+)
+
+var (
+	// Elements of the core properties, by name (CoreProperties option)
+	CoreProperties = map[string]string{
+		"title":          "dc:title",
+		"subject":        "dc:subject",
+		"author":         "dc:creator",
+		"creator":        "dc:creator",
+		"keywords":       "cp:keywords",
+		"description":    "dc:description",
+		"category":       "cp:category",
+		"contentstatus":  "cp:contentStatus",
+		"identifier":     "dc:identifier",
+		"language":       "dc:language",
+		"lastmodifiedby": "cp:lastModifiedBy",
+		"lastprinted":    "cp:lastPrinted",
+		"revision":       "cp:revision",
+		"version":        "cp:version",
+		"created":        "dcterms:created",
+		"modified":       "dcterms:modified",
+	}
+	CORE_PROPERTIES_NAMESPACES = map[string]string{
+		"xmlns:cp":       "http://schemas.openxmlformats.org/package/2006/metadata/core-properties",
+		"xmlns:dc":       "http://purl.org/dc/elements/1.1/",
+		"xmlns:dcterms":  "http://purl.org/dc/terms/",
+		"xmlns:dcmitype": "http://purl.org/dc/dcmitype/",
+		"xmlns:xsi":      "http://www.w3.org/2001/XMLSchema-instance",
+	}
+)
+
+// SetProperties sets the core and custom properties of the report (CoreProperties and
+// CustomProperties options) from the data, creating their parts if missing
+func SetProperties(data *ReportData, ctx *Context, zip *ZipArchive, contentTypes *NonTextNode) error {
+	coreProperties := ctx.options.CoreProperties
+	customProperties := ctx.options.CustomProperties
+	if len(coreProperties) == 0 && len(customProperties) == 0 {
+		return nil
+	}
+	xmlOptions := XmlOptions{LiteralXmlDelimiter: DEFAULT_LITERAL_XML_DELIMITER}
+	rels, err := getRelsFromZip(zip, PACKAGE_RELS_PATH)
+	if err != nil {
+		return err
+	}
+
+	if len(coreProperties) > 0 {
+		core, corePath, err := loadPropertiesPart(rels, zip, contentTypes, CORE_PROPERTIES_RELATION_TYPE, CORE_PROPERTIES_PATH, CORE_PROPERTIES_CONTENT_TYPE,
+			NewNonTextNode("cp:coreProperties", maps.Clone(CORE_PROPERTIES_NAMESPACES), nil))
+		if err != nil {
+			return err
+		}
+		// Sorted, for a stable order of the new elements
+		for _, name := range slices.Sorted(maps.Keys(coreProperties)) {
+			tag, ok := CoreProperties[strings.ToLower(name)]
+			if !ok {
+				return fmt.Errorf("Unknown core property: %s", name)
+			}
+			value, err := runAndGetValue(coreProperties[name], ctx, data)
+			if err != nil {
+				return fmt.Errorf("%s: %w", name, err)
+			}
+			setCoreProperty(core, tag, value)
+		}
+		zip.SetFile(corePath, BuildXml(core, xmlOptions, ""))
+	}
+
+	if len(customProperties) > 0 {
+		custom, customPath, err := loadPropertiesPart(rels, zip, contentTypes, CUSTOM_PROPERTIES_RELATION_TYPE, CUSTOM_PROPERTIES_PATH, CUSTOM_PROPERTIES_CONTENT_TYPE,
+			NewNonTextNode("Properties", map[string]string{"xmlns": CUSTOM_PROPERTIES_NAMESPACE, "xmlns:vt": VTYPES_NAMESPACE}, nil))
+		if err != nil {
+			return err
+		}
+		for _, name := range slices.Sorted(maps.Keys(customProperties)) {
+			value, err := runAndGetValue(customProperties[name], ctx, data)
+			if err != nil {
+				return fmt.Errorf("%s: %w", name, err)
+			}
+			setCustomProperty(custom, name, value)
+		}
+		zip.SetFile(customPath, BuildXml(custom, xmlOptions, ""))
+	}
+
+	zip.SetFile(PACKAGE_RELS_PATH, BuildXml(rels, xmlOptions, ""))
+	return nil
+}
+
+// loadPropertiesPart returns a part of the package and its path, creating it from root if missing
+func loadPropertiesPart(rels Node, zip *ZipArchive, contentTypes *NonTextNode, relType string, defaultPath string, contentType string, root *NonTextNode) (*NonTextNode, string, error) {
+	for _, rel := range findChildren(rels, "Relationship") {
+		if rel.Attrs["Type"] == relType {
+			partPath := resolveRelTarget("", rel.Attrs["Target"])
+			part, err := parsePath(zip, partPath)
+			return part, partPath, err
+		}
+	}
+	AddChild(rels, NewNonTextNode("Relationship", map[string]string{
+		"Id":     uniqueRelId(rels, strings.TrimSuffix(path.Base(defaultPath), ".xml")),
+		"Type":   relType,
+		"Target": defaultPath,
+	}, nil))
+	AddChild(contentTypes, NewNonTextNode("Override", map[string]string{
+		"PartName":    "/" + defaultPath,
+		"ContentType": contentType,
+	}, nil))
+	return root, defaultPath, nil
+}
+
+func setCoreProperty(core *NonTextNode, tag string, value VarValue) {
+	var attrs map[string]string
+	text := formatPropertyValue(value)
+	if date, ok := value.(time.Time); ok {
+		// W3CDTF, as the dates of the properties are (e.g. cp:lastPrinted)
+		text = date.UTC().Format(time.RFC3339)
+	}
+	if strings.HasPrefix(tag, "dcterms:") {
+		attrs = map[string]string{"xsi:type": "dcterms:W3CDTF"}
+		if _, ok := core.Attrs["xmlns:xsi"]; !ok {
+			core.Attrs["xmlns:xsi"] = CORE_PROPERTIES_NAMESPACES["xmlns:xsi"]
+		}
+	}
+	if existing := findChild(core, tag); existing != nil {
+		existing.SetChildren(nil)
+		AddChild(existing, NewTextNode(text))
+		return
+	}
+	AddChild(core, NewNonTextNode(tag, attrs, []Node{NewTextNode(text)}))
+}
+
+func setCustomProperty(custom *NonTextNode, name string, value VarValue) {
+	node := NewNonTextNode
+	var typedValue *NonTextNode
+	switch v := value.(type) {
+	case bool:
+		typedValue = node("vt:bool", nil, []Node{NewTextNode(strconv.FormatBool(v))})
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+		typedValue = node(integerPropertyType(v), nil, []Node{NewTextNode(fmt.Sprint(v))})
+	case float32, float64:
+		typedValue = node("vt:r8", nil, []Node{NewTextNode(fmt.Sprint(v))})
+	case time.Time:
+		typedValue = node("vt:filetime", nil, []Node{NewTextNode(v.UTC().Format(time.RFC3339))})
+	default:
+		typedValue = node("vt:lpwstr", nil, []Node{NewTextNode(formatPropertyValue(value))})
+	}
+
+	properties := findChildren(custom, "property")
+	for _, property := range properties {
+		if property.Attrs["name"] == name {
+			typedValue.SetParent(property)
+			property.SetChildren([]Node{typedValue})
+			return
+		}
+	}
+	// Property ids start at 2
+	pid := 1
+	for _, property := range properties {
+		pid = max(pid, getIntAttr(property, "pid"))
+	}
+	AddChild(custom, node("property", map[string]string{
+		"fmtid": CUSTOM_PROPERTY_FMTID,
+		"pid":   fmt.Sprint(pid + 1),
+		"name":  name,
+	}, []Node{typedValue}))
+}
+
+// integerPropertyType returns the smallest variant type holding an integer value
+func integerPropertyType(value VarValue) string {
+	reflected := reflect.ValueOf(value)
+	if reflected.CanUint() {
+		if n := reflected.Uint(); n > math.MaxInt64 {
+			return "vt:ui8"
+		} else if n > math.MaxInt32 {
+			return "vt:i8"
+		}
+		return "vt:i4"
+	}
+	if n := reflected.Int(); n < math.MinInt32 || n > math.MaxInt32 {
+		return "vt:i8"
+	}
+	return "vt:i4"
+}
+
+// formatPropertyValue returns the text of a property: arrays (e.g. keywords) are joined with commas
+func formatPropertyValue(value VarValue) string {
+	if value == nil {
+		return ""
+	}
+	reflected := reflect.ValueOf(value)
+	if reflected.Kind() == reflect.Slice {
+		values := make([]string, reflected.Len())
+		for i := range reflected.Len() {
+			values[i] = fmt.Sprint(reflected.Index(i).Interface())
+		}
+		return strings.Join(values, ", ")
+	}
+	return fmt.Sprint(value)
+}
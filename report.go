@@ -4,7 +4,9 @@ import (
 	"bytes"
 	"fmt"
 	"log/slog"
+	"maps"
 	"slices"
+	"strings"
 )
 
 // CreateReport generates a report document based on a given template and data.
@@ -19,51 +21,88 @@ import (
 //   - A byte slice representing the generated document.
 //   - An error if any occurs during template parsing, processing, or document generation.
 func CreateReport(templatePath string, data *ReportData, options CreateReportOptions) ([]byte, error) {
-
-	outBuffer := new(bytes.Buffer)
-	zip, err := NewZipArchive(templatePath, outBuffer)
+	template, err := NewTemplate(templatePath, options)
 	if err != nil {
 		return nil, err
 	}
+	return template.Render(data)
+}
 
-	// xml parse the document
-	parseResult, err := ParseTemplate(zip)
+// Render generates a report from the template and data
+func (t *Template) Render(data *ReportData) ([]byte, error) {
+	options := t.options
+	outBuffer := new(bytes.Buffer)
+	zip, err := NewZipArchiveFromBytes(t.data, outBuffer)
 	if err != nil {
-		return nil, fmt.Errorf("ParseTemplate failed: %w", err)
-	}
-
-	if options.CmdDelimiter == nil {
-		options.CmdDelimiter = &Delimiters{
-			Open:  DEFAULT_CMD_DELIMITER,
-			Close: DEFAULT_CMD_DELIMITER,
-		}
+		return nil, err
 	}
-	if options.LiteralXmlDelimiter == "" {
-		options.LiteralXmlDelimiter = DEFAULT_LITERAL_XML_DELIMITER
+	// The template is shared by the reports: the parts that are completed are copied
+	parseResult := &ParseTemplateResult{
+		Root:         t.root,
+		MainDocument: t.mainDocument,
+		Zip:          zip,
+		ContentTypes: cloneNode(t.contentTypes).(*NonTextNode),
+		Extras:       t.extras,
 	}
-
 	xmlOptions := XmlOptions{
 		LiteralXmlDelimiter: options.LiteralXmlDelimiter,
 	}
 
-	preppedTemplate, err := PreprocessTemplate(parseResult.Root, *options.CmdDelimiter)
+	result, err := ProduceReport(data, t.root, NewContext(options, 73086257))
+	//TODO ^ max id
 	if err != nil {
-		return nil, fmt.Errorf("PreprocessTemplate failed: %w", err)
+		return nil, fmt.Errorf("ProduceReport failed: %w", err)
 	}
 
-	result, err := ProduceReport(data, preppedTemplate, NewContext(options, 73086257))
-	//TODO ^ max id
+	err = ProcessIncludes(result.Includes, result.Report, result.Images, parseResult.MainDocument, parseResult.Zip, parseResult.ContentTypes)
 	if err != nil {
-		return nil, fmt.Errorf("ProduceReport failed: %w", err)
+		return nil, fmt.Errorf("ProcessIncludes failed: %w", err)
+	}
+	if options.ContentControls {
+		err = FillContentControls(result.Report, data, options.UnwrapContentControls)
+		if err != nil {
+			return nil, fmt.Errorf("FillContentControls failed: %w", err)
+		}
+	}
+	var customXmlParts CustomXmlParts
+	if len(options.CustomXml) > 0 {
+		ctx := NewContext(options, 0)
+		customXmlParts, err = PopulateCustomXml(data, &ctx, parseResult.MainDocument, parseResult.Zip)
+		if err != nil {
+			return nil, fmt.Errorf("PopulateCustomXml failed: %w", err)
+		}
+		RefreshDataBindings(result.Report, customXmlParts)
+	}
+	err = ProcessNumbering(result.Numbering, result.Report, parseResult.MainDocument, parseResult.Zip, parseResult.ContentTypes)
+	if err != nil {
+		return nil, fmt.Errorf("ProcessNumbering failed: %w", err)
+	}
+	if options.CleanTemplate {
+		err = ClearComments(parseResult.MainDocument, parseResult.Zip)
+		if err != nil {
+			return nil, fmt.Errorf("ClearComments failed: %w", err)
+		}
+	}
+	err = ProcessRevisions(result.Revisions, result.Report, parseResult.MainDocument, parseResult.Zip, parseResult.ContentTypes)
+	if err != nil {
+		return nil, fmt.Errorf("ProcessRevisions failed: %w", err)
+	}
+	if options.UpdateToc {
+		err = UpdateToc(result.Report, parseResult.MainDocument, parseResult.Zip)
+		if err != nil {
+			return nil, fmt.Errorf("UpdateToc failed: %w", err)
+		}
 	}
 
 	newXml := BuildXml(result.Report, xmlOptions, "")
 
 	slog.Debug("Writing report...")
-	zip.SetFile("word/document.xml", newXml)
+	zip.SetFile(fmt.Sprintf("%s/%s", TEMPLATE_PATH, parseResult.MainDocument), newXml)
 
 	numImages := len(result.Images)
 	numHtmls := len(result.Htmls)
+	numCharts := len(result.Charts)
+	numIncludes := len(result.Includes)
 	err = ProcessImages(result.Images, parseResult.MainDocument, parseResult.Zip)
 	if err != nil {
 		return nil, fmt.Errorf("ProcessImages failed: %w", err)
@@ -76,23 +115,90 @@ func CreateReport(templatePath string, data *ReportData, options CreateReportOpt
 	if err != nil {
 		return nil, fmt.Errorf("ProcessLinks failed: %w", err)
 	}
+	if len(options.ChartsByTitle) > 0 {
+		ctx := NewContext(options, 0)
+		err = bindChartsByTitle(data, &ctx, parseResult.MainDocument, parseResult.Zip, result.ChartUpdates)
+		if err != nil {
+			return nil, fmt.Errorf("ChartsByTitle failed: %w", err)
+		}
+	}
+	err = UpdateCharts(result.ChartUpdates, parseResult.MainDocument, parseResult.Zip)
+	if err != nil {
+		return nil, fmt.Errorf("UpdateCharts failed: %w", err)
+	}
+	err = ProcessCharts(result.Charts, parseResult.MainDocument, parseResult.Zip, parseResult.ContentTypes)
+	if err != nil {
+		return nil, fmt.Errorf("ProcessCharts failed: %w", err)
+	}
 
-	// Additionals headers and footers
-	for extraPath, extraNode := range parseResult.Extras {
-		prepped, err := PreprocessTemplate(extraNode, *options.CmdDelimiter)
+	if options.UpdateFields {
+		err = SetUpdateFields(parseResult.MainDocument, parseResult.Zip, parseResult.ContentTypes)
 		if err != nil {
-			return nil, fmt.Errorf("PreprocessTemplate failed: %w", err)
+			return nil, fmt.Errorf("SetUpdateFields failed: %w", err)
 		}
-		r, err := ProduceReport(data, prepped, NewContext(options, 73086257))
+	}
+
+	if len(options.CoreProperties) > 0 || len(options.CustomProperties) > 0 {
+		ctx := NewContext(options, 0)
+		err = SetProperties(data, &ctx, parseResult.Zip, parseResult.ContentTypes)
+		if err != nil {
+			return nil, fmt.Errorf("SetProperties failed: %w", err)
+		}
+	}
+
+	// Additionals headers and footers (in a fixed order, as they add numbering definitions)
+	hasNumbering := !result.Numbering.isEmpty()
+	for _, extraPath := range slices.Sorted(maps.Keys(parseResult.Extras)) {
+		extraComponent := strings.TrimPrefix(extraPath, TEMPLATE_PATH+"/")
+		r, err := ProduceReport(data, parseResult.Extras[extraPath], NewContext(options, 73086257))
 		if err != nil {
 			return nil, fmt.Errorf("ProduceReport failed: %w", err)
 		}
+		if options.ContentControls {
+			err = FillContentControls(r.Report, data, options.UnwrapContentControls)
+			if err != nil {
+				return nil, fmt.Errorf("FillContentControls failed: %w", err)
+			}
+		}
+		// Word doesn't allow comments in headers and footers; their tracked changes are
+		// numbered in the part
+		if len(r.Revisions.comments) > 0 {
+			return nil, fmt.Errorf("COMMENT is not supported in %s, only in the document body", extraPath)
+		}
+		err = ProcessRevisions(r.Revisions, r.Report, extraComponent, parseResult.Zip, parseResult.ContentTypes)
+		if err != nil {
+			return nil, fmt.Errorf("ProcessRevisions failed: %w", err)
+		}
+		// The numbering definitions are shared with the main document
+		hasNumbering = hasNumbering || !r.Numbering.isEmpty()
+		err = ProcessNumbering(r.Numbering, r.Report, parseResult.MainDocument, parseResult.Zip, parseResult.ContentTypes)
+		if err != nil {
+			return nil, fmt.Errorf("ProcessNumbering failed: %w", err)
+		}
+		RefreshDataBindings(r.Report, customXmlParts)
 		extraXml := BuildXml(r.Report, xmlOptions, "")
 		slog.Debug(fmt.Sprintf("Writing %s...", extraPath))
 		zip.SetFile(extraPath, extraXml)
+
+		// The images and links of a header or footer are related by its own rels part
+		numImages += len(r.Images)
+		numHtmls += len(r.Htmls)
+		err = ProcessImages(r.Images, extraComponent, parseResult.Zip)
+		if err != nil {
+			return nil, fmt.Errorf("ProcessImages failed: %w", err)
+		}
+		err = ProcessHtmls(r.Htmls, extraComponent, parseResult.Zip)
+		if err != nil {
+			return nil, fmt.Errorf("ProcessHtmls failed: %w", err)
+		}
+		err = ProcessLinks(r.Links, extraComponent, parseResult.Zip)
+		if err != nil {
+			return nil, fmt.Errorf("ProcessLinks failed: %w", err)
+		}
 	}
 
-	if numHtmls > 0 || numImages > 0 {
+	if numHtmls > 0 || numImages > 0 || numCharts > 0 || numIncludes > 0 || hasNumbering || !result.Revisions.isEmpty() || options.UpdateFields ||
+		len(options.CoreProperties) > 0 || len(options.CustomProperties) > 0 {
 		slog.Debug("Completing [Content_Types].xml...")
 
 		contentTypes := parseResult.ContentTypes
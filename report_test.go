@@ -3,14 +3,35 @@ package godocx
 import (
 	"archive/zip"
 	"bytes"
+	"errors"
 	"fmt"
+	"image"
+	"image/jpeg"
+	"image/png"
 	"io"
+	"maps"
 	"os"
+	"regexp"
+	"slices"
 	"strings"
+	"sync"
 	"testing"
+	"time"
+
+	"golang.org/x/image/bmp"
 )
 
+// 50x50 pixels PNG image
+var testPngData = []byte{
+	137, 80, 78, 71, 13, 10, 26, 10, 0, 0, 0, 13, 73, 72, 68, 82, 0, 0, 0, 50, 0, 0, 0, 50, 8, 2, 0, 0, 0, 145, 93, 31, 230, 0, 0, 0, 30, 73, 68, 65, 84, 120, 156, 237, 193, 49, 1, 0, 0, 0, 194, 160, 245, 79, 109, 8, 95, 160, 0, 0, 0, 0, 0, 0, 248, 13, 29, 126, 0, 1, 10, 82, 239, 54, 0, 0, 0, 0, 73, 69, 78, 68, 174, 66, 96, 130,
+}
+
 func createTestDocx(content []byte, filename string) error {
+	return createTestDocxWithFiles(content, nil, filename)
+}
+
+// createTestDocxWithFiles creates a test template with additional parts (which may replace the default ones)
+func createTestDocxWithFiles(content []byte, extraFiles map[string][]byte, filename string) error {
 	// Create a buffer to write our archive to.
 	buf := new(bytes.Buffer)
 
@@ -34,6 +55,9 @@ func createTestDocx(content []byte, filename string) error {
 		<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">
 		</Relationships>`),
 	}
+	for name, content := range extraFiles {
+		files[name] = content
+	}
 
 	for name, content := range files {
 		f, err := w.Create(name)
@@ -85,6 +109,36 @@ func verifyDocxContent(t *testing.T, filename string, verifyFn func([]byte) erro
 	}
 }
 
+// checkNoteReferences returns the text of the notes (footnotes, comments...) by the text of the
+// paragraphs referencing them, checking that each note is referenced once
+func checkNoteReferences(t *testing.T, files map[string]string, notesPath string, noteTag string, refTag string) map[string]string {
+	t.Helper()
+	document, err := ParseXml(files["word/document.xml"])
+	if err != nil {
+		t.Fatalf("Failed to parse output: %v", err)
+	}
+	notesRoot, err := ParseXml(files[notesPath])
+	if err != nil {
+		t.Fatalf("Failed to parse %s: %v", notesPath, err)
+	}
+	notes := map[string]string{}
+	for _, note := range findChildren(notesRoot, noteTag) {
+		if note.Attrs["w:type"] == "" {
+			notes[note.Attrs["w:id"]] = strings.TrimSpace(getNodeText(note))
+		}
+	}
+	texts := map[string]string{}
+	for _, ref := range findDescendants(document, refTag) {
+		text, ok := notes[ref.Attrs["w:id"]]
+		if !ok {
+			t.Errorf("Missing note %s in %s", ref.Attrs["w:id"], files[notesPath])
+		}
+		delete(notes, ref.Attrs["w:id"])
+		texts[getParagraphText(findAncestor(ref, P_TAG))] = text
+	}
+	return texts
+}
+
 func TestCreateReport(t *testing.T) {
 	// Test basic data processing
 	t.Run("basic data processing", func(t *testing.T) {
@@ -138,9 +192,7 @@ func TestCreateReport(t *testing.T) {
 
 	// Test image processing
 	t.Run("image processing", func(t *testing.T) {
-		imageData := []byte{
-			137, 80, 78, 71, 13, 10, 26, 10, 0, 0, 0, 13, 73, 72, 68, 82, 0, 0, 0, 50, 0, 0, 0, 50, 8, 2, 0, 0, 0, 145, 93, 31, 230, 0, 0, 0, 30, 73, 68, 65, 84, 120, 156, 237, 193, 49, 1, 0, 0, 0, 194, 160, 245, 79, 109, 8, 95, 160, 0, 0, 0, 0, 0, 0, 248, 13, 29, 126, 0, 1, 10, 82, 239, 54, 0, 0, 0, 0, 73, 69, 78, 68, 174, 66, 96, 130,
-		}
+		imageData := testPngData
 		data := ReportData{
 			"img": &ImagePars{
 				Width:     5,
@@ -289,6 +341,67 @@ func TestCreateReport(t *testing.T) {
 		})
 	})
 
+	// Test user functions
+	t.Run("user functions", func(t *testing.T) {
+		data := ReportData{
+			"name": "John",
+			"age":  42,
+		}
+
+		templateContent := []byte(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+		<w:document xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main">
+			<w:body>
+				<w:p>
+					<w:r>
+						<w:t>+++upper(name)+++</w:t>
+					</w:r>
+				</w:p>
+			</w:body>
+		</w:document>`)
+		err := createTestDocx(templateContent, "test_template_functions.docx")
+		if err != nil {
+			t.Fatalf("Failed to create test template: %v", err)
+		}
+		defer os.Remove("test_template_functions.docx")
+
+		options := CreateReportOptions{
+			LiteralXmlDelimiter: "||",
+			Functions: Functions{
+				"upper": func(args ...any) VarValue {
+					s, ok := args[0].(string)
+					if !ok {
+						return errors.New("upper: not a string")
+					}
+					return strings.ToUpper(s)
+				},
+			},
+		}
+
+		outBuf, err := CreateReport("test_template_functions.docx", &data, options)
+		if err != nil {
+			t.Fatalf("CreateReport failed: %v", err)
+		}
+		os.WriteFile("test_output_functions.docx", outBuf, 0644)
+		defer os.Remove("test_output_functions.docx")
+		verifyDocxContent(t, "test_output_functions.docx", func(documentXml []byte) error {
+			if !bytes.Contains(documentXml, []byte("JOHN")) {
+				return fmt.Errorf("Generated document does not contain the result of the function: JOHN")
+			}
+			return nil
+		})
+
+		// A function returning an error fails the command
+		templateContent = bytes.Replace(templateContent, []byte("upper(name)"), []byte("upper(age)"), 1)
+		err = createTestDocx(templateContent, "test_template_functions.docx")
+		if err != nil {
+			t.Fatalf("Failed to create test template: %v", err)
+		}
+		_, err = CreateReport("test_template_functions.docx", &data, options)
+		if err == nil || !strings.Contains(err.Error(), "upper: not a string") {
+			t.Errorf("Expected the error of the function, got %v", err)
+		}
+	})
+
 	// Test FOR loop processing
 	t.Run("for loop processing", func(t *testing.T) {
 		data := ReportData{
@@ -569,4 +682,2845 @@ func TestCreateReport(t *testing.T) {
 		})
 	})
 
+	// Test STYLE-CELL / CELL-SHADE processing
+	t.Run("cell style processing", func(t *testing.T) {
+		data := ReportData{
+			"rows": []any{
+				map[string]any{"name": "Late", "overdue": true, "note": "it's late"},
+				map[string]any{"name": "On time", "overdue": false, "note": ""},
+			},
+		}
+
+		templateContent := []byte(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+		<w:document xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main">
+			<w:body>
+				<w:tbl>
+					<w:tr><w:tc><w:p><w:r><w:t>+++FOR row IN rows+++</w:t></w:r></w:p></w:tc></w:tr>
+					<w:tr>
+						<w:tc>
+							<w:tcPr><w:tcW w:w="2000" w:type="dxa"/><w:vAlign w:val="center"/></w:tcPr>
+							<w:p><w:r><w:t>+++STYLE-CELL shade=$row.overdue ? 'FF0000' : '' bold=$row.overdue italic=$row.note == "it's late" color='#0000ff'++++++INS $row.name+++</w:t></w:r></w:p>
+						</w:tc>
+						<w:tc>
+							<w:p><w:r><w:t>+++CELL-SHADE '#00ff00'+++</w:t></w:r></w:p>
+						</w:tc>
+					</w:tr>
+					<w:tr><w:tc><w:p><w:r><w:t>+++END-FOR row+++</w:t></w:r></w:p></w:tc></w:tr>
+				</w:tbl>
+			</w:body>
+		</w:document>`)
+		err := createTestDocx(templateContent, "test_template_cellstyle.docx")
+		if err != nil {
+			t.Fatalf("Failed to create test template: %v", err)
+		}
+		defer os.Remove("test_template_cellstyle.docx")
+
+		options := CreateReportOptions{
+			LiteralXmlDelimiter: "||",
+		}
+
+		outBuf, err := CreateReport("test_template_cellstyle.docx", &data, options)
+		if err != nil {
+			t.Fatalf("CreateReport failed: %v", err)
+		}
+
+		os.WriteFile("test_output_cellstyle.docx", outBuf, 0644)
+		defer os.Remove("test_output_cellstyle.docx")
+
+		verifyDocxContent(t, "test_output_cellstyle.docx", func(documentXml []byte) error {
+			if count := bytes.Count(documentXml, []byte(`w:fill="FF0000"`)); count != 1 {
+				return fmt.Errorf("Expected 1 red cell, found %d", count)
+			}
+			if count := bytes.Count(documentXml, []byte(`w:fill="00FF00"`)); count != 2 {
+				return fmt.Errorf("Expected 2 green cells, found %d", count)
+			}
+			// w:shd must be placed between w:tcW and w:vAlign
+			tcPr := regexp.MustCompile(`(?s)<w:tcPr>.*?</w:tcPr>`).Find(documentXml)
+			if !regexp.MustCompile(`(?s)w:tcW.*w:shd.*w:vAlign`).Match(tcPr) {
+				return fmt.Errorf("Cell properties out of order: %s", tcPr)
+			}
+			for _, val := range []string{"Late", "On time", `<w:b w:val="true"/>`, `<w:b w:val="false"/>`, `<w:i w:val="true"/>`, `<w:i w:val="false"/>`, `<w:color w:val="0000FF"/>`} {
+				if !bytes.Contains(documentXml, []byte(val)) {
+					return fmt.Errorf("Generated document does not contain expected value: %s", val)
+				}
+			}
+			return nil
+		})
+
+		// Colors must be 6 hexadecimal digits or auto
+		for _, color := range []string{"'red'", "'#FF00'", `'FF0000" w:val="1'`} {
+			invalidTemplate := bytes.Replace(templateContent, []byte("'#00ff00'"), []byte(color), 1)
+			err = createTestDocx(invalidTemplate, "test_template_cellstyle.docx")
+			if err != nil {
+				t.Fatalf("Failed to create test template: %v", err)
+			}
+			_, err = CreateReport("test_template_cellstyle.docx", &data, options)
+			if err == nil || !strings.Contains(err.Error(), "Invalid color") {
+				t.Errorf("Expected an invalid color error for %s, got %v", color, err)
+			}
+		}
+	})
+
+	// Test column loops and COLUMN-IF
+	t.Run("table grid processing", func(t *testing.T) {
+		data := ReportData{
+			"columns":     []any{"A", "B", "C"},
+			"hasDiscount": false,
+		}
+
+		templateContent := []byte(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+		<w:document xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main">
+			<w:body>
+				<w:tbl>
+					<w:tblGrid><w:gridCol w:w="3000"/><w:gridCol w:w="3000"/><w:gridCol w:w="3000"/><w:gridCol w:w="3000"/></w:tblGrid>
+					<w:tr>
+						<w:tc><w:p><w:r><w:t>Name</w:t></w:r></w:p></w:tc>
+						<w:tc><w:p><w:r><w:t>+++FOR column IN columns+++</w:t></w:r></w:p></w:tc>
+						<w:tc><w:p><w:r><w:t>+++INS $column+++</w:t></w:r></w:p></w:tc>
+						<w:tc><w:p><w:r><w:t>+++END-FOR column+++</w:t></w:r></w:p></w:tc>
+						<w:tc><w:p><w:r><w:t>+++COLUMN-IF hasDiscount+++Discount</w:t></w:r></w:p></w:tc>
+					</w:tr>
+				</w:tbl>
+				<w:tbl>
+					<w:tblGrid><w:gridCol w:w="2000"/><w:gridCol w:w="3000"/><w:gridCol w:w="4000"/></w:tblGrid>
+					<w:tr>
+						<w:tc><w:tcPr><w:tcW w:w="2000" w:type="dxa"/></w:tcPr><w:p><w:r><w:t>Short</w:t></w:r></w:p></w:tc>
+						<w:tc><w:tcPr><w:tcW w:w="3000" w:type="dxa"/></w:tcPr><w:p><w:r><w:t>Row</w:t></w:r></w:p></w:tc>
+					</w:tr>
+				</w:tbl>
+			</w:body>
+		</w:document>`)
+		err := createTestDocx(templateContent, "test_template_grid.docx")
+		if err != nil {
+			t.Fatalf("Failed to create test template: %v", err)
+		}
+		defer os.Remove("test_template_grid.docx")
+
+		options := CreateReportOptions{
+			LiteralXmlDelimiter: "||",
+		}
+
+		outBuf, err := CreateReport("test_template_grid.docx", &data, options)
+		if err != nil {
+			t.Fatalf("CreateReport failed: %v", err)
+		}
+
+		os.WriteFile("test_output_grid.docx", outBuf, 0644)
+		defer os.Remove("test_output_grid.docx")
+
+		verifyDocxContent(t, "test_output_grid.docx", func(documentXml []byte) error {
+			if bytes.Contains(documentXml, []byte("Discount")) {
+				return fmt.Errorf("Generated document contains hidden column")
+			}
+			if count := bytes.Count(documentXml, []byte("<w:tc>")); count != 4+2 {
+				return fmt.Errorf("Expected 4 cells (and 2 in the short row table), found %d", count)
+			}
+			if count := bytes.Count(documentXml, []byte(`<w:gridCol w:w="3000"/>`)); count != 5 {
+				return fmt.Errorf("Expected 4 grid columns of 3000 (and one of the short row table), found %d", count)
+			}
+			// Without column commands, a table with rows shorter than its grid keeps it
+			for _, val := range []string{`<w:gridCol w:w="2000"/>`, `<w:gridCol w:w="4000"/>`} {
+				if !bytes.Contains(documentXml, []byte(val)) {
+					return fmt.Errorf("Generated document does not contain expected value: %s", val)
+				}
+			}
+			if bytes.Contains(documentXml, []byte(`w:w="4500"`)) {
+				return fmt.Errorf("Short row table was split evenly")
+			}
+			return nil
+		})
+	})
+
+	// Test image sizing modes
+	t.Run("image sizing", func(t *testing.T) {
+		data := ReportData{
+			"natural":   &ImagePars{Data: testPngData, Extension: ".png"},
+			"widthOnly": &ImagePars{Data: testPngData, Extension: ".png", Width: 4},
+			"boxed":     &ImagePars{Data: testPngData, Extension: ".png", Width: 10, Height: 5, MaxWidth: 2},
+			"svg": &ImagePars{
+				Data:      []byte(`<svg xmlns="http://www.w3.org/2000/svg" width="2cm" viewBox="0 0 200 100"></svg>`),
+				Extension: ".svg",
+			},
+		}
+
+		templateContent := []byte(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+		<w:document xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main">
+			<w:body>
+				<w:p><w:r><w:t>+++IMAGE natural+++</w:t></w:r></w:p>
+				<w:p><w:r><w:t>+++IMAGE widthOnly+++</w:t></w:r></w:p>
+				<w:p><w:r><w:t>+++IMAGE boxed+++</w:t></w:r></w:p>
+				<w:p><w:r><w:t>+++IMAGE svg+++</w:t></w:r></w:p>
+			</w:body>
+		</w:document>`)
+		err := createTestDocx(templateContent, "test_template_imagesize.docx")
+		if err != nil {
+			t.Fatalf("Failed to create test template: %v", err)
+		}
+		defer os.Remove("test_template_imagesize.docx")
+
+		options := CreateReportOptions{
+			LiteralXmlDelimiter: "||",
+		}
+
+		outBuf, err := CreateReport("test_template_imagesize.docx", &data, options)
+		if err != nil {
+			t.Fatalf("CreateReport failed: %v", err)
+		}
+
+		os.WriteFile("test_output_imagesize.docx", outBuf, 0644)
+		defer os.Remove("test_output_imagesize.docx")
+
+		verifyDocxContent(t, "test_output_imagesize.docx", func(documentXml []byte) error {
+			extents := regexp.MustCompile(`<wp:extent (c[xy]="\d+") (c[xy]="\d+")/>`).FindAllSubmatch(documentXml, -1)
+			expected := [][2]string{
+				{`cx="476250"`, `cy="476250"`},   // 50px at 96 dpi
+				{`cx="1440000"`, `cy="1440000"`}, // 4cm, square
+				{`cx="720000"`, `cy="360000"`},   // 10x5cm fitted in 2cm wide
+				{`cx="720000"`, `cy="360000"`},   // 2cm wide, 2:1 viewBox
+			}
+			if len(extents) != len(expected) {
+				return fmt.Errorf("Expected %d images, found %d", len(expected), len(extents))
+			}
+			for i, extent := range extents {
+				got := []string{string(extent[1]), string(extent[2])}
+				if !slices.Contains(got, expected[i][0]) || !slices.Contains(got, expected[i][1]) {
+					return fmt.Errorf("Image %d: expected %v, got %v", i, expected[i], got)
+				}
+			}
+			return nil
+		})
+	})
+
+	// Test placeholder pictures
+	t.Run("placeholder image", func(t *testing.T) {
+		data := ReportData{
+			"logo": &ImagePars{Data: testPngData, Extension: ".png", Alt: "Company logo"},
+		}
+
+		templateContent := []byte(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+		<w:document xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main"
+			xmlns:wp="http://schemas.openxmlformats.org/drawingml/2006/wordprocessingDrawing"
+			xmlns:a="http://schemas.openxmlformats.org/drawingml/2006/main"
+			xmlns:pic="http://schemas.openxmlformats.org/drawingml/2006/picture"
+			xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">
+			<w:body>
+				<w:p><w:r><w:drawing>
+					<wp:anchor behindDoc="1" distT="0" distB="0" distL="0" distR="0">
+						<wp:extent cx="1800000" cy="900000"/>
+						<wp:wrapSquare wrapText="bothSides"/>
+						<wp:docPr id="1" name="Picture 1" descr="+++IMAGE logo+++"/>
+						<a:graphic><a:graphicData uri="http://schemas.openxmlformats.org/drawingml/2006/picture">
+							<pic:pic>
+								<pic:nvPicPr><pic:cNvPr id="0" name="sample.png"/><pic:cNvPicPr/></pic:nvPicPr>
+								<pic:blipFill><a:blip r:embed="rId5"/><a:srcRect l="10"/><a:stretch><a:fillRect/></a:stretch></pic:blipFill>
+								<pic:spPr><a:xfrm><a:off x="0" y="0"/><a:ext cx="1800000" cy="900000"/></a:xfrm><a:prstGeom prst="ellipse"/></pic:spPr>
+							</pic:pic>
+						</a:graphicData></a:graphic>
+					</wp:anchor>
+				</w:drawing></w:r></w:p>
+			</w:body>
+		</w:document>`)
+		err := createTestDocx(templateContent, "test_template_placeholder.docx")
+		if err != nil {
+			t.Fatalf("Failed to create test template: %v", err)
+		}
+		defer os.Remove("test_template_placeholder.docx")
+
+		options := CreateReportOptions{
+			LiteralXmlDelimiter: "||",
+		}
+
+		outBuf, err := CreateReport("test_template_placeholder.docx", &data, options)
+		if err != nil {
+			t.Fatalf("CreateReport failed: %v", err)
+		}
+
+		os.WriteFile("test_output_placeholder.docx", outBuf, 0644)
+		defer os.Remove("test_output_placeholder.docx")
+
+		verifyDocxContent(t, "test_output_placeholder.docx", func(documentXml []byte) error {
+			if bytes.Contains(documentXml, []byte("+++")) || bytes.Contains(documentXml, []byte(`"rId5"`)) {
+				return fmt.Errorf("Placeholder picture was not replaced")
+			}
+			// Square image fitted in the 5 x 2.5 cm placeholder
+			for _, val := range []string{`cx="900000"`, `cy="900000"`, `prst="ellipse"`, "wp:wrapSquare", `descr="Company logo"`} {
+				if !bytes.Contains(documentXml, []byte(val)) {
+					return fmt.Errorf("Generated document does not contain expected value: %s", val)
+				}
+			}
+			return nil
+		})
+	})
+
+	// Test floating images
+	t.Run("anchored image", func(t *testing.T) {
+		data := ReportData{
+			"stamp": &ImagePars{
+				Data: testPngData, Extension: ".png", Width: 3,
+				Anchor: &ImageAnchor{RelativeFromH: "page", OffsetX: 2, AlignV: "center", Wrap: "tight", BehindDoc: true},
+			},
+			"invalid": &ImagePars{
+				Data: testPngData, Extension: ".png", Width: 3,
+				Anchor: &ImageAnchor{Wrap: "around"},
+			},
+		}
+
+		templateContent := []byte(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+		<w:document xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main">
+			<w:body>
+				<w:p><w:r><w:t>+++IMAGE stamp+++</w:t></w:r></w:p>
+			</w:body>
+		</w:document>`)
+		err := createTestDocx(templateContent, "test_template_anchor.docx")
+		if err != nil {
+			t.Fatalf("Failed to create test template: %v", err)
+		}
+		defer os.Remove("test_template_anchor.docx")
+
+		options := CreateReportOptions{
+			LiteralXmlDelimiter: "||",
+		}
+
+		outBuf, err := CreateReport("test_template_anchor.docx", &data, options)
+		if err != nil {
+			t.Fatalf("CreateReport failed: %v", err)
+		}
+
+		os.WriteFile("test_output_anchor.docx", outBuf, 0644)
+		defer os.Remove("test_output_anchor.docx")
+
+		verifyDocxContent(t, "test_output_anchor.docx", func(documentXml []byte) error {
+			for _, val := range []string{
+				"<wp:anchor", `behindDoc="1"`, `<wp:positionH relativeFrom="page">`, "<wp:posOffset>720000</wp:posOffset>",
+				`<wp:positionV relativeFrom="paragraph">`, "<wp:align>center</wp:align>", "<wp:wrapPolygon",
+			} {
+				if !bytes.Contains(documentXml, []byte(val)) {
+					return fmt.Errorf("Generated document does not contain expected value: %s", val)
+				}
+			}
+			if bytes.Contains(documentXml, []byte("<wp:inline")) {
+				return fmt.Errorf("Generated document contains an inline image")
+			}
+			return nil
+		})
+
+		err = createTestDocx([]byte(strings.ReplaceAll(string(templateContent), "stamp", "invalid")), "test_template_anchor.docx")
+		if err != nil {
+			t.Fatalf("Failed to create test template: %v", err)
+		}
+		_, err = CreateReport("test_template_anchor.docx", &data, options)
+		if err == nil || !strings.Contains(err.Error(), "Invalid wrap mode") {
+			t.Errorf("Expected invalid wrap mode error, got %v", err)
+		}
+	})
+
+	// Test image deduplication
+	t.Run("image deduplication", func(t *testing.T) {
+		icon := &ImagePars{Data: testPngData, Extension: ".png", Width: 1}
+		data := ReportData{
+			"rows": []any{
+				map[string]any{"icon": icon},
+				map[string]any{"icon": icon},
+				map[string]any{"icon": &ImagePars{Data: slices.Clone(testPngData), Extension: ".png", Width: 1}},
+			},
+		}
+
+		templateContent := []byte(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+		<w:document xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main">
+			<w:body>
+				<w:p><w:r><w:t>+++FOR row IN rows+++</w:t></w:r></w:p>
+				<w:p><w:r><w:t>+++IMAGE $row.icon+++</w:t></w:r></w:p>
+				<w:p><w:r><w:t>+++END-FOR row+++</w:t></w:r></w:p>
+			</w:body>
+		</w:document>`)
+		err := createTestDocx(templateContent, "test_template_dedup.docx")
+		if err != nil {
+			t.Fatalf("Failed to create test template: %v", err)
+		}
+		defer os.Remove("test_template_dedup.docx")
+
+		options := CreateReportOptions{
+			LiteralXmlDelimiter: "||",
+		}
+
+		outBuf, err := CreateReport("test_template_dedup.docx", &data, options)
+		if err != nil {
+			t.Fatalf("CreateReport failed: %v", err)
+		}
+
+		outputZip, err := zip.NewReader(bytes.NewReader(outBuf), int64(len(outBuf)))
+		if err != nil {
+			t.Fatalf("Failed to open output: %v", err)
+		}
+		media := 0
+		var documentXml, relsXml []byte
+		for _, f := range outputZip.File {
+			if strings.HasPrefix(f.Name, "word/media/") {
+				media++
+			}
+			rc, _ := f.Open()
+			switch f.Name {
+			case "word/document.xml":
+				documentXml, _ = io.ReadAll(rc)
+			case "word/_rels/document.xml.rels":
+				relsXml, _ = io.ReadAll(rc)
+			}
+			rc.Close()
+		}
+		if media != 1 {
+			t.Errorf("Expected 1 media file, found %d", media)
+		}
+		if count := bytes.Count(relsXml, []byte("relationships/image")); count != 1 {
+			t.Errorf("Expected 1 image relationship, found %d", count)
+		}
+		if count := bytes.Count(documentXml, []byte("<wp:docPr")); count != 3 {
+			t.Errorf("Expected 3 images, found %d", count)
+		}
+	})
+
+	// Test image format detection and conversion
+	t.Run("image conversion", func(t *testing.T) {
+		bmpData := new(bytes.Buffer)
+		if err := bmp.Encode(bmpData, image.NewRGBA(image.Rect(0, 0, 20, 10))); err != nil {
+			t.Fatalf("Failed to encode BMP: %v", err)
+		}
+		svgData := []byte(`<svg xmlns="http://www.w3.org/2000/svg" width="40" height="20" viewBox="0 0 4 2">
+			<rect width="2" height="2" fill="#ff0000"/>
+			<path d="M2 0h2v2h-2z" style="fill: blue"/>
+		</svg>`)
+
+		templateContent := []byte(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+		<w:document xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main">
+			<w:body>
+				<w:p><w:r><w:t>+++IMAGE bitmap+++</w:t></w:r></w:p>
+				<w:p><w:r><w:t>+++IMAGE vector+++</w:t></w:r></w:p>
+			</w:body>
+		</w:document>`)
+		err := createTestDocx(templateContent, "test_template_conversion.docx")
+		if err != nil {
+			t.Fatalf("Failed to create test template: %v", err)
+		}
+		defer os.Remove("test_template_conversion.docx")
+
+		options := CreateReportOptions{
+			LiteralXmlDelimiter: "||",
+		}
+		data := ReportData{
+			"bitmap": &ImagePars{Data: bmpData.Bytes(), Extension: ".bmp"},
+			"vector": &ImagePars{Data: svgData, Extension: ".svg", Width: 4},
+		}
+		outBuf, err := CreateReport("test_template_conversion.docx", &data, options)
+		if err != nil {
+			t.Fatalf("CreateReport failed: %v", err)
+		}
+		outputZip, err := zip.NewReader(bytes.NewReader(outBuf), int64(len(outBuf)))
+		if err != nil {
+			t.Fatalf("Failed to open output: %v", err)
+		}
+		pngs := []image.Image{}
+		for _, f := range outputZip.File {
+			if !strings.HasPrefix(f.Name, "word/media/") {
+				continue
+			}
+			if strings.HasSuffix(f.Name, ".bmp") {
+				t.Errorf("BMP image not converted: %s", f.Name)
+			}
+			if strings.HasSuffix(f.Name, ".png") {
+				rc, _ := f.Open()
+				img, err := png.Decode(rc)
+				rc.Close()
+				if err != nil {
+					t.Fatalf("Invalid PNG %s: %v", f.Name, err)
+				}
+				pngs = append(pngs, img)
+			}
+		}
+		if len(pngs) != 2 {
+			t.Fatalf("Expected 2 PNG images, found %d", len(pngs))
+		}
+		for _, img := range pngs {
+			if img.Bounds().Dx() == 20 {
+				continue
+			}
+			// SVG fallback: red on the left, blue on the right
+			bounds := img.Bounds()
+			r, _, _, _ := img.At(bounds.Dx()/4, bounds.Dy()/2).RGBA()
+			_, _, b, _ := img.At(bounds.Dx()*3/4, bounds.Dy()/2).RGBA()
+			if bounds.Dx() != 2*bounds.Dy() || r>>8 != 255 || b>>8 != 255 {
+				t.Errorf("Unexpected SVG fallback rendering: %v", bounds)
+			}
+		}
+
+		// Extension not matching the data
+		data = ReportData{
+			"bitmap": &ImagePars{Data: testPngData, Extension: ".jpg"},
+			"vector": &ImagePars{Data: svgData, Extension: ".svg", Width: 4},
+		}
+		_, err = CreateReport("test_template_conversion.docx", &data, options)
+		if err == nil || !strings.Contains(err.Error(), "The image data is a .png image, but its extension is .jpg") {
+			t.Errorf("Expected an extension mismatch error, got %v", err)
+		}
+	})
+
+	// Test image compression
+	t.Run("image compression", func(t *testing.T) {
+		photo := new(bytes.Buffer)
+		if err := jpeg.Encode(photo, image.NewRGBA(image.Rect(0, 0, 400, 200)), nil); err != nil {
+			t.Fatalf("Failed to encode JPEG: %v", err)
+		}
+		// EXIF block with Orientation = 6 (rotated by 90° clockwise)
+		exif := []byte("\xff\xe1\x00\x22Exif\x00\x00II*\x00\x08\x00\x00\x00\x01\x00\x12\x01\x03\x00\x01\x00\x00\x00\x06\x00\x00\x00\x00\x00\x00\x00")
+		photoData := slices.Concat(photo.Bytes()[:2], exif, photo.Bytes()[2:])
+
+		templateContent := []byte(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+		<w:document xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main">
+			<w:body>
+				<w:p><w:r><w:t>+++IMAGE photo+++</w:t></w:r></w:p>
+			</w:body>
+		</w:document>`)
+		err := createTestDocx(templateContent, "test_template_compression.docx")
+		if err != nil {
+			t.Fatalf("Failed to create test template: %v", err)
+		}
+		defer os.Remove("test_template_compression.docx")
+
+		options := CreateReportOptions{
+			LiteralXmlDelimiter: "||",
+			ImageCompression:    &ImageCompression{MaxDpi: 100, JpegQuality: 70},
+		}
+		data := ReportData{
+			"photo": &ImagePars{Data: photoData, Extension: ".jpg", Width: 2},
+		}
+		outBuf, err := CreateReport("test_template_compression.docx", &data, options)
+		if err != nil {
+			t.Fatalf("CreateReport failed: %v", err)
+		}
+		outputZip, err := zip.NewReader(bytes.NewReader(outBuf), int64(len(outBuf)))
+		if err != nil {
+			t.Fatalf("Failed to open output: %v", err)
+		}
+		for _, f := range outputZip.File {
+			rc, _ := f.Open()
+			content, _ := io.ReadAll(rc)
+			rc.Close()
+			switch {
+			case strings.HasPrefix(f.Name, "word/media/"):
+				config, err := jpeg.DecodeConfig(bytes.NewReader(content))
+				if err != nil {
+					t.Fatalf("Invalid JPEG %s: %v", f.Name, err)
+				}
+				// Upright, 2 x 4 cm at 100 dpi
+				if config.Width != 79 || config.Height != 157 {
+					t.Errorf("Unexpected image size %dx%d", config.Width, config.Height)
+				}
+				if bytes.Contains(content, []byte("Exif")) {
+					t.Errorf("EXIF metadata not stripped")
+				}
+			case f.Name == "word/document.xml":
+				root, err := ParseXml(string(content))
+				if err != nil {
+					t.Fatalf("Failed to parse output: %v", err)
+				}
+				extents := findDescendants(root, "wp:extent")
+				if len(extents) != 1 || extents[0].Attrs["cx"] != "720000" || extents[0].Attrs["cy"] != "1440000" {
+					t.Errorf("Unexpected image extent in %s", content)
+				}
+			}
+		}
+
+		// Without compression, the image is kept as is but sized as displayed
+		outBuf, err = CreateReport("test_template_compression.docx", &data, CreateReportOptions{LiteralXmlDelimiter: "||"})
+		if err != nil {
+			t.Fatalf("CreateReport failed: %v", err)
+		}
+		outputZip, err = zip.NewReader(bytes.NewReader(outBuf), int64(len(outBuf)))
+		if err != nil {
+			t.Fatalf("Failed to open output: %v", err)
+		}
+		for _, f := range outputZip.File {
+			if f.Name != "word/document.xml" {
+				continue
+			}
+			rc, _ := f.Open()
+			content, _ := io.ReadAll(rc)
+			rc.Close()
+			root, err := ParseXml(string(content))
+			if err != nil {
+				t.Fatalf("Failed to parse output: %v", err)
+			}
+			extents := findDescendants(root, "wp:extent")
+			if len(extents) != 1 || extents[0].Attrs["cx"] != "720000" || extents[0].Attrs["cy"] != "1440000" {
+				t.Errorf("Unexpected image extent of the uncompressed image in %s", content)
+			}
+		}
+
+		// Truncated JPEG segments
+		for _, header := range []string{"\xff\xd8\xff\xe1\x00\x00", "\xff\xd8\xff\xe0\x00\x01\xff\xda", "\xff\xd8\xff\xe1\x00\x08Exif"} {
+			data := ReportData{
+				"photo": &ImagePars{Data: []byte(header), Extension: ".jpg", Width: 2},
+			}
+			if _, err := CreateReport("test_template_compression.docx", &data, options); err == nil {
+				t.Errorf("Expected an error for the invalid JPEG image %q", header)
+			}
+		}
+	})
+
+	// Test chart generation
+	t.Run("chart processing", func(t *testing.T) {
+		templateContent := []byte(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+		<w:document xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main">
+			<w:body>
+				<w:p><w:r><w:t>+++CHART sales+++</w:t></w:r></w:p>
+				<w:p><w:r><w:t>+++CHART share+++</w:t></w:r></w:p>
+			</w:body>
+		</w:document>`)
+		err := createTestDocx(templateContent, "test_template_chart.docx")
+		if err != nil {
+			t.Fatalf("Failed to create test template: %v", err)
+		}
+		defer os.Remove("test_template_chart.docx")
+
+		options := CreateReportOptions{
+			LiteralXmlDelimiter: "||",
+		}
+		data := ReportData{
+			"sales": &ChartPars{
+				Type:       "column",
+				Title:      "Sales",
+				Categories: []string{"Q1", "Q2", "Q3"},
+				Series: []ChartSeries{
+					{Name: "2023", Values: []float64{10, 12.5, 8}, Color: "#4472C4"},
+					{Name: "2024", Values: []float64{11, 14, 9}},
+				},
+				YAxisTitle: "k€",
+			},
+			"share": &ChartPars{
+				Type:       "pie",
+				Categories: []string{"A", "B"},
+				Series:     []ChartSeries{{Name: "Share", Values: []float64{60, 40}}},
+			},
+		}
+		outBuf, err := CreateReport("test_template_chart.docx", &data, options)
+		if err != nil {
+			t.Fatalf("CreateReport failed: %v", err)
+		}
+		outputZip, err := zip.NewReader(bytes.NewReader(outBuf), int64(len(outBuf)))
+		if err != nil {
+			t.Fatalf("Failed to open output: %v", err)
+		}
+		files := map[string]string{}
+		for _, f := range outputZip.File {
+			rc, _ := f.Open()
+			content, _ := io.ReadAll(rc)
+			rc.Close()
+			files[f.Name] = string(content)
+		}
+		chart1, chart2 := files["word/charts/chart1.xml"], files["word/charts/chart2.xml"]
+		if chart1 == "" || chart2 == "" {
+			t.Fatalf("Missing chart parts")
+		}
+		if !strings.Contains(chart1, `<c:barDir val="col"/>`) || !strings.Contains(chart1, "<c:v>12.5</c:v>") ||
+			!strings.Contains(chart1, `<a:srgbClr val="4472C4"/>`) || !strings.Contains(chart1, "<a:t>k€</a:t>") {
+			t.Errorf("Unexpected chart content: %s", chart1)
+		}
+		if !strings.Contains(chart2, "<c:pieChart>") {
+			t.Errorf("Unexpected chart content: %s", chart2)
+		}
+		if !strings.Contains(files["[Content_Types].xml"], `PartName="/word/charts/chart1.xml"`) {
+			t.Errorf("Missing chart content type")
+		}
+		if !strings.Contains(files["word/_rels/document.xml.rels"], `Target="charts/chart2.xml"`) {
+			t.Errorf("Missing chart relationship")
+		}
+		if strings.Count(files["word/document.xml"], "<c:chart ") != 2 {
+			t.Errorf("Expected 2 charts in the document")
+		}
+
+		// Invalid series
+		data["share"].(*ChartPars).Series[0].Values = []float64{1}
+		_, err = CreateReport("test_template_chart.docx", &data, options)
+		if err == nil || !strings.Contains(err.Error(), `Series "Share" has 1 values, but there are 2 categories`) {
+			t.Errorf("Expected a series error, got %v", err)
+		}
+		data["share"].(*ChartPars).Series[0] = ChartSeries{Name: "Share", Values: []float64{60, 40}, Color: "blue"}
+		_, err = CreateReport("test_template_chart.docx", &data, options)
+		if err == nil || !strings.Contains(err.Error(), `Series "Share" has an invalid color: "blue"`) {
+			t.Errorf("Expected a color error, got %v", err)
+		}
+	})
+
+	// Test template chart update
+	t.Run("template chart update", func(t *testing.T) {
+		templateContent := []byte(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+		<w:document xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main" xmlns:wp="http://schemas.openxmlformats.org/drawingml/2006/wordprocessingDrawing" xmlns:a="http://schemas.openxmlformats.org/drawingml/2006/main" xmlns:c="http://schemas.openxmlformats.org/drawingml/2006/chart" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">
+			<w:body>
+				<w:p><w:r><w:drawing><wp:inline>
+					<wp:extent cx="5400000" cy="3240000"/>
+					<wp:docPr id="1" name="Chart 1" descr="+++CHART sales+++"/>
+					<a:graphic><a:graphicData uri="http://schemas.openxmlformats.org/drawingml/2006/chart"><c:chart r:id="rId5"/></a:graphicData></a:graphic>
+				</wp:inline></w:drawing></w:r></w:p>
+				<w:p><w:r><w:drawing><wp:inline>
+					<wp:extent cx="5400000" cy="3240000"/>
+					<wp:docPr id="2" name="Chart 2"/>
+					<a:graphic><a:graphicData uri="http://schemas.openxmlformats.org/drawingml/2006/chart"><c:chart r:id="rId6"/></a:graphicData></a:graphic>
+				</wp:inline></w:drawing></w:r></w:p>
+			</w:body>
+		</w:document>`)
+		chartXml := func(title string, data string) []byte {
+			return []byte(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+			<c:chartSpace xmlns:c="http://schemas.openxmlformats.org/drawingml/2006/chart" xmlns:a="http://schemas.openxmlformats.org/drawingml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">
+				<c:chart>
+					<c:title><c:tx><c:rich><a:bodyPr/><a:p><a:r><a:rPr b="1"/><a:t>` + title + `</a:t></a:r></a:p></c:rich></c:tx></c:title>
+					<c:plotArea><c:layout/><c:barChart><c:barDir val="col"/><c:ser>
+						<c:idx val="0"/><c:order val="0"/>
+						<c:spPr><a:solidFill><a:srgbClr val="FF0000"/></a:solidFill></c:spPr>
+						` + data + `
+					</c:ser><c:axId val="1"/><c:axId val="2"/></c:barChart></c:plotArea>
+				</c:chart>
+				<c:externalData r:id="rId1"><c:autoUpdate val="0"/></c:externalData>
+			</c:chartSpace>`)
+		}
+		err := createTestDocxWithFiles(templateContent, map[string][]byte{
+			"word/_rels/document.xml.rels": []byte(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+			<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">
+				<Relationship Id="rId5" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/chart" Target="charts/chart1.xml"/>
+				<Relationship Id="rId6" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/chart" Target="charts/chart2.xml"/>
+			</Relationships>`),
+			"word/charts/chart1.xml": chartXml("Sales", `
+				<c:tx><c:strRef><c:f>Sheet1!$B$1</c:f><c:strCache><c:ptCount val="1"/><c:pt idx="0"><c:v>Old</c:v></c:pt></c:strCache></c:strRef></c:tx>
+				<c:cat><c:strRef><c:f>Sheet1!$A$2:$A$3</c:f><c:strCache><c:ptCount val="2"/><c:pt idx="0"><c:v>x</c:v></c:pt><c:pt idx="1"><c:v>y</c:v></c:pt></c:strCache></c:strRef></c:cat>
+				<c:val><c:numRef><c:f>Sheet1!$B$2:$B$3</c:f><c:numCache><c:formatCode>0.0</c:formatCode><c:ptCount val="2"/><c:pt idx="0"><c:v>1</c:v></c:pt><c:pt idx="1"><c:v>2</c:v></c:pt></c:numCache></c:numRef></c:val>`),
+			"word/charts/_rels/chart1.xml.rels": []byte(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+			<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">
+				<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/package" Target="../embeddings/Microsoft_Excel_Worksheet.xlsx"/>
+			</Relationships>`),
+			"word/embeddings/Microsoft_Excel_Worksheet.xlsx": []byte("old workbook"),
+			"word/charts/chart2.xml": chartXml("Share", `
+				<c:tx><c:v>Old</c:v></c:tx>
+				<c:cat><c:strLit><c:ptCount val="1"/><c:pt idx="0"><c:v>x</c:v></c:pt></c:strLit></c:cat>
+				<c:val><c:numLit><c:formatCode>General</c:formatCode><c:ptCount val="1"/><c:pt idx="0"><c:v>1</c:v></c:pt></c:numLit></c:val>`),
+		}, "test_template_chart_update.docx")
+		if err != nil {
+			t.Fatalf("Failed to create test template: %v", err)
+		}
+		defer os.Remove("test_template_chart_update.docx")
+
+		options := CreateReportOptions{
+			LiteralXmlDelimiter: "||",
+			ChartsByTitle:       map[string]string{"Share": "share"},
+		}
+		data := ReportData{
+			"sales": &ChartPars{
+				Title:      "Sales 2024",
+				Categories: []string{"Q1", "Q2", "Q3"},
+				Series: []ChartSeries{
+					{Name: "North", Values: []float64{1, 2, 3}},
+					{Name: "South", Values: []float64{4, 5, 6}},
+				},
+			},
+			"share": &ChartPars{
+				Categories: []string{"A", "B"},
+				Series:     []ChartSeries{{Name: "Share", Values: []float64{60, 40}}},
+			},
+		}
+		outBuf, err := CreateReport("test_template_chart_update.docx", &data, options)
+		if err != nil {
+			t.Fatalf("CreateReport failed: %v", err)
+		}
+		outputZip, err := zip.NewReader(bytes.NewReader(outBuf), int64(len(outBuf)))
+		if err != nil {
+			t.Fatalf("Failed to open output: %v", err)
+		}
+		files := map[string][]byte{}
+		for _, f := range outputZip.File {
+			rc, _ := f.Open()
+			files[f.Name], _ = io.ReadAll(rc)
+			rc.Close()
+		}
+		chart1 := string(files["word/charts/chart1.xml"])
+		for _, expected := range []string{
+			"<a:t>Sales 2024</a:t>", `<a:rPr b="1"/>`, `<a:srgbClr val="FF0000"/>`,
+			"<c:f>Sheet1!$C$1</c:f>", "<c:f>Sheet1!$A$2:$A$4</c:f>", "<c:f>Sheet1!$C$2:$C$4</c:f>",
+			"<c:formatCode>0.0</c:formatCode>", "<c:v>Q3</c:v>", "<c:v>South</c:v>", "<c:v>6</c:v>",
+		} {
+			if !strings.Contains(chart1, expected) {
+				t.Errorf("Expected %s in %s", expected, chart1)
+			}
+		}
+		if strings.Count(chart1, "<c:ser>") != 2 || strings.Count(chart1, "FF0000") != 1 {
+			t.Errorf("Expected 2 series, the new one without the formatting of the first one")
+		}
+		chart2 := string(files["word/charts/chart2.xml"])
+		if !strings.Contains(chart2, "<c:v>Share</c:v>") || !strings.Contains(chart2, "<c:v>40</c:v>") || strings.Contains(chart2, "<c:f>") {
+			t.Errorf("Unexpected chart content: %s", chart2)
+		}
+		if strings.Contains(string(files["word/document.xml"]), "+++CHART") {
+			t.Errorf("Alt text command not removed")
+		}
+
+		workbook, err := zip.NewReader(bytes.NewReader(files["word/embeddings/Microsoft_Excel_Worksheet.xlsx"]), int64(len(files["word/embeddings/Microsoft_Excel_Worksheet.xlsx"])))
+		if err != nil {
+			t.Fatalf("Invalid embedded workbook: %v", err)
+		}
+		for _, f := range workbook.File {
+			if f.Name == "xl/worksheets/sheet1.xml" {
+				rc, _ := f.Open()
+				sheet, _ := io.ReadAll(rc)
+				rc.Close()
+				if !strings.Contains(string(sheet), `<c r="C4">`) || !strings.Contains(string(sheet), "<t>Q3</t>") {
+					t.Errorf("Unexpected sheet content: %s", sheet)
+				}
+			}
+		}
+	})
+
+	t.Run("barcode functions", func(t *testing.T) {
+		templateContent := []byte(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+		<w:document xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main">
+			<w:body>
+				<w:p><w:r><w:t>+++IMAGE qr('https://example.com', 4)+++</w:t></w:r></w:p>
+				<w:p><w:r><w:t>+++IMAGE code128(ref)+++</w:t></w:r></w:p>
+				<w:p><w:r><w:t>+++IMAGE ean13('400638133393', 4, 2)+++</w:t></w:r></w:p>
+			</w:body>
+		</w:document>`)
+		err := createTestDocx(templateContent, "test_template_barcodes.docx")
+		if err != nil {
+			t.Fatalf("Failed to create test template: %v", err)
+		}
+		defer os.Remove("test_template_barcodes.docx")
+
+		data := ReportData{"ref": "INV-2024-000123"}
+		for _, format := range []string{"png", "svg"} {
+			options := CreateReportOptions{
+				LiteralXmlDelimiter: "||",
+				Barcodes:            BarcodeOptions{Format: format, ErrorCorrection: "H"},
+			}
+			outBuf, err := CreateReport("test_template_barcodes.docx", &data, options)
+			if err != nil {
+				t.Fatalf("CreateReport failed: %v", err)
+			}
+			outputZip, err := zip.NewReader(bytes.NewReader(outBuf), int64(len(outBuf)))
+			if err != nil {
+				t.Fatalf("Failed to open output: %v", err)
+			}
+			media := 0
+			for _, f := range outputZip.File {
+				if strings.HasPrefix(f.Name, "word/media/") && strings.HasSuffix(f.Name, "."+format) {
+					media++
+				}
+				if f.Name == "word/document.xml" {
+					rc, _ := f.Open()
+					document, _ := io.ReadAll(rc)
+					rc.Close()
+					root, err := ParseXml(string(document))
+					if err != nil {
+						t.Fatalf("Failed to parse output: %v", err)
+					}
+					extents, docPrs := findDescendants(root, "wp:extent"), findDescendants(root, "wp:docPr")
+					if len(extents) != 3 || len(docPrs) != 3 {
+						t.Fatalf("Expected 3 images in %s", document)
+					}
+					// 4 cm square, and 4 x 2 cm
+					for i, expected := range map[int][2]string{0: {"1440000", "1440000"}, 2: {"1440000", "720000"}} {
+						if extents[i].Attrs["cx"] != expected[0] || extents[i].Attrs["cy"] != expected[1] {
+							t.Errorf("Unexpected extent of image %d: %v", i, extents[i].Attrs)
+						}
+					}
+					if docPrs[1].Attrs["descr"] != "INV-2024-000123" {
+						t.Errorf("Unexpected description of the code 128 barcode: %v", docPrs[1].Attrs)
+					}
+				}
+			}
+			if media != 3 {
+				t.Errorf("Expected 3 %s images, got %d", format, media)
+			}
+		}
+
+		// Golden patterns: modules (1 for a bar) of EAN-13, widths of the bars and spaces of Code 128
+		modules := func(bars []bool) string {
+			var text strings.Builder
+			for _, bar := range bars {
+				text.WriteString(map[bool]string{true: "1", false: "0"}[bar])
+			}
+			return text.String()
+		}
+		bars, err := encodeEan13("4006381333931")
+		// 4 gives the parity LGLLGG of the left digits
+		expectedEan13 := "101" + "0001101" + "0100111" + "0101111" + "0111101" + "0001001" + "0110011" +
+			"01010" + "1000010" + "1000010" + "1000010" + "1110100" + "1000010" + "1100110" + "101"
+		if err != nil || modules(bars) != expectedEan13 {
+			t.Errorf("Unexpected EAN-13 encoding: %v\n%s\n%s", err, modules(bars), expectedEan13)
+		}
+		if _, err := encodeEan13("4006381333932"); err == nil {
+			t.Errorf("Expected an invalid check digit error")
+		}
+		for text, expected := range map[string][]string{
+			// Start A, HT, checksum 73, stop
+			"\t": {"211412", "142112", "142112", "2331112"},
+			// Start B, a, Code A, HT, Code B, b, checksum 84, stop
+			"a\tb": {"211214", "121124", "311141", "142112", "114131", "121421", "124112", "2331112"},
+			// Start B, A, b, Code C, 12, 34, checksum 63, stop
+			"Ab1234": {"211214", "111323", "121421", "113141", "112232", "131123", "111224", "2331112"},
+		} {
+			bars, err := encodeCode128(text)
+			if err != nil {
+				t.Errorf("Code 128 encoding of %q failed: %v", text, err)
+				continue
+			}
+			widths := ""
+			for i := 0; i < len(bars); {
+				n := 1
+				for i+n < len(bars) && bars[i+n] == bars[i] {
+					n++
+				}
+				widths += fmt.Sprint(n)
+				i += n
+			}
+			if widths != strings.Join(expected, "") {
+				t.Errorf("Unexpected Code 128 encoding of %q:\n%s\n%s", text, widths, strings.Join(expected, ""))
+			}
+		}
+		if _, err := encodeCode128("café"); err == nil {
+			t.Errorf("Expected an unsupported character error")
+		}
+		matrix, err := encodeQr([]byte("HELLO"), "M")
+		if err != nil {
+			t.Fatalf("QR code encoding failed: %v", err)
+		}
+		qrRows := []string{}
+		for _, row := range matrix {
+			qrRows = append(qrRows, strings.ReplaceAll(strings.ReplaceAll(modules(row), "1", "#"), "0", "."))
+		}
+		// HELLO in byte mode, at level M (as decoded by ZXing)
+		expectedQr := []string{
+			"#######.##.#..#######",
+			"#.....#..##.#.#.....#",
+			"#.###.#..####.#.###.#",
+			"#.###.#.#..#..#.###.#",
+			"#.###.#.#...#.#.###.#",
+			"#.....#.#.##..#.....#",
+			"#######.#.#.#.#######",
+			"........#####........",
+			"#...#.######.#####..#",
+			"...###..#.###..#.####",
+			"#.##..#.#.##..###..#.",
+			"###..#...#...##.#....",
+			"..#.###..#..###...##.",
+			"........###.###..#.##",
+			"#######.##..##...#.#.",
+			"#.....#....##..#...#.",
+			"#.###.#.#..#..###.#.#",
+			"#.###.#....##....#.##",
+			"#.###.#..###..####...",
+			"#.....#..#...##......",
+			"#######.#...#####.#.#",
+		}
+		if !slices.Equal(qrRows, expectedQr) {
+			t.Errorf("Unexpected QR code:\n%s", strings.Join(qrRows, "\n"))
+		}
+		// Version 1 (21 x 21 modules), version 2 from 15 bytes at level M
+		for text, size := range map[string]int{"HELLO": 21, "123456789012345": 25} {
+			matrix, err := encodeQr([]byte(text), "M")
+			if err != nil || len(matrix) != size {
+				t.Errorf("Unexpected QR code size for %s: %d", text, len(matrix))
+			}
+		}
+
+		_, err = CreateReport("test_template_barcodes.docx", &data, CreateReportOptions{
+			LiteralXmlDelimiter: "||",
+			Functions:           Functions{"ean13": func(args ...any) VarValue { return errors.New("custom") }},
+		})
+		if err == nil || !strings.Contains(err.Error(), "custom") {
+			t.Errorf("Expected the error returned by the overriding function, got %v", err)
+		}
+	})
+
+	t.Run("include", func(t *testing.T) {
+		pngData := new(bytes.Buffer)
+		if err := png.Encode(pngData, image.NewRGBA(image.Rect(0, 0, 4, 4))); err != nil {
+			t.Fatalf("Failed to encode PNG: %v", err)
+		}
+		stylesXml := func(styles string) []byte {
+			return []byte(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+			<w:styles xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main">
+				<w:style w:type="paragraph" w:styleId="Normal"><w:name w:val="Normal"/></w:style>` + styles + `
+			</w:styles>`)
+		}
+		numberingXml := []byte(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+			<w:numbering xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main">
+				<w:abstractNum w:abstractNumId="0"><w:lvl w:ilvl="0"><w:numFmt w:val="decimal"/></w:lvl></w:abstractNum>
+				<w:num w:numId="1"><w:abstractNumId w:val="0"/></w:num>
+			</w:numbering>`)
+		documentRels := []byte(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+			<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">
+				<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/styles" Target="styles.xml"/>
+				<Relationship Id="rId2" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/numbering" Target="numbering.xml"/>
+				<Relationship Id="rId7" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/image" Target="media/image1.png"/>
+				<Relationship Id="rId8" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/hyperlink" Target="https://example.com" TargetMode="External"/>
+			</Relationships>`)
+
+		err := createTestDocxWithFiles([]byte(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+		<w:document xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">
+			<w:body>
+				<w:p><w:bookmarkStart w:id="0" w:name="clause"/><w:r><w:t>Clause +++$clause.title+++</w:t></w:r><w:bookmarkEnd w:id="0"/></w:p>
+				<w:p><w:pPr><w:pStyle w:val="ClauseText"/><w:numPr><w:ilvl w:val="0"/><w:numId w:val="1"/></w:numPr></w:pPr><w:r><w:t>+++$clause.text+++</w:t></w:r></w:p>
+				<w:p><w:r><w:drawing><wp:inline xmlns:wp="http://schemas.openxmlformats.org/drawingml/2006/wordprocessingDrawing"><wp:docPr id="1" name="Logo"/><a:graphic xmlns:a="http://schemas.openxmlformats.org/drawingml/2006/main"><a:graphicData><a:blip r:embed="rId7"/></a:graphicData></a:graphic></wp:inline></w:drawing></w:r></w:p>
+				<w:p><w:hyperlink r:id="rId8"><w:r><w:t>Details</w:t></w:r></w:hyperlink></w:p>
+				<w:sectPr><w:pgSz w:w="5000" w:h="5000"/></w:sectPr>
+			</w:body>
+		</w:document>`), map[string][]byte{
+			"word/_rels/document.xml.rels": documentRels,
+			"word/styles.xml":              stylesXml(`<w:style w:type="paragraph" w:styleId="ClauseText"><w:name w:val="Clause text"/><w:pPr><w:numPr><w:numId w:val="1"/></w:numPr></w:pPr></w:style>`),
+			"word/numbering.xml":           numberingXml,
+			"word/media/image1.png":        pngData.Bytes(),
+		}, "test_include_clause.docx")
+		if err != nil {
+			t.Fatalf("Failed to create included template: %v", err)
+		}
+		defer os.Remove("test_include_clause.docx")
+		err = createTestDocxWithFiles([]byte(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+		<w:document xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main">
+			<w:body>
+				<w:p><w:bookmarkStart w:id="0" w:name="top"/><w:r><w:t>Contract</w:t></w:r><w:bookmarkEnd w:id="0"/></w:p>
+				<w:p><w:r><w:t>+++FOR clause IN clauses+++</w:t></w:r></w:p>
+				<w:p><w:r><w:t>+++INCLUDE $clause.template+++</w:t></w:r></w:p>
+				<w:p><w:r><w:t>+++END-FOR clause+++</w:t></w:r></w:p>
+				<w:sectPr/>
+			</w:body>
+		</w:document>`), map[string][]byte{
+			"word/_rels/document.xml.rels": documentRels,
+			"word/styles.xml":              stylesXml(""),
+			"word/numbering.xml":           numberingXml,
+		}, "test_include.docx")
+		if err != nil {
+			t.Fatalf("Failed to create test template: %v", err)
+		}
+		defer os.Remove("test_include.docx")
+		loads := 0
+		options := CreateReportOptions{
+			LiteralXmlDelimiter: "||",
+			IncludeLoader: func(name string) ([]byte, error) {
+				loads++
+				return os.ReadFile(name)
+			},
+		}
+		data := ReportData{"clauses": []any{
+			map[string]any{"template": "test_include_clause.docx", "title": "Liability", "text": "We are not liable."},
+			map[string]any{"template": "test_include_clause.docx", "title": "Warranty", "text": "None."},
+		}}
+		outBuf, err := CreateReport("test_include.docx", &data, options)
+		if err != nil {
+			t.Fatalf("CreateReport failed: %v", err)
+		}
+		if loads != 1 {
+			t.Errorf("Expected the included template to be loaded once, got %d", loads)
+		}
+		outputZip, err := zip.NewReader(bytes.NewReader(outBuf), int64(len(outBuf)))
+		if err != nil {
+			t.Fatalf("Failed to open output: %v", err)
+		}
+		files := map[string]string{}
+		media := 0
+		for _, f := range outputZip.File {
+			rc, _ := f.Open()
+			content, _ := io.ReadAll(rc)
+			rc.Close()
+			files[f.Name] = string(content)
+			if strings.HasPrefix(f.Name, "word/media/") {
+				media++
+			}
+		}
+		document := files["word/document.xml"]
+		for _, expected := range []string{
+			"Clause Liability", "We are not liable.", "Clause Warranty", "None.",
+			`<w:numId w:val="2"/>`, `<w:numId w:val="3"/>`, `<w:bookmarkEnd w:id="1"/>`, `<w:bookmarkEnd w:id="2"/>`,
+			`r:id="include1_rId8"`, `r:id="include2_rId8"`, `xmlns:r=`,
+		} {
+			if !strings.Contains(document, expected) {
+				t.Errorf("Expected %s in %s", expected, document)
+			}
+		}
+		if strings.Contains(document, "INCLUDE") || strings.Count(document, "<w:sectPr") != 1 || strings.Contains(document, `r:embed="rId7"`) {
+			t.Errorf("Unexpected document content: %s", document)
+		}
+		if media != 1 || !strings.Contains(files["word/_rels/document.xml.rels"], `Target="https://example.com"`) {
+			t.Errorf("Expected a single (deduplicated) image, and the hyperlinks: %d, %s", media, files["word/_rels/document.xml.rels"])
+		}
+		numbering := strings.Join(strings.Fields(files["word/numbering.xml"]), "")
+		if strings.Count(numbering, "</w:abstractNum>") != 3 || !strings.Contains(numbering, `</w:abstractNum><w:num`) ||
+			!strings.Contains(numbering, `<w:numw:numId="3"><w:abstractNumIdw:val="2"/>`) {
+			t.Errorf("Unexpected numbering: %s", numbering)
+		}
+		styles := files["word/styles.xml"]
+		if strings.Count(styles, `w:styleId="Normal"`) != 1 || strings.Count(styles, `w:styleId="ClauseText"`) != 1 || !strings.Contains(styles, `<w:numId w:val="2"/>`) {
+			t.Errorf("Unexpected styles: %s", styles)
+		}
+
+		// Nested inclusion: the numbering of each template is remapped once
+		nestedNumberingXml := func(formats ...string) []byte {
+			definitions := ""
+			for i, format := range formats {
+				definitions += fmt.Sprintf(`<w:abstractNum w:abstractNumId="%d"><w:lvl w:ilvl="0"><w:numFmt w:val="%s"/></w:lvl></w:abstractNum>`, i, format)
+			}
+			for i := range formats {
+				definitions += fmt.Sprintf(`<w:num w:numId="%d"><w:abstractNumId w:val="%d"/></w:num>`, i+1, i)
+			}
+			return []byte(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+			<w:numbering xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main">` + definitions + `</w:numbering>`)
+		}
+		err = createTestDocxWithFiles([]byte(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+		<w:document xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main">
+			<w:body><w:p><w:pPr><w:numPr><w:ilvl w:val="0"/><w:numId w:val="1"/></w:numPr></w:pPr><w:r><w:t>Inner item</w:t></w:r></w:p></w:body>
+		</w:document>`), map[string][]byte{
+			"word/_rels/document.xml.rels": documentRels,
+			"word/styles.xml":              stylesXml(""),
+			"word/numbering.xml":           nestedNumberingXml("upperLetter"),
+		}, "test_include_inner.docx")
+		if err != nil {
+			t.Fatalf("Failed to create included template: %v", err)
+		}
+		defer os.Remove("test_include_inner.docx")
+		err = createTestDocxWithFiles([]byte(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+		<w:document xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main">
+			<w:body>
+				<w:p><w:pPr><w:numPr><w:ilvl w:val="0"/><w:numId w:val="3"/></w:numPr></w:pPr><w:r><w:t>Outer item</w:t></w:r></w:p>
+				<w:p><w:r><w:t>+++INCLUDE 'test_include_inner.docx'+++</w:t></w:r></w:p>
+			</w:body>
+		</w:document>`), map[string][]byte{
+			"word/_rels/document.xml.rels": documentRels,
+			"word/styles.xml":              stylesXml(""),
+			"word/numbering.xml":           nestedNumberingXml("decimal", "lowerRoman", "bullet"),
+		}, "test_include_outer.docx")
+		if err != nil {
+			t.Fatalf("Failed to create included template: %v", err)
+		}
+		defer os.Remove("test_include_outer.docx")
+		err = createTestDocxWithFiles([]byte(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+		<w:document xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main">
+			<w:body>
+				<w:p><w:pPr><w:numPr><w:ilvl w:val="0"/><w:numId w:val="1"/></w:numPr></w:pPr><w:r><w:t>Main item</w:t></w:r></w:p>
+				<w:p><w:r><w:t>+++INCLUDE 'test_include_outer.docx'+++</w:t></w:r></w:p>
+			</w:body>
+		</w:document>`), map[string][]byte{
+			"word/_rels/document.xml.rels": documentRels,
+			"word/styles.xml":              stylesXml(""),
+			"word/numbering.xml":           nestedNumberingXml("decimal", "lowerLetter"),
+		}, "test_include_nested.docx")
+		if err != nil {
+			t.Fatalf("Failed to create test template: %v", err)
+		}
+		defer os.Remove("test_include_nested.docx")
+		outBuf, err = CreateReport("test_include_nested.docx", &data, options)
+		if err != nil {
+			t.Fatalf("CreateReport failed: %v", err)
+		}
+		outputZip, err = zip.NewReader(bytes.NewReader(outBuf), int64(len(outBuf)))
+		if err != nil {
+			t.Fatalf("Failed to open output: %v", err)
+		}
+		for _, f := range outputZip.File {
+			rc, _ := f.Open()
+			content, _ := io.ReadAll(rc)
+			rc.Close()
+			files[f.Name] = string(content)
+		}
+		root, err := ParseXml(files["word/document.xml"])
+		if err != nil {
+			t.Fatalf("Failed to parse output: %v", err)
+		}
+		numberingRoot, err := ParseXml(files["word/numbering.xml"])
+		if err != nil {
+			t.Fatalf("Failed to parse numbering: %v", err)
+		}
+		formats := []string{}
+		for _, numId := range findDescendants(root, "w:numId") {
+			format := ""
+			for _, num := range findChildren(numberingRoot, "w:num") {
+				if num.Attrs["w:numId"] != numId.Attrs["w:val"] {
+					continue
+				}
+				for _, abstractNum := range findChildren(numberingRoot, "w:abstractNum") {
+					if abstractNum.Attrs["w:abstractNumId"] == findChild(num, "w:abstractNumId").Attrs["w:val"] {
+						format = findDescendants(abstractNum, "w:numFmt")[0].Attrs["w:val"]
+					}
+				}
+			}
+			formats = append(formats, getParagraphText(findAncestor(numId, P_TAG))+"="+format)
+		}
+		if expected := []string{"Main item=decimal", "Outer item=bullet", "Inner item=upperLetter"}; !slices.Equal(formats, expected) {
+			t.Errorf("Unexpected numbering of the nested includes:\n%v\n%v", formats, expected)
+		}
+
+		// Footnotes and comments of the included template, renumbered after those of the report
+		footnotesXml := func(notes string) []byte {
+			return []byte(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+			<w:footnotes xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">
+				<w:footnote w:type="separator" w:id="-1"><w:p><w:r><w:separator/></w:r></w:p></w:footnote>
+				<w:footnote w:type="continuationSeparator" w:id="0"><w:p><w:r><w:continuationSeparator/></w:r></w:p></w:footnote>` + notes + `
+			</w:footnotes>`)
+		}
+		notesRels := func(rels string) []byte {
+			return []byte(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+			<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">
+				<Relationship Id="rId3" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/footnotes" Target="footnotes.xml"/>` + rels + `
+			</Relationships>`)
+		}
+		err = createTestDocxWithFiles([]byte(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+		<w:document xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main">
+			<w:body>
+				<w:p><w:r><w:t>Included</w:t></w:r><w:r><w:footnoteReference w:id="1"/></w:r></w:p>
+				<w:p><w:commentRangeStart w:id="0"/><w:r><w:t>Reviewed</w:t></w:r><w:commentRangeEnd w:id="0"/><w:r><w:commentReference w:id="0"/></w:r></w:p>
+				<w:p><w:r><w:t>+++COMMENT 'Generated'+++Checked+++END-COMMENT+++</w:t></w:r></w:p>
+			</w:body>
+		</w:document>`), map[string][]byte{
+			"word/_rels/document.xml.rels": notesRels(`<Relationship Id="rId4" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/comments" Target="comments.xml"/>`),
+			"word/footnotes.xml":           footnotesXml(`<w:footnote w:id="1"><w:p><w:hyperlink r:id="rId1"><w:r><w:t>Included note</w:t></w:r></w:hyperlink></w:p></w:footnote>`),
+			"word/_rels/footnotes.xml.rels": []byte(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+			<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">
+				<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/hyperlink" Target="https://example.com/note" TargetMode="External"/>
+			</Relationships>`),
+			"word/comments.xml": []byte(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+			<w:comments xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main">
+				<w:comment w:id="0" w:author="Reviewer"><w:p><w:r><w:t>Included comment</w:t></w:r></w:p></w:comment>
+			</w:comments>`),
+		}, "test_include_notes.docx")
+		if err != nil {
+			t.Fatalf("Failed to create included template: %v", err)
+		}
+		defer os.Remove("test_include_notes.docx")
+		err = createTestDocxWithFiles([]byte(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+		<w:document xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main">
+			<w:body>
+				<w:p><w:r><w:t>Host</w:t></w:r><w:r><w:footnoteReference w:id="1"/></w:r></w:p>
+				<w:p><w:r><w:t>+++INCLUDE 'test_include_notes.docx'+++</w:t></w:r></w:p>
+			</w:body>
+		</w:document>`), map[string][]byte{
+			"word/_rels/document.xml.rels": notesRels(""),
+			"word/footnotes.xml":           footnotesXml(`<w:footnote w:id="1"><w:p><w:r><w:t>Host note</w:t></w:r></w:p></w:footnote>`),
+		}, "test_include_host.docx")
+		if err != nil {
+			t.Fatalf("Failed to create test template: %v", err)
+		}
+		defer os.Remove("test_include_host.docx")
+		outBuf, err = CreateReport("test_include_host.docx", &data, options)
+		if err != nil {
+			t.Fatalf("CreateReport failed: %v", err)
+		}
+		outputZip, err = zip.NewReader(bytes.NewReader(outBuf), int64(len(outBuf)))
+		if err != nil {
+			t.Fatalf("Failed to open output: %v", err)
+		}
+		for _, f := range outputZip.File {
+			rc, _ := f.Open()
+			content, _ := io.ReadAll(rc)
+			rc.Close()
+			files[f.Name] = string(content)
+		}
+		if notes := checkNoteReferences(t, files, "word/footnotes.xml", "w:footnote", "w:footnoteReference"); !maps.Equal(notes, map[string]string{"Host": "Host note", "Included": "Included note"}) {
+			t.Errorf("Unexpected footnotes %v", notes)
+		}
+		if notes := checkNoteReferences(t, files, "word/comments.xml", "w:comment", "w:commentReference"); !maps.Equal(notes, map[string]string{"Reviewed": "Included comment", "Checked": "Generated"}) {
+			t.Errorf("Unexpected comments %v", notes)
+		}
+		if strings.Count(files["word/footnotes.xml"], "w:separator") != 1 || !strings.Contains(files["word/_rels/footnotes.xml.rels"], "https://example.com/note") ||
+			!strings.Contains(files["[Content_Types].xml"], "/word/comments.xml") {
+			t.Errorf("Unexpected notes parts: %s\n%s", files["word/footnotes.xml"], files["word/_rels/footnotes.xml.rels"])
+		}
+
+		// Recursive inclusion
+		err = createTestDocx([]byte(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+		<w:document xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main">
+			<w:body><w:p><w:r><w:t>+++INCLUDE 'test_include_loop.docx'+++</w:t></w:r></w:p></w:body>
+		</w:document>`), "test_include_loop.docx")
+		if err != nil {
+			t.Fatalf("Failed to create test template: %v", err)
+		}
+		defer os.Remove("test_include_loop.docx")
+		_, err = CreateReport("test_include_loop.docx", &data, options)
+		if err == nil || !strings.Contains(err.Error(), "Recursive inclusion") {
+			t.Errorf("Expected a recursive inclusion error, got %v", err)
+		}
+	})
+
+	t.Run("merge documents", func(t *testing.T) {
+		pngData := new(bytes.Buffer)
+		if err := png.Encode(pngData, image.NewRGBA(image.Rect(0, 0, 4, 4))); err != nil {
+			t.Fatalf("Failed to encode PNG: %v", err)
+		}
+		err := createTestDocxWithFiles([]byte(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+		<w:document xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main">
+			<w:body>
+				<w:p><w:r><w:t>Dear +++name+++,</w:t></w:r></w:p>
+				<w:p><w:pPr><w:numPr><w:ilvl w:val="0"/><w:numId w:val="1"/></w:numPr></w:pPr><w:r><w:t>+++LINK link+++</w:t></w:r></w:p>
+				<w:p><w:r><w:t>+++IMAGE logo+++</w:t></w:r></w:p>
+				<w:sectPr><w:pgSz w:w="11906" w:h="16838"/></w:sectPr>
+			</w:body>
+		</w:document>`), map[string][]byte{
+			"word/_rels/document.xml.rels": []byte(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+			<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">
+				<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/numbering" Target="numbering.xml"/>
+			</Relationships>`),
+			"word/numbering.xml": []byte(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+			<w:numbering xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main">
+				<w:abstractNum w:abstractNumId="0"><w:lvl w:ilvl="0"><w:numFmt w:val="decimal"/></w:lvl></w:abstractNum>
+				<w:num w:numId="1"><w:abstractNumId w:val="0"/></w:num>
+			</w:numbering>`),
+		}, "test_template_merge.docx")
+		if err != nil {
+			t.Fatalf("Failed to create test template: %v", err)
+		}
+		defer os.Remove("test_template_merge.docx")
+
+		options := CreateReportOptions{LiteralXmlDelimiter: "||"}
+		data := []*ReportData{}
+		for _, name := range []string{"Alice", "Bob", "Carol"} {
+			data = append(data, &ReportData{
+				"name": name,
+				"link": &LinkPars{Url: "https://example.com/" + name, Label: "Account"},
+				"logo": &ImagePars{Data: pngData.Bytes(), Extension: ".png", Width: 1},
+			})
+		}
+		readOutput := func(outBuf []byte) map[string]string {
+			outputZip, err := zip.NewReader(bytes.NewReader(outBuf), int64(len(outBuf)))
+			if err != nil {
+				t.Fatalf("Failed to open output: %v", err)
+			}
+			files := map[string]string{}
+			for _, f := range outputZip.File {
+				rc, _ := f.Open()
+				content, _ := io.ReadAll(rc)
+				rc.Close()
+				files[f.Name] = string(content)
+			}
+			return files
+		}
+
+		outBuf, err := CreateMergedReport("test_template_merge.docx", data, options, MergeOptions{})
+		if err != nil {
+			t.Fatalf("CreateMergedReport failed: %v", err)
+		}
+		files := readOutput(outBuf)
+		document := files["word/document.xml"]
+		for _, expected := range []string{"Dear Alice,", "Dear Bob,", "Dear Carol,", `id="1"`, `id="2"`, `id="3"`, `<w:numId w:val="3"/>`} {
+			if !strings.Contains(document, expected) {
+				t.Errorf("Expected %s in %s", expected, document)
+			}
+		}
+		if strings.Count(document, `<w:br w:type="page"/>`) != 2 || strings.Count(document, "<w:sectPr") != 1 {
+			t.Errorf("Unexpected document content: %s", document)
+		}
+		media := 0
+		for name := range files {
+			if strings.HasPrefix(name, "word/media/") {
+				media++
+			}
+		}
+		rels := files["word/_rels/document.xml.rels"]
+		relIds := regexp.MustCompile(`Id="([^"]+)"`).FindAllStringSubmatch(rels, -1)
+		uniqueRelIds := map[string]bool{}
+		for _, relId := range relIds {
+			uniqueRelIds[relId[1]] = true
+		}
+		if media != 1 || len(uniqueRelIds) != len(relIds) || !strings.Contains(rels, "https://example.com/Carol") {
+			t.Errorf("Unexpected media (%d) or relationships: %s", media, rels)
+		}
+
+		outBuf, err = CreateMergedReport("test_template_merge.docx", data[:2], options, MergeOptions{Separator: "section"})
+		if err != nil {
+			t.Fatalf("CreateMergedReport failed: %v", err)
+		}
+		document = readOutput(outBuf)["word/document.xml"]
+		if strings.Count(document, "<w:sectPr") != 2 || !regexp.MustCompile(`<w:pPr>\s*<w:sectPr>`).MatchString(document) {
+			t.Errorf("Expected a section per document: %s", document)
+		}
+		if _, err := MergeDocuments(nil, MergeOptions{}); err == nil {
+			t.Errorf("Expected an error without documents")
+		}
+
+		// The footnotes and comments of each document are kept, with new ids
+		documents := [][]byte{}
+		for _, name := range []string{"A", "B"} {
+			err := createTestDocxWithFiles([]byte(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+			<w:document xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main">
+				<w:body>
+					<w:p><w:r><w:t>Text `+name+`</w:t></w:r><w:r><w:footnoteReference w:id="1"/></w:r></w:p>
+					<w:p><w:commentRangeStart w:id="0"/><w:r><w:t>Clause `+name+`</w:t></w:r><w:commentRangeEnd w:id="0"/><w:r><w:commentReference w:id="0"/></w:r></w:p>
+				</w:body>
+			</w:document>`), map[string][]byte{
+				"word/_rels/document.xml.rels": []byte(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+				<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">
+					<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/footnotes" Target="footnotes.xml"/>
+					<Relationship Id="rId2" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/comments" Target="comments.xml"/>
+				</Relationships>`),
+				"word/footnotes.xml": []byte(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+				<w:footnotes xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main">
+					<w:footnote w:type="separator" w:id="-1"><w:p><w:r><w:separator/></w:r></w:p></w:footnote>
+					<w:footnote w:id="1"><w:p><w:r><w:t>Note ` + name + `</w:t></w:r></w:p></w:footnote>
+				</w:footnotes>`),
+				"word/comments.xml": []byte(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+				<w:comments xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main">
+					<w:comment w:id="0" w:author="Reviewer"><w:p><w:r><w:t>Comment ` + name + `</w:t></w:r></w:p></w:comment>
+				</w:comments>`),
+			}, "test_merge_notes.docx")
+			if err != nil {
+				t.Fatalf("Failed to create test document: %v", err)
+			}
+			document, err := os.ReadFile("test_merge_notes.docx")
+			os.Remove("test_merge_notes.docx")
+			if err != nil {
+				t.Fatalf("Failed to read test document: %v", err)
+			}
+			documents = append(documents, document)
+		}
+		outBuf, err = MergeDocuments(documents, MergeOptions{})
+		if err != nil {
+			t.Fatalf("MergeDocuments failed: %v", err)
+		}
+		files = readOutput(outBuf)
+		if notes := checkNoteReferences(t, files, "word/footnotes.xml", "w:footnote", "w:footnoteReference"); !maps.Equal(notes, map[string]string{"Text A": "Note A", "Text B": "Note B"}) {
+			t.Errorf("Unexpected footnotes %v", notes)
+		}
+		if notes := checkNoteReferences(t, files, "word/comments.xml", "w:comment", "w:commentReference"); !maps.Equal(notes, map[string]string{"Clause A": "Comment A", "Clause B": "Comment B"}) {
+			t.Errorf("Unexpected comments %v", notes)
+		}
+	})
+
+	t.Run("render each", func(t *testing.T) {
+		err := createTestDocxWithFiles([]byte(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+		<w:document xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main">
+			<w:body>
+				<w:p><w:r><w:t>Dear +++customer.name+++,</w:t></w:r></w:p>
+				<w:p><w:r><w:t>+++FOR order IN customer.orders+++</w:t></w:r></w:p>
+				<w:p><w:r><w:t>Order +++$order+++</w:t></w:r></w:p>
+				<w:p><w:r><w:t>+++END-FOR order+++</w:t></w:r></w:p>
+				<w:p><w:r><w:t>+++IF customer.vip+++</w:t></w:r></w:p>
+				<w:p><w:r><w:t>VIP customer</w:t></w:r></w:p>
+				<w:p><w:r><w:t>+++END-IF+++</w:t></w:r></w:p>
+			</w:body>
+		</w:document>`), map[string][]byte{
+			"word/header1.xml": []byte(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+			<w:hdr xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main"><w:p><w:r><w:t>Ref +++customer.id+++</w:t></w:r></w:p></w:hdr>`),
+		}, "test_template_render_each.docx")
+		if err != nil {
+			t.Fatalf("Failed to create test template: %v", err)
+		}
+		defer os.Remove("test_template_render_each.docx")
+
+		template, err := NewTemplate("test_template_render_each.docx", CreateReportOptions{LiteralXmlDelimiter: "||"})
+		if err != nil {
+			t.Fatalf("NewTemplate failed: %v", err)
+		}
+		items := []ReportData{
+			{"customer": map[string]any{"id": "C1", "name": "Alice", "orders": []any{"A-1", "A-2"}, "vip": true}},
+			{"customer": map[string]any{"id": "C2", "name": "Bob", "orders": []any{"B-1"}, "vip": false}},
+		}
+		names := []string{}
+		for report, err := range template.RenderEach(items, "customer.id") {
+			if err != nil {
+				t.Fatalf("RenderEach failed: %v", err)
+			}
+			names = append(names, report.Name)
+			outputZip, err := zip.NewReader(bytes.NewReader(report.Data), int64(len(report.Data)))
+			if err != nil {
+				t.Fatalf("Failed to open output: %v", err)
+			}
+			content := ""
+			for _, f := range outputZip.File {
+				rc, _ := f.Open()
+				data, _ := io.ReadAll(rc)
+				rc.Close()
+				content += string(data)
+			}
+			customer := items[len(names)-1]["customer"].(map[string]any)
+			if !strings.Contains(content, "Dear "+customer["name"].(string)) || !strings.Contains(content, "Ref "+customer["id"].(string)) ||
+				strings.Count(content, "Order ") != len(customer["orders"].([]any)) {
+				t.Errorf("Unexpected content for %s: %s", report.Name, content)
+			}
+		}
+		if !slices.Equal(names, []string{"C1.docx", "C2.docx"}) {
+			t.Errorf("Unexpected report names %v", names)
+		}
+
+		out := new(bytes.Buffer)
+		if err := template.WriteZip(out, items, ""); err != nil {
+			t.Fatalf("WriteZip failed: %v", err)
+		}
+		outputZip, err := zip.NewReader(bytes.NewReader(out.Bytes()), int64(out.Len()))
+		if err != nil || len(outputZip.File) != 2 || outputZip.File[1].Name != "report2.docx" {
+			t.Errorf("Unexpected zip of reports: %v", err)
+		}
+		if err := template.WriteZip(io.Discard, items, "'same'"); err == nil {
+			t.Errorf("Expected a duplicate name error")
+		}
+
+		// Concurrent rendering (run with -race), from a template not rendered yet
+		template, err = NewTemplate("test_template_render_each.docx", CreateReportOptions{LiteralXmlDelimiter: "||"})
+		if err != nil {
+			t.Fatalf("NewTemplate failed: %v", err)
+		}
+		var wg sync.WaitGroup
+		for i := range 8 {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				id := fmt.Sprintf("P%d", i)
+				orders := []any{}
+				for j := range i + 1 {
+					orders = append(orders, fmt.Sprintf("%s-%d", id, j))
+				}
+				data := ReportData{"customer": map[string]any{"id": id, "name": "Customer " + id, "orders": orders, "vip": i%2 == 0}}
+				output, err := template.Render(&data)
+				if err != nil {
+					t.Errorf("Render failed: %v", err)
+					return
+				}
+				outputZip, err := zip.NewReader(bytes.NewReader(output), int64(len(output)))
+				if err != nil {
+					t.Errorf("Failed to open output: %v", err)
+					return
+				}
+				content := ""
+				for _, f := range outputZip.File {
+					rc, _ := f.Open()
+					data, _ := io.ReadAll(rc)
+					rc.Close()
+					content += string(data)
+				}
+				if !strings.Contains(content, "Dear Customer "+id+",") || !strings.Contains(content, "Ref "+id+"<") ||
+					strings.Count(content, "Order "+id+"-") != i+1 || strings.Contains(content, "VIP customer") != (i%2 == 0) {
+					t.Errorf("Unexpected content for %s: %s", id, content)
+				}
+			}()
+		}
+		wg.Wait()
+	})
+
+	t.Run("breaks", func(t *testing.T) {
+		err := createTestDocx([]byte(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+		<w:document xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">
+			<w:body>
+				<w:p><w:r><w:t>+++FOR customer IN customers+++</w:t></w:r></w:p>
+				<w:p><w:r><w:t>Customer +++$customer.name+++</w:t></w:r><w:r><w:t>+++COLUMNBREAK+++</w:t></w:r></w:p>
+				<w:p><w:r><w:t>+++FOR line IN $customer.lines+++</w:t></w:r></w:p>
+				<w:p><w:r><w:t>Line +++$line+++</w:t></w:r></w:p>
+				<w:p><w:r><w:t>+++END-FOR line+++</w:t></w:r></w:p>
+				<w:p><w:r><w:t>Total due+++PAGEBREAK !$last+++</w:t></w:r></w:p>
+				<w:p><w:r><w:t>+++END-FOR customer+++</w:t></w:r></w:p>
+				<w:p><w:pPr><w:jc w:val="center"/></w:pPr><w:r><w:t>+++SECTIONBREAK continuous landscape+++</w:t></w:r></w:p>
+				<w:p><w:r><w:t>Summary</w:t></w:r></w:p>
+				<w:sectPr><w:headerReference w:type="default" r:id="rId1"/><w:pgSz w:w="11906" w:h="16838"/></w:sectPr>
+			</w:body>
+		</w:document>`), "test_template_breaks.docx")
+		if err != nil {
+			t.Fatalf("Failed to create test template: %v", err)
+		}
+		defer os.Remove("test_template_breaks.docx")
+
+		customers := []any{
+			map[string]any{"name": "A", "lines": []any{"A1", "A2"}},
+			map[string]any{"name": "B", "lines": []any{"B1"}},
+			map[string]any{"name": "C", "lines": []any{"C1", "C2"}},
+		}
+		report, err := CreateReport("test_template_breaks.docx", &ReportData{"customers": customers}, CreateReportOptions{LiteralXmlDelimiter: "||"})
+		if err != nil {
+			t.Fatalf("CreateReport failed: %v", err)
+		}
+		outputZip, err := zip.NewReader(bytes.NewReader(report), int64(len(report)))
+		if err != nil {
+			t.Fatalf("Failed to open output: %v", err)
+		}
+		var document []byte
+		for _, f := range outputZip.File {
+			if f.Name == "word/document.xml" {
+				rc, _ := f.Open()
+				document, _ = io.ReadAll(rc)
+				rc.Close()
+			}
+		}
+		root, err := ParseXml(string(document))
+		if err != nil {
+			t.Fatalf("Failed to parse output: %v", err)
+		}
+		breaks := map[string]int{}
+		for _, br := range findDescendants(root, "w:br") {
+			breaks[br.Attrs["w:type"]]++
+		}
+		if breaks["page"] != 2 || breaks["column"] != 3 {
+			t.Errorf("Unexpected breaks %v: %s", breaks, document)
+		}
+		for _, br := range findDescendants(root, "w:br") {
+			if p := findAncestor(br, P_TAG); br.Attrs["w:type"] == "page" && getParagraphText(p) != "Total due" {
+				t.Errorf("Unexpected page break paragraph: %s", BuildXml(p, XmlOptions{}, ""))
+			}
+		}
+
+		sections := findDescendants(root, "w:sectPr")
+		if len(sections) != 2 {
+			t.Fatalf("Expected 2 sections, got %d: %s", len(sections), document)
+		}
+		first, last := sections[0], sections[1]
+		if p := findAncestor(first, P_TAG); p == nil || findChild(findChild(p, "w:pPr"), "w:jc") == nil {
+			t.Errorf("Section break not in the paragraph properties: %s", document)
+		}
+		if findChild(first, "w:headerReference") == nil || findChild(first, "w:pgSz").Attrs["w:orient"] != "" || findChild(first, "w:type") != nil {
+			t.Errorf("Unexpected first section: %s", document)
+		}
+		pgSz := findChild(last, "w:pgSz")
+		if pgSz.Attrs["w:orient"] != "landscape" || pgSz.Attrs["w:w"] != "16838" || pgSz.Attrs["w:h"] != "11906" {
+			t.Errorf("Unexpected page size of the last section: %v", pgSz.Attrs)
+		}
+		if sectType := findChild(last, "w:type"); sectType == nil || sectType.Attrs["w:val"] != "continuous" {
+			t.Errorf("Unexpected type of the last section: %s", document)
+		}
+		if !strings.Contains(string(document), "Summary") {
+			t.Errorf("Missing content after the section break: %s", document)
+		}
+	})
+
+	t.Run("table of contents", func(t *testing.T) {
+		documentXml := []byte(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+		<w:document xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main">
+			<w:body>
+				<w:sdt><w:sdtContent>
+					<w:p><w:pPr><w:pStyle w:val="TOCHeading"/></w:pPr><w:r><w:t>Contents</w:t></w:r></w:p>
+					<w:p><w:r><w:fldChar w:fldCharType="begin"/></w:r><w:r><w:instrText xml:space="preserve"> TOC \o "1-2" </w:instrText></w:r><w:r><w:instrText xml:space="preserve">\h \z \u </w:instrText></w:r><w:r><w:fldChar w:fldCharType="separate"/></w:r><w:r><w:t>Stale entry</w:t></w:r></w:p>
+					<w:p><w:r><w:fldChar w:fldCharType="end"/></w:r></w:p>
+				</w:sdtContent></w:sdt>
+				<w:p><w:r><w:t>+++FOR chapter IN chapters+++</w:t></w:r></w:p>
+				<w:p><w:pPr><w:pStyle w:val="Heading1"/></w:pPr><w:r><w:t>+++$chapter.title+++</w:t></w:r></w:p>
+				<w:p><w:pPr><w:pStyle w:val="Section"/></w:pPr><w:r><w:t>+++$chapter.section+++</w:t></w:r></w:p>
+				<w:p><w:pPr><w:pStyle w:val="Heading3"/></w:pPr><w:r><w:t>Too deep</w:t></w:r></w:p>
+				<w:p><w:r><w:t>+++END-FOR chapter+++</w:t></w:r></w:p>
+				<w:sectPr><w:pgSz w:w="11906" w:h="16838"/><w:pgMar w:left="1000" w:right="906"/></w:sectPr>
+			</w:body>
+		</w:document>`)
+		err := createTestDocxWithFiles(documentXml, map[string][]byte{
+			"word/_rels/document.xml.rels": []byte(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+			<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">
+				<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/styles" Target="styles.xml"/>
+				<Relationship Id="rId2" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/settings" Target="settings.xml"/>
+			</Relationships>`),
+			"word/styles.xml": []byte(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+			<w:styles xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main">
+				<w:style w:type="paragraph" w:styleId="Heading1"><w:name w:val="heading 1"/></w:style>
+				<w:style w:type="paragraph" w:styleId="Heading3"><w:name w:val="heading 3"/></w:style>
+				<w:style w:type="paragraph" w:styleId="Section"><w:name w:val="Section"/><w:pPr><w:outlineLvl w:val="1"/></w:pPr></w:style>
+			</w:styles>`),
+			"word/settings.xml": []byte(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+			<w:settings xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main"><w:zoom w:percent="100"/><w:compat/></w:settings>`),
+		}, "test_template_toc.docx")
+		if err != nil {
+			t.Fatalf("Failed to create test template: %v", err)
+		}
+		defer os.Remove("test_template_toc.docx")
+
+		data := &ReportData{"chapters": []any{
+			map[string]any{"title": "Introduction", "section": "Scope"},
+			map[string]any{"title": "Results", "section": "Measures"},
+		}}
+		report, err := CreateReport("test_template_toc.docx", data, CreateReportOptions{LiteralXmlDelimiter: "||", UpdateFields: true, UpdateToc: true})
+		if err != nil {
+			t.Fatalf("CreateReport failed: %v", err)
+		}
+		files := map[string]Node{}
+		outputZip, err := zip.NewReader(bytes.NewReader(report), int64(len(report)))
+		if err != nil {
+			t.Fatalf("Failed to open output: %v", err)
+		}
+		for _, f := range outputZip.File {
+			rc, _ := f.Open()
+			content, _ := io.ReadAll(rc)
+			rc.Close()
+			if files[f.Name], err = ParseXml(string(content)); err != nil {
+				t.Fatalf("Failed to parse %s: %v", f.Name, err)
+			}
+		}
+
+		settings := files["word/settings.xml"]
+		tags := []string{}
+		for _, child := range settings.Children() {
+			if nonTextChild, ok := child.(*NonTextNode); ok {
+				tags = append(tags, nonTextChild.Tag)
+			}
+		}
+		if !slices.Equal(tags, []string{"w:zoom", "w:updateFields", "w:compat"}) {
+			t.Errorf("Unexpected settings %v", tags)
+		}
+
+		document := files["word/document.xml"]
+		sdtContent := findDescendants(document, "w:sdtContent")[0]
+		entries := []string{}
+		for _, p := range findChildren(sdtContent, P_TAG)[1:] {
+			hyperlink := findChild(p, "w:hyperlink")
+			if hyperlink == nil {
+				t.Fatalf("Missing hyperlink in TOC entry: %s", BuildXml(p, XmlOptions{}, ""))
+			}
+			style := findChild(findChild(p, "w:pPr"), "w:pStyle").Attrs["w:val"]
+			entries = append(entries, style+":"+getParagraphText(p))
+			bookmarks := slices.DeleteFunc(findDescendants(document, "w:bookmarkStart"), func(bookmark *NonTextNode) bool {
+				return bookmark.Attrs["w:name"] != hyperlink.Attrs["w:anchor"]
+			})
+			if len(bookmarks) != 1 || !strings.Contains(getParagraphText(bookmarks[0].Parent()), strings.TrimSuffix(getParagraphText(p), TOC_PAGE_PLACEHOLDER)) {
+				t.Errorf("No heading bookmark for %s", hyperlink.Attrs["w:anchor"])
+			}
+		}
+		expected := []string{"TOC1:Introduction#", "TOC2:Scope#", "TOC1:Results#", "TOC2:Measures#"}
+		if !slices.Equal(entries, expected) {
+			t.Errorf("Unexpected TOC entries %v", entries)
+		}
+		fields := findFields(document)
+		if len(fields) != 5 || !strings.Contains(fields[4].instruction, "TOC") || findAncestor(fields[4].end, P_TAG) != findChildren(sdtContent, P_TAG)[4] {
+			t.Errorf("Unexpected TOC fields: %d", len(fields))
+		}
+		if tab := findDescendants(sdtContent, "w:tab")[0]; tab.Attrs["w:pos"] != "10000" {
+			t.Errorf("Unexpected TOC tab position %s", tab.Attrs["w:pos"])
+		}
+
+		// Without settings part
+		err = createTestDocx(documentXml, "test_template_toc_nosettings.docx")
+		if err != nil {
+			t.Fatalf("Failed to create test template: %v", err)
+		}
+		defer os.Remove("test_template_toc_nosettings.docx")
+		report, err = CreateReport("test_template_toc_nosettings.docx", data, CreateReportOptions{LiteralXmlDelimiter: "||", UpdateFields: true})
+		if err != nil {
+			t.Fatalf("CreateReport failed: %v", err)
+		}
+		outputZip, err = zip.NewReader(bytes.NewReader(report), int64(len(report)))
+		if err != nil {
+			t.Fatalf("Failed to open output: %v", err)
+		}
+		content := ""
+		for _, f := range outputZip.File {
+			rc, _ := f.Open()
+			data, _ := io.ReadAll(rc)
+			rc.Close()
+			content += string(data)
+		}
+		if !strings.Contains(content, `<w:updateFields w:val="true"/>`) || !strings.Contains(content, `Target="settings.xml"`) ||
+			!strings.Contains(content, SETTINGS_CONTENT_TYPE) || !strings.Contains(content, "Stale entry") {
+			t.Errorf("Unexpected report without settings: %s", content)
+		}
+	})
+
+	t.Run("bookmarks", func(t *testing.T) {
+		err := createTestDocx([]byte(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+		<w:document xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main">
+			<w:body>
+				<w:p><w:r><w:t>See +++LINK-TO item_1 'the second item'+++ on page +++PAGEREF item_1+++, and +++LINK-TO code_${code}+++</w:t></w:r></w:p>
+				<w:p><w:r><w:t>See page +++PAGEREF details+++ for details</w:t></w:r></w:p>
+				<w:p><w:r><w:t>+++FOR item IN items+++</w:t></w:r></w:p>
+				<w:p><w:pPr><w:pStyle w:val="Heading1"/></w:pPr><w:r><w:t>+++BOOKMARK item_$idx++++++$item+++</w:t></w:r></w:p>
+				<w:p><w:bookmarkStart w:id="0" w:name="details"/><w:r><w:t>Details</w:t></w:r><w:bookmarkEnd w:id="0"/></w:p>
+				<w:p><w:r><w:t>+++END-FOR item+++</w:t></w:r></w:p>
+				<w:p><w:r><w:t>+++BOOKMARK code_${code}+++Code</w:t></w:r></w:p>
+			</w:body>
+		</w:document>`), "test_template_bookmarks.docx")
+		if err != nil {
+			t.Fatalf("Failed to create test template: %v", err)
+		}
+		defer os.Remove("test_template_bookmarks.docx")
+
+		report, err := CreateReport("test_template_bookmarks.docx", &ReportData{"items": []any{"First", "Second"}, "code": "A 1"}, CreateReportOptions{LiteralXmlDelimiter: "||"})
+		if err != nil {
+			t.Fatalf("CreateReport failed: %v", err)
+		}
+		outputZip, err := zip.NewReader(bytes.NewReader(report), int64(len(report)))
+		if err != nil {
+			t.Fatalf("Failed to open output: %v", err)
+		}
+		var document []byte
+		for _, f := range outputZip.File {
+			if f.Name == "word/document.xml" {
+				rc, _ := f.Open()
+				document, _ = io.ReadAll(rc)
+				rc.Close()
+			}
+		}
+		root, err := ParseXml(string(document))
+		if err != nil {
+			t.Fatalf("Failed to parse output: %v", err)
+		}
+
+		bookmarks := map[string]string{} // [name]paragraph text
+		ids := map[string]bool{}
+		for _, start := range findDescendants(root, "w:bookmarkStart") {
+			if ids[start.Attrs["w:id"]] {
+				t.Errorf("Duplicate bookmark id %s", start.Attrs["w:id"])
+			}
+			ids[start.Attrs["w:id"]] = true
+			bookmarks[start.Attrs["w:name"]] = getParagraphText(start.Parent())
+			ends := slices.DeleteFunc(findDescendants(start.Parent(), "w:bookmarkEnd"), func(end *NonTextNode) bool { return end.Attrs["w:id"] != start.Attrs["w:id"] })
+			if len(ends) != 1 {
+				t.Errorf("No bookmark end for %s", start.Attrs["w:name"])
+			}
+		}
+		expected := map[string]string{"item_0": "First", "item_1": "Second", "details": "Details", "details_2": "Details", "code_A_1": "Code"}
+		if !maps.Equal(bookmarks, expected) {
+			t.Errorf("Unexpected bookmarks %v", bookmarks)
+		}
+
+		hyperlinks := findDescendants(root, "w:hyperlink")
+		if len(hyperlinks) != 2 || hyperlinks[0].Attrs["w:anchor"] != "item_1" || getParagraphText(hyperlinks[0]) != "the second item" ||
+			hyperlinks[1].Attrs["w:anchor"] != "code_A_1" || getParagraphText(hyperlinks[1]) != "code_A_1" || hyperlinks[0].Attrs["r:id"] != "" {
+			t.Errorf("Unexpected internal links: %s", document)
+		}
+		if text := getParagraphText(findDescendants(root, P_TAG)[0]); text != "See the second item on page #, and code_A_1" {
+			t.Errorf("Unexpected paragraph text %q", text)
+		}
+		if text := getParagraphText(findDescendants(root, P_TAG)[1]); text != "See page # for details" {
+			t.Errorf("Unexpected paragraph text %q", text)
+		}
+		fields := findDescendants(root, "w:fldSimple")
+		if len(fields) != 2 || fields[0].Attrs["w:instr"] != ` PAGEREF item_1 \h ` || fields[1].Attrs["w:instr"] != ` PAGEREF details \h ` {
+			t.Errorf("Unexpected PAGEREF field: %s", document)
+		}
+	})
+
+	t.Run("fields", func(t *testing.T) {
+		err := createTestDocx([]byte(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+		<w:document xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main">
+			<w:body>
+				<w:p><w:r><w:t xml:space="preserve">See </w:t></w:r><w:r><w:t>+++FIELD REF intro \h+++</w:t></w:r></w:p>
+				<w:p><w:r><w:rPr><w:i/></w:rPr><w:t>Page +++FIELD PAGE+++ of +++FIELD NUMPAGES+++</w:t></w:r></w:p>
+				<w:p><w:r><w:t>+++BOOKMARK intro+++Introduction</w:t></w:r></w:p>
+				<w:p><w:r><w:t>+++FOR figure IN figures+++</w:t></w:r></w:p>
+				<w:p><w:r><w:rPr><w:b/></w:rPr><w:t>Figure +++FIELD SEQ Figure \* ARABIC+++: +++$figure+++</w:t></w:r></w:p>
+				<w:p><w:r><w:t>+++END-FOR figure+++</w:t></w:r></w:p>
+				<w:p><w:r><w:t>+++FIELD SEQ Figure \c \* ROMAN+++</w:t></w:r></w:p>
+				<w:p><w:r><w:t>+++FIELD SEQ Table \r 27 \* alphabetic+++</w:t></w:r></w:p>
+				<w:p><w:r><w:t>+++FIELD DATE \@ "'Day' d MMMM yyyy"+++</w:t></w:r></w:p>
+				<w:p><w:r><w:t>+++FIELD MERGEFIELD ${field} \* Upper+++</w:t></w:r></w:p>
+			</w:body>
+		</w:document>`), "test_template_fields.docx")
+		if err != nil {
+			t.Fatalf("Failed to create test template: %v", err)
+		}
+		defer os.Remove("test_template_fields.docx")
+
+		data := &ReportData{"figures": []any{"Plan", "Section", "Detail"}, "field": "customer", "customer": "Acme"}
+		report, err := CreateReport("test_template_fields.docx", data, CreateReportOptions{LiteralXmlDelimiter: "||"})
+		if err != nil {
+			t.Fatalf("CreateReport failed: %v", err)
+		}
+		outputZip, err := zip.NewReader(bytes.NewReader(report), int64(len(report)))
+		if err != nil {
+			t.Fatalf("Failed to open output: %v", err)
+		}
+		var document []byte
+		for _, f := range outputZip.File {
+			if f.Name == "word/document.xml" {
+				rc, _ := f.Open()
+				document, _ = io.ReadAll(rc)
+				rc.Close()
+			}
+		}
+		root, err := ParseXml(string(document))
+		if err != nil {
+			t.Fatalf("Failed to parse output: %v", err)
+		}
+
+		results := []string{}
+		for _, field := range findFields(root) {
+			if field.separate == nil {
+				t.Fatalf("Field without result: %s", field.instruction)
+			}
+			separateRun, endRun := field.separate.Parent(), field.end.Parent()
+			runs := separateRun.Parent().Children()
+			result := ""
+			for _, run := range runs[slices.Index(runs, separateRun)+1 : slices.Index(runs, endRun)] {
+				result += getParagraphText(run)
+			}
+			results = append(results, strings.TrimSpace(field.instruction)+"="+result)
+		}
+		expected := []string{
+			`REF intro \h=Introduction`, "PAGE=#", "NUMPAGES=#",
+			`SEQ Figure \* ARABIC=1`, `SEQ Figure \* ARABIC=2`, `SEQ Figure \* ARABIC=3`, `SEQ Figure \c \* ROMAN=III`,
+			`SEQ Table \r 27 \* alphabetic=aa`, `DATE \@ "'Day' d MMMM yyyy"=Day ` + time.Now().Format("2 January 2006"),
+			`MERGEFIELD customer \* Upper=ACME`,
+		}
+		if !slices.Equal(results, expected) {
+			t.Errorf("Unexpected field results:\n%v\n%v", results, expected)
+		}
+
+		for _, field := range findFields(root)[3:6] {
+			for _, fldChar := range []*NonTextNode{field.begin, field.separate, field.end} {
+				if rPr := findChild(fldChar.Parent(), RPR_TAG); rPr == nil || findChild(rPr, "w:b") == nil {
+					t.Errorf("Field run without the command formatting: %s", BuildXml(fldChar.Parent(), XmlOptions{}, ""))
+				}
+			}
+		}
+		paragraphs := findDescendants(root, P_TAG)
+		if text := getParagraphText(paragraphs[1]); text != "Page # of #" {
+			t.Errorf("Unexpected page numbers %q", text)
+		}
+		for _, run := range findChildren(paragraphs[1], R_TAG) {
+			if rPr := findChild(run, RPR_TAG); rPr == nil || findChild(rPr, "w:i") == nil {
+				t.Errorf("Run without the command formatting: %s", BuildXml(run, XmlOptions{}, ""))
+			}
+		}
+		if text := getParagraphText(paragraphs[3]); text != "Figure 1: Plan" {
+			t.Errorf("Unexpected caption %q", text)
+		}
+	})
+
+	t.Run("lists", func(t *testing.T) {
+		err := createTestDocxWithFiles([]byte(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+		<w:document xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main">
+			<w:body>
+				<w:p><w:r><w:t>+++FOR invoice IN invoices+++</w:t></w:r></w:p>
+				<w:p><w:r><w:t>Invoice +++$invoice.name+++</w:t></w:r></w:p>
+				<w:p><w:r><w:t>+++RESTART-NUMBERING+++</w:t></w:r></w:p>
+				<w:p><w:r><w:t>+++FOR item IN $invoice.items+++</w:t></w:r></w:p>
+				<w:p><w:pPr><w:numPr><w:ilvl w:val="0"/><w:numId w:val="1"/></w:numPr></w:pPr><w:r><w:t>+++$item+++</w:t></w:r></w:p>
+				<w:p><w:r><w:t>+++END-FOR item+++</w:t></w:r></w:p>
+				<w:p><w:pPr><w:pStyle w:val="ListNumber"/></w:pPr><w:r><w:t>Total</w:t></w:r></w:p>
+				<w:p><w:r><w:t>+++END-FOR invoice+++</w:t></w:r></w:p>
+				<w:p><w:pPr><w:jc w:val="both"/></w:pPr><w:r><w:rPr><w:i/></w:rPr><w:t>+++LIST number steps+++</w:t></w:r></w:p>
+				<w:p><w:r><w:t>+++LIST tags+++</w:t></w:r></w:p>
+			</w:body>
+		</w:document>`), map[string][]byte{
+			"word/_rels/document.xml.rels": []byte(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+			<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">
+				<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/numbering" Target="numbering.xml"/>
+				<Relationship Id="rId2" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/styles" Target="styles.xml"/>
+			</Relationships>`),
+			"word/numbering.xml": []byte(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+			<w:numbering xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main">
+				<w:abstractNum w:abstractNumId="0"><w:lvl w:ilvl="0"><w:start w:val="1"/><w:numFmt w:val="decimal"/></w:lvl></w:abstractNum>
+				<w:num w:numId="1"><w:abstractNumId w:val="0"/></w:num>
+			</w:numbering>`),
+			"word/styles.xml": []byte(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+			<w:styles xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main">
+				<w:style w:type="paragraph" w:styleId="ListNumber"><w:pPr><w:numPr><w:numId w:val="1"/></w:numPr></w:pPr></w:style>
+			</w:styles>`),
+		}, "test_template_lists.docx")
+		if err != nil {
+			t.Fatalf("Failed to create test template: %v", err)
+		}
+		defer os.Remove("test_template_lists.docx")
+
+		data := &ReportData{
+			"invoices": []any{
+				map[string]any{"name": "A", "items": []any{"Chair", "Desk"}},
+				map[string]any{"name": "B", "items": []any{"Lamp"}},
+			},
+			"steps": []any{"Open", []any{"Unpack", "Check"}, "Install"},
+			"tags":  []string{"new", "sale"},
+		}
+		report, err := CreateReport("test_template_lists.docx", data, CreateReportOptions{LiteralXmlDelimiter: "||"})
+		if err != nil {
+			t.Fatalf("CreateReport failed: %v", err)
+		}
+		outputZip, err := zip.NewReader(bytes.NewReader(report), int64(len(report)))
+		if err != nil {
+			t.Fatalf("Failed to open output: %v", err)
+		}
+		files := map[string]string{}
+		for _, f := range outputZip.File {
+			rc, _ := f.Open()
+			content, _ := io.ReadAll(rc)
+			rc.Close()
+			files[f.Name] = string(content)
+		}
+		root, err := ParseXml(files["word/document.xml"])
+		if err != nil {
+			t.Fatalf("Failed to parse output: %v", err)
+		}
+
+		paragraphs := []string{}
+		for _, p := range findDescendants(root, P_TAG) {
+			numbering := ""
+			if pPr := findChild(p, "w:pPr"); pPr != nil {
+				if numPr := findChild(pPr, "w:numPr"); numPr != nil {
+					numbering = fmt.Sprintf("[%d/%d] ", getIntAttr(findChild(numPr, "w:numId"), "w:val"), getIntAttr(findChild(numPr, "w:ilvl"), "w:val"))
+				}
+			}
+			paragraphs = append(paragraphs, numbering+getParagraphText(p))
+		}
+		expected := []string{
+			"Invoice A", "[2/0] Chair", "[2/0] Desk", "[2/0] Total",
+			"Invoice B", "[3/0] Lamp", "[3/0] Total",
+			"[4/0] Open", "[4/1] Unpack", "[4/1] Check", "[4/0] Install",
+			"[5/0] new", "[5/0] sale",
+		}
+		if !slices.Equal(paragraphs, expected) {
+			t.Errorf("Unexpected paragraphs:\n%v\n%v", paragraphs, expected)
+		}
+		step := findDescendants(root, P_TAG)[7]
+		if pPr := findChild(step, "w:pPr"); findChild(pPr, "w:jc") == nil || findChild(findChild(step, R_TAG), RPR_TAG) == nil {
+			t.Errorf("List item without the command formatting: %s", BuildXml(step, XmlOptions{}, ""))
+		}
+
+		numbering, err := ParseXml(files["word/numbering.xml"])
+		if err != nil {
+			t.Fatalf("Failed to parse numbering: %v", err)
+		}
+		abstractNums, nums := findChildren(numbering, "w:abstractNum"), findChildren(numbering, "w:num")
+		if len(abstractNums) != 3 || len(nums) != 5 {
+			t.Fatalf("Unexpected numbering: %s", files["word/numbering.xml"])
+		}
+		for _, num := range nums[1:3] {
+			overrides := findChildren(num, "w:lvlOverride")
+			if findChild(num, "w:abstractNumId").Attrs["w:val"] != "0" || len(overrides) != 9 || findChild(overrides[0], "w:startOverride").Attrs["w:val"] != "1" {
+				t.Errorf("Unexpected restarted numbering: %s", BuildXml(num, XmlOptions{}, ""))
+			}
+		}
+		formats := []string{}
+		for _, abstractNum := range abstractNums[1:] {
+			lvl := findChildren(abstractNum, "w:lvl")[1]
+			formats = append(formats, findChild(lvl, "w:numFmt").Attrs["w:val"]+" "+findChild(lvl, "w:lvlText").Attrs["w:val"])
+		}
+		if !slices.Equal(formats, []string{"lowerLetter %2.", "bullet ◦"}) {
+			t.Errorf("Unexpected list formats: %v", formats)
+		}
+	})
+
+	t.Run("document properties", func(t *testing.T) {
+		err := createTestDocxWithFiles([]byte(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+		<w:document xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main">
+			<w:body><w:p><w:r><w:t>+++title+++</w:t></w:r></w:p></w:body>
+		</w:document>`), map[string][]byte{
+			"_rels/.rels": []byte(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+			<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">
+				<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="word/document.xml"/>
+				<Relationship Id="rId2" Type="http://schemas.openxmlformats.org/package/2006/relationships/metadata/core-properties" Target="docProps/core.xml"/>
+			</Relationships>`),
+			"docProps/core.xml": []byte(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+			<cp:coreProperties xmlns:cp="http://schemas.openxmlformats.org/package/2006/metadata/core-properties" xmlns:dc="http://purl.org/dc/elements/1.1/" xmlns:dcterms="http://purl.org/dc/terms/" xmlns:xsi="http://www.w3.org/2001/XMLSchema-instance">
+				<dc:creator>Template Author</dc:creator>
+				<dcterms:created xsi:type="dcterms:W3CDTF">2020-01-01T00:00:00Z</dcterms:created>
+			</cp:coreProperties>`),
+		}, "test_template_properties.docx")
+		if err != nil {
+			t.Fatalf("Failed to create test template: %v", err)
+		}
+		defer os.Remove("test_template_properties.docx")
+
+		data := &ReportData{
+			"title": "Contract 42", "author": "Jane Doe", "tags": []string{"contract", "signed"},
+			"date": time.Date(2026, 3, 1, 12, 30, 0, 0, time.UTC), "caseNumber": "C-42", "amount": 1250.5, "confidential": true,
+			"printed": time.Date(2026, 3, 2, 10, 0, 0, 0, time.FixedZone("CET", 3600)), "pages": 12, "views": int64(5000000000), "bytes": uint32(4000000000),
+		}
+		options := CreateReportOptions{
+			LiteralXmlDelimiter: "||",
+			CoreProperties:      map[string]string{"Title": "title", "Author": "author", "Keywords": "tags", "Created": "date", "Modified": "date", "LastPrinted": "printed"},
+			CustomProperties:    map[string]string{"CaseNumber": "caseNumber", "Amount": "amount", "Confidential": "confidential", "Pages": "pages", "Views": "views", "Bytes": "bytes"},
+		}
+		report, err := CreateReport("test_template_properties.docx", data, options)
+		if err != nil {
+			t.Fatalf("CreateReport failed: %v", err)
+		}
+		outputZip, err := zip.NewReader(bytes.NewReader(report), int64(len(report)))
+		if err != nil {
+			t.Fatalf("Failed to open output: %v", err)
+		}
+		files := map[string]string{}
+		for _, f := range outputZip.File {
+			rc, _ := f.Open()
+			content, _ := io.ReadAll(rc)
+			rc.Close()
+			files[f.Name] = string(content)
+		}
+
+		core, err := ParseXml(files["docProps/core.xml"])
+		if err != nil {
+			t.Fatalf("Failed to parse core properties: %v", err)
+		}
+		coreValues := map[string]string{}
+		for _, child := range core.Children() {
+			if property, ok := child.(*NonTextNode); ok {
+				coreValues[property.Tag] = getNodeText(property)
+			}
+		}
+		expectedCore := map[string]string{
+			"dc:title": "Contract 42", "dc:creator": "Jane Doe", "cp:keywords": "contract, signed",
+			"dcterms:created": "2026-03-01T12:30:00Z", "dcterms:modified": "2026-03-01T12:30:00Z", "cp:lastPrinted": "2026-03-02T09:00:00Z",
+		}
+		if !maps.Equal(coreValues, expectedCore) {
+			t.Errorf("Unexpected core properties: %v", coreValues)
+		}
+		if modified := findChild(core, "dcterms:modified"); modified.Attrs["xsi:type"] != "dcterms:W3CDTF" {
+			t.Errorf("Missing date type: %s", files["docProps/core.xml"])
+		}
+
+		custom, err := ParseXml(files["docProps/custom.xml"])
+		if err != nil {
+			t.Fatalf("Failed to parse custom properties: %v", err)
+		}
+		customValues := []string{}
+		for _, property := range findChildren(custom, "property") {
+			var value *NonTextNode
+			for _, child := range property.Children() {
+				if nonTextChild, ok := child.(*NonTextNode); ok {
+					value = nonTextChild
+				}
+			}
+			customValues = append(customValues, fmt.Sprintf("%s %s %s=%s", property.Attrs["pid"], property.Attrs["name"], value.Tag, getNodeText(value)))
+		}
+		expectedCustom := []string{
+			"2 Amount vt:r8=1250.5", "3 Bytes vt:i8=4000000000", "4 CaseNumber vt:lpwstr=C-42", "5 Confidential vt:bool=true",
+			"6 Pages vt:i4=12", "7 Views vt:i8=5000000000",
+		}
+		if !slices.Equal(customValues, expectedCustom) {
+			t.Errorf("Unexpected custom properties: %v", customValues)
+		}
+		if !strings.Contains(files["_rels/.rels"], CUSTOM_PROPERTIES_RELATION_TYPE) || strings.Count(files["_rels/.rels"], CORE_PROPERTIES_RELATION_TYPE) != 1 ||
+			!strings.Contains(files["[Content_Types].xml"], `PartName="/docProps/custom.xml"`) {
+			t.Errorf("Missing custom properties part: %s\n%s", files["_rels/.rels"], files["[Content_Types].xml"])
+		}
+	})
+
+	t.Run("content controls", func(t *testing.T) {
+		err := createTestDocx([]byte(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+		<w:document xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main" xmlns:w14="http://schemas.microsoft.com/office/word/2010/wordml" xmlns:w15="http://schemas.microsoft.com/office/word/2012/wordml">
+			<w:body>
+				<w:sdt><w:sdtPr><w:alias w:val="Client name"/><w:tag w:val="client.name"/><w:showingPlcHdr/></w:sdtPr><w:sdtContent><w:p><w:r><w:rPr><w:rStyle w:val="PlaceholderText"/><w:b/></w:rPr><w:t>Click here</w:t></w:r><w:r><w:t xml:space="preserve"> to enter text.</w:t></w:r></w:p></w:sdtContent></w:sdt>
+				<w:p><w:r><w:t xml:space="preserve">Signed on </w:t></w:r><w:sdt><w:sdtPr><w:tag w:val="signedOn"/><w:date><w:dateFormat w:val="d MMMM yyyy"/></w:date></w:sdtPr><w:sdtContent><w:r><w:t>Date</w:t></w:r></w:sdtContent></w:sdt></w:p>
+				<w:p><w:sdt><w:sdtPr><w:tag w:val="approved"/><w14:checkbox><w14:checked w14:val="0"/><w14:checkedState w14:val="2612"/><w14:uncheckedState w14:val="2610"/></w14:checkbox></w:sdtPr><w:sdtContent><w:r><w:t>☐</w:t></w:r></w:sdtContent></w:sdt></w:p>
+				<w:p><w:sdt><w:sdtPr><w:alias w:val="jurisdiction"/><w:dropDownList><w:listItem w:displayText="England and Wales" w:value="EW"/><w:listItem w:displayText="Scotland" w:value="SC"/></w:dropDownList></w:sdtPr><w:sdtContent><w:r><w:t>Choose an item.</w:t></w:r></w:sdtContent></w:sdt></w:p>
+				<w:sdt><w:sdtPr><w:tag w:val="parties"/><w15:repeatingSection/></w:sdtPr><w:sdtContent>
+					<w:sdt><w:sdtPr><w15:repeatingSectionItem/></w:sdtPr><w:sdtContent>
+						<w:p><w:sdt><w:sdtPr><w:tag w:val="name"/></w:sdtPr><w:sdtContent><w:r><w:t>Name</w:t></w:r></w:sdtContent></w:sdt><w:r><w:t xml:space="preserve">, </w:t></w:r><w:sdt><w:sdtPr><w:tag w:val="client.name"/></w:sdtPr><w:sdtContent><w:r><w:t>Client</w:t></w:r></w:sdtContent></w:sdt></w:p>
+					</w:sdtContent></w:sdt>
+				</w:sdtContent></w:sdt>
+				<w:sdt><w:sdtPr><w:tag w:val="client.name"/></w:sdtPr><w:sdtContent><w:tbl><w:tr><w:tc><w:p><w:r><w:t>A1</w:t></w:r></w:p><w:p><w:r><w:t>A2</w:t></w:r></w:p></w:tc><w:tc><w:p><w:r><w:t>B1</w:t></w:r></w:p></w:tc></w:tr></w:tbl><w:p><w:r><w:t>After</w:t></w:r></w:p></w:sdtContent></w:sdt>
+				<w:sdt><w:sdtPr><w:tag w:val="Signature block"/></w:sdtPr><w:sdtContent><w:p><w:r><w:t>Unchanged</w:t></w:r></w:p></w:sdtContent></w:sdt>
+			</w:body>
+		</w:document>`), "test_template_content_controls.docx")
+		if err != nil {
+			t.Fatalf("Failed to create test template: %v", err)
+		}
+		defer os.Remove("test_template_content_controls.docx")
+
+		data := &ReportData{
+			"client":       map[string]any{"name": "Acme Ltd"},
+			"signedOn":     time.Date(2026, 3, 1, 9, 0, 0, 0, time.FixedZone("CET", 3600)),
+			"approved":     true,
+			"jurisdiction": "SC",
+			"parties":      []any{map[string]any{"name": "Alice"}, map[string]any{"name": "Bob"}},
+		}
+		render := func(unwrap bool) Node {
+			options := CreateReportOptions{LiteralXmlDelimiter: "||", ContentControls: true, UnwrapContentControls: unwrap}
+			report, err := CreateReport("test_template_content_controls.docx", data, options)
+			if err != nil {
+				t.Fatalf("CreateReport failed: %v", err)
+			}
+			outputZip, err := zip.NewReader(bytes.NewReader(report), int64(len(report)))
+			if err != nil {
+				t.Fatalf("Failed to open output: %v", err)
+			}
+			var document []byte
+			for _, f := range outputZip.File {
+				if f.Name == "word/document.xml" {
+					rc, _ := f.Open()
+					document, _ = io.ReadAll(rc)
+					rc.Close()
+				}
+			}
+			root, err := ParseXml(string(document))
+			if err != nil {
+				t.Fatalf("Failed to parse output: %v", err)
+			}
+			return root
+		}
+		expected := []string{"Acme Ltd", "Signed on 1 March 2026", "☒", "Scotland", "Alice, Acme Ltd", "Bob, Acme Ltd", "Acme Ltd", "", "Unchanged"}
+
+		for _, unwrap := range []bool{false, true} {
+			root := render(unwrap)
+			paragraphs := []string{}
+			for _, p := range findDescendants(root, P_TAG) {
+				paragraphs = append(paragraphs, getParagraphText(p))
+			}
+			if !slices.Equal(paragraphs, expected) {
+				t.Errorf("Unexpected paragraphs (unwrap: %v):\n%v\n%v", unwrap, paragraphs, expected)
+			}
+			rPr := findDescendants(findDescendants(root, P_TAG)[0], RPR_TAG)
+			if len(rPr) != 1 || findChild(rPr[0], "w:b") == nil || findChild(rPr[0], "w:rStyle") != nil {
+				t.Errorf("Unexpected placeholder formatting: %s", BuildXml(findDescendants(root, P_TAG)[0], XmlOptions{}, ""))
+			}
+
+			// The table of the block control is kept, with a paragraph per cell
+			if cells := findDescendants(root, TC_TAG); len(cells) != 2 || findChild(cells[0], P_TAG) == nil || findChild(cells[1], P_TAG) == nil {
+				t.Errorf("Unexpected table cells: %s", BuildXml(findDescendants(root, TBL_TAG)[0], XmlOptions{}, ""))
+			}
+
+			controls := findDescendants(root, SDT_TAG)
+			if unwrap {
+				if len(controls) != 1 {
+					t.Errorf("Expected only the unbound control, got %d", len(controls))
+				}
+				continue
+			}
+			if len(controls) != 13 || len(findDescendants(root, "w:showingPlcHdr")) != 0 {
+				t.Errorf("Unexpected controls: %d", len(controls))
+			}
+			if checked := findDescendants(root, "w14:checked")[0]; checked.Attrs["w14:val"] != "1" {
+				t.Errorf("Checkbox not checked: %v", checked.Attrs)
+			}
+			if date := findDescendants(root, "w:date")[0]; date.Attrs["w:fullDate"] != "2026-03-01T08:00:00Z" {
+				t.Errorf("Unexpected date: %v", date.Attrs)
+			}
+		}
+	})
+
+	t.Run("custom xml data binding", func(t *testing.T) {
+		binding := func(xpath string) string {
+			return `<w:dataBinding w:prefixMappings="xmlns:ns0='urn:contract'" w:xpath="` + xpath + `" w:storeItemID="{5C3A2F10-8B1D-4E5F-9A6B-7C8D9E0F1A2B}"/>`
+		}
+		err := createTestDocxWithFiles([]byte(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+		<w:document xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main" xmlns:w14="http://schemas.microsoft.com/office/word/2010/wordml">
+			<w:body>
+				<w:p><w:sdt><w:sdtPr><w:showingPlcHdr/>`+binding("/ns0:contract[1]/ns0:client[1]/ns0:name[1]")+`</w:sdtPr><w:sdtContent><w:r><w:rPr><w:rStyle w:val="PlaceholderText"/></w:rPr><w:t>Client</w:t></w:r></w:sdtContent></w:sdt></w:p>
+				<w:p><w:sdt><w:sdtPr>`+binding("/ns0:contract[1]/@id")+`</w:sdtPr><w:sdtContent><w:r><w:t>Id</w:t></w:r></w:sdtContent></w:sdt></w:p>
+				<w:p><w:sdt><w:sdtPr>`+binding("/ns0:contract[1]/ns0:signedOn[1]")+`<w:date><w:dateFormat w:val="dd/MM/yyyy"/></w:date></w:sdtPr><w:sdtContent><w:r><w:t>Date</w:t></w:r></w:sdtContent></w:sdt></w:p>
+				<w:p><w:sdt><w:sdtPr>`+binding("/ns0:contract[1]/ns0:approved[1]")+`<w14:checkbox><w14:checked w14:val="0"/></w14:checkbox></w:sdtPr><w:sdtContent><w:r><w:t>☐</w:t></w:r></w:sdtContent></w:sdt></w:p>
+				<w:p><w:sdt><w:sdtPr>`+binding("/ns0:contract[1]/ns0:parties[1]/ns0:party[2]")+`</w:sdtPr><w:sdtContent><w:r><w:t>Party</w:t></w:r></w:sdtContent></w:sdt></w:p>
+			</w:body>
+		</w:document>`), map[string][]byte{
+			"word/_rels/document.xml.rels": []byte(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+			<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">
+				<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/customXml" Target="../customXml/item1.xml"/>
+			</Relationships>`),
+			"customXml/item1.xml": []byte(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+			<contract xmlns="urn:contract" id=""><client><name/><vat>GB123</vat></client><signedOn/><approved>false</approved><parties><party/></parties></contract>`),
+			"customXml/_rels/item1.xml.rels": []byte(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+			<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">
+				<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/customXmlProps" Target="itemProps1.xml"/>
+			</Relationships>`),
+			"customXml/itemProps1.xml": []byte(`<?xml version="1.0" encoding="UTF-8" standalone="no"?>
+			<ds:datastoreItem ds:itemID="{5c3a2f10-8b1d-4e5f-9a6b-7c8d9e0f1a2b}" xmlns:ds="http://schemas.openxmlformats.org/officeDocument/2006/customXml"/>`),
+		}, "test_template_custom_xml.docx")
+		if err != nil {
+			t.Fatalf("Failed to create test template: %v", err)
+		}
+		defer os.Remove("test_template_custom_xml.docx")
+
+		data := &ReportData{"contract": map[string]any{
+			"@id":      "C-42",
+			"client":   map[string]any{"name": "Acme Ltd"},
+			"signedOn": time.Date(2026, 3, 1, 9, 0, 0, 0, time.FixedZone("CET", 3600)),
+			"approved": true,
+			"parties":  map[string]any{"party": []string{"Alice", "Bob"}},
+		}}
+		options := CreateReportOptions{LiteralXmlDelimiter: "||", CustomXml: map[string]string{"urn:contract": "contract"}}
+		report, err := CreateReport("test_template_custom_xml.docx", data, options)
+		if err != nil {
+			t.Fatalf("CreateReport failed: %v", err)
+		}
+		outputZip, err := zip.NewReader(bytes.NewReader(report), int64(len(report)))
+		if err != nil {
+			t.Fatalf("Failed to open output: %v", err)
+		}
+		files := map[string]string{}
+		for _, f := range outputZip.File {
+			rc, _ := f.Open()
+			content, _ := io.ReadAll(rc)
+			rc.Close()
+			files[f.Name] = string(content)
+		}
+
+		item, err := ParseXml(files["customXml/item1.xml"])
+		if err != nil {
+			t.Fatalf("Failed to parse custom XML: %v", err)
+		}
+		namespaces := map[string]string{"c": "urn:contract"}
+		for xpath, expected := range map[string]string{
+			"/c:contract/c:client/c:name":      "Acme Ltd",
+			"/c:contract/c:client/c:vat":       "GB123",
+			"/c:contract/@id":                  "C-42",
+			"/c:contract/c:parties/c:party[2]": "Bob",
+			"/c:contract/c:approved":           "true",
+			"/c:contract/c:signedOn":           "2026-03-01T08:00:00Z",
+		} {
+			if value, ok := evalXpath(item.(*NonTextNode), xpath, namespaces); !ok || value != expected {
+				t.Errorf("Unexpected value of %s: %q, expected %q", xpath, value, expected)
+			}
+		}
+
+		root, err := ParseXml(files["word/document.xml"])
+		if err != nil {
+			t.Fatalf("Failed to parse output: %v", err)
+		}
+		paragraphs := []string{}
+		for _, p := range findDescendants(root, P_TAG) {
+			paragraphs = append(paragraphs, getParagraphText(p))
+		}
+		expected := []string{"Acme Ltd", "C-42", "01/03/2026", "☒", "Bob"}
+		if !slices.Equal(paragraphs, expected) {
+			t.Errorf("Unexpected paragraphs:\n%v\n%v", paragraphs, expected)
+		}
+		if len(findDescendants(root, "w:dataBinding")) != 5 || len(findDescendants(root, "w:showingPlcHdr")) != 0 || len(findDescendants(root, "w:rStyle")) != 0 {
+			t.Errorf("Unexpected controls: %s", files["word/document.xml"])
+		}
+	})
+
+	t.Run("checkboxes", func(t *testing.T) {
+		formCheckbox := `<w:r><w:fldChar w:fldCharType="begin"><w:ffData><w:name w:val="Check1"/><w:enabled/><w:checkBox><w:sizeAuto/><w:default w:val="0"/></w:checkBox></w:ffData></w:fldChar></w:r>` +
+			`<w:r><w:instrText xml:space="preserve"> FORMCHECKBOX </w:instrText></w:r><w:r><w:fldChar w:fldCharType="end"/></w:r>`
+		err := createTestDocx([]byte(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+		<w:document xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main">
+			<w:body>
+				<w:p><w:r><w:t xml:space="preserve">Signed: +++CHECKBOX signed+++</w:t></w:r></w:p>
+				<w:p><w:r><w:t>+++CHECKBOX !signed+++ Pending</w:t></w:r></w:p>
+				<w:p><w:r><w:rPr><w:sz w:val="28"/></w:rPr><w:t>+++CHECKBOX control signed+++</w:t></w:r></w:p>
+				<w:p><w:r><w:rPr><w:b/></w:rPr><w:t>Accept: +++CHECKBOX control signed+++ yes</w:t></w:r></w:p>
+				<w:p><w:r><w:t>+++FOR answer IN answers+++</w:t></w:r></w:p>
+				<w:p><w:r><w:t>+++CHECKBOX $answer+++</w:t></w:r>`+formCheckbox+`<w:r><w:t xml:space="preserve"> Yes </w:t></w:r><w:r><w:t>+++CHECKBOX !$answer+++</w:t></w:r>`+formCheckbox+`<w:r><w:t xml:space="preserve"> No</w:t></w:r></w:p>
+				<w:p><w:r><w:t>+++END-FOR answer+++</w:t></w:r></w:p>
+			</w:body>
+		</w:document>`), "test_template_checkboxes.docx")
+		if err != nil {
+			t.Fatalf("Failed to create test template: %v", err)
+		}
+		defer os.Remove("test_template_checkboxes.docx")
+
+		data := &ReportData{"signed": true, "answers": []any{true, false}}
+		report, err := CreateReport("test_template_checkboxes.docx", data, CreateReportOptions{LiteralXmlDelimiter: "||"})
+		if err != nil {
+			t.Fatalf("CreateReport failed: %v", err)
+		}
+		outputZip, err := zip.NewReader(bytes.NewReader(report), int64(len(report)))
+		if err != nil {
+			t.Fatalf("Failed to open output: %v", err)
+		}
+		var document []byte
+		for _, f := range outputZip.File {
+			if f.Name == "word/document.xml" {
+				rc, _ := f.Open()
+				document, _ = io.ReadAll(rc)
+				rc.Close()
+			}
+		}
+		root, err := ParseXml(string(document))
+		if err != nil {
+			t.Fatalf("Failed to parse output: %v", err)
+		}
+
+		paragraphs := findDescendants(root, P_TAG)
+		texts := []string{}
+		for _, p := range paragraphs {
+			texts = append(texts, getParagraphText(p))
+		}
+		expected := []string{"Signed: ☒", "☐ Pending", "☒", "Accept: ☒ yes", " Yes  No", " Yes  No"}
+		if !slices.Equal(texts, expected) {
+			t.Errorf("Unexpected paragraphs:\n%q\n%q", texts, expected)
+		}
+
+		controls := findDescendants(paragraphs[2], SDT_TAG)
+		if len(controls) != 1 || controls[0].Attrs["xmlns:w14"] != W14_NAMESPACE {
+			t.Fatalf("Expected a check box control: %s", BuildXml(paragraphs[2], XmlOptions{}, ""))
+		}
+		if checked := findDescendants(controls[0], "w14:checked"); len(checked) != 1 || checked[0].Attrs["w14:val"] != "1" {
+			t.Errorf("Check box control not checked: %s", BuildXml(controls[0], XmlOptions{}, ""))
+		}
+		if size := findDescendants(findChild(controls[0], "w:sdtContent"), "w:sz"); len(size) != 1 {
+			t.Errorf("Check box control without the command formatting: %s", BuildXml(controls[0], XmlOptions{}, ""))
+		}
+
+		tags := []string{}
+		for _, child := range paragraphs[3].Children() {
+			if nonTextChild, ok := child.(*NonTextNode); ok {
+				tags = append(tags, nonTextChild.Tag)
+			}
+		}
+		if !slices.Equal(tags, []string{R_TAG, SDT_TAG, R_TAG}) {
+			t.Errorf("Expected a check box control between two runs: %s", BuildXml(paragraphs[3], XmlOptions{}, ""))
+		}
+		for _, run := range findChildren(paragraphs[3], R_TAG) {
+			if findDescendants(run, "w:b") == nil {
+				t.Errorf("Run without the command formatting: %s", BuildXml(run, XmlOptions{}, ""))
+			}
+		}
+
+		values := []string{}
+		for _, p := range paragraphs[4:] {
+			for _, checkbox := range findDescendants(p, "w:checkBox") {
+				values = append(values, findChild(checkbox, "w:checked").Attrs["w:val"])
+			}
+		}
+		if !slices.Equal(values, []string{"1", "0", "0", "1"}) {
+			t.Errorf("Unexpected form check boxes: %v", values)
+		}
+	})
+
+	t.Run("revisions and comments", func(t *testing.T) {
+		err := createTestDocx([]byte(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+		<w:document xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main">
+			<w:body>
+				<w:p><w:bookmarkStart w:id="5" w:name="clause"/><w:r><w:rPr><w:i/></w:rPr><w:t>Clause: +++REVISIONS diff+++ (draft)</w:t></w:r><w:bookmarkEnd w:id="5"/></w:p>
+				<w:p><w:r><w:t>+++COMMENT note+++</w:t></w:r><w:r><w:t>Payment terms</w:t></w:r><w:r><w:t>+++END-COMMENT+++</w:t></w:r></w:p>
+				<w:p><w:r><w:t>+++COMMENT 'Check'+++Clause text+++END-COMMENT+++</w:t></w:r></w:p>
+				<w:p><w:r><w:t>+++FOR item IN items+++</w:t></w:r></w:p>
+				<w:p><w:r><w:t>+++COMMENT $item+++</w:t></w:r><w:r><w:t>+++$item+++</w:t></w:r><w:r><w:t>+++END-COMMENT+++</w:t></w:r></w:p>
+				<w:p><w:r><w:t>+++END-FOR item+++</w:t></w:r></w:p>
+			</w:body>
+		</w:document>`), "test_template_revisions.docx")
+		if err != nil {
+			t.Fatalf("Failed to create test template: %v", err)
+		}
+		defer os.Remove("test_template_revisions.docx")
+
+		date := time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC)
+		data := &ReportData{
+			"diff": []any{
+				"The price is ",
+				map[string]any{"type": "delete", "text": "100"},
+				map[string]any{"type": "insert", "text": "120", "author": "Legal", "date": date},
+				" EUR.",
+			},
+			"note":  map[string]any{"text": "Check with finance\nbefore signing", "author": "Jane Doe"},
+			"items": []string{"Delivery", "Warranty"},
+		}
+		options := CreateReportOptions{LiteralXmlDelimiter: "||", RevisionAuthor: "Reviewer"}
+		report, err := CreateReport("test_template_revisions.docx", data, options)
+		if err != nil {
+			t.Fatalf("CreateReport failed: %v", err)
+		}
+		outputZip, err := zip.NewReader(bytes.NewReader(report), int64(len(report)))
+		if err != nil {
+			t.Fatalf("Failed to open output: %v", err)
+		}
+		files := map[string]string{}
+		for _, f := range outputZip.File {
+			rc, _ := f.Open()
+			content, _ := io.ReadAll(rc)
+			rc.Close()
+			files[f.Name] = string(content)
+		}
+		root, err := ParseXml(files["word/document.xml"])
+		if err != nil {
+			t.Fatalf("Failed to parse output: %v", err)
+		}
+
+		paragraphs := findDescendants(root, P_TAG)
+		if text := getParagraphText(paragraphs[0]); text != "Clause: The price is 120 EUR. (draft)" {
+			t.Errorf("Unexpected text %q", text)
+		}
+		deletions, insertions := findDescendants(paragraphs[0], "w:del"), findDescendants(paragraphs[0], "w:ins")
+		if len(deletions) != 1 || len(insertions) != 1 {
+			t.Fatalf("Unexpected revisions: %s", BuildXml(paragraphs[0], XmlOptions{}, ""))
+		}
+		if del := deletions[0]; del.Attrs["w:id"] != "6" || del.Attrs["w:author"] != "Reviewer" || getNodeText(findDescendants(del, "w:delText")[0]) != "100" {
+			t.Errorf("Unexpected deletion: %s", BuildXml(del, XmlOptions{}, ""))
+		}
+		if ins := insertions[0]; ins.Attrs["w:id"] != "7" || ins.Attrs["w:author"] != "Legal" || ins.Attrs["w:date"] != "2026-03-01T09:00:00Z" ||
+			findChild(findDescendants(ins, RPR_TAG)[0], "w:i") == nil {
+			t.Errorf("Unexpected insertion: %s", BuildXml(ins, XmlOptions{}, ""))
+		}
+
+		ranges := []string{}
+		for _, p := range paragraphs[1:] {
+			start, end, reference := findDescendants(p, "w:commentRangeStart"), findDescendants(p, "w:commentRangeEnd"), findDescendants(p, "w:commentReference")
+			if len(start) != 1 || len(end) != 1 || len(reference) != 1 {
+				t.Fatalf("Unexpected comment range: %s", BuildXml(p, XmlOptions{}, ""))
+			}
+			ranges = append(ranges, start[0].Attrs["w:id"]+end[0].Attrs["w:id"]+reference[0].Attrs["w:id"]+" "+getParagraphText(p))
+		}
+		if !slices.Equal(ranges, []string{"000 Payment terms", "111 Clause text", "222 Delivery", "333 Warranty"}) {
+			t.Errorf("Unexpected comment ranges: %v", ranges)
+		}
+		tags := []string{}
+		for _, child := range paragraphs[2].Children() {
+			if nonTextChild, ok := child.(*NonTextNode); ok {
+				tags = append(tags, nonTextChild.Tag)
+			}
+		}
+		if !slices.Equal(tags, []string{"w:commentRangeStart", R_TAG, "w:commentRangeEnd", R_TAG}) {
+			t.Errorf("Unexpected comment range: %s", BuildXml(paragraphs[2], XmlOptions{}, ""))
+		}
+
+		comments, err := ParseXml(files["word/comments.xml"])
+		if err != nil {
+			t.Fatalf("Failed to parse comments: %v", err)
+		}
+		texts := []string{}
+		for _, c := range findChildren(comments, "w:comment") {
+			lines := []string{}
+			for _, p := range findChildren(c, P_TAG) {
+				lines = append(lines, getParagraphText(p))
+			}
+			texts = append(texts, fmt.Sprintf("%s %s %s: %s", c.Attrs["w:id"], c.Attrs["w:author"], c.Attrs["w:initials"], strings.Join(lines, "/")))
+		}
+		expected := []string{"0 Jane Doe JD: Check with finance/before signing", "1 Reviewer R: Check", "2 Reviewer R: Delivery", "3 Reviewer R: Warranty"}
+		if !slices.Equal(texts, expected) {
+			t.Errorf("Unexpected comments:\n%v\n%v", texts, expected)
+		}
+		if !strings.Contains(files["word/_rels/document.xml.rels"], COMMENTS_RELATION_TYPE) || !strings.Contains(files["[Content_Types].xml"], COMMENTS_CONTENT_TYPE) {
+			t.Errorf("Missing comments part: %s\n%s", files["word/_rels/document.xml.rels"], files["[Content_Types].xml"])
+		}
+	})
+
+	t.Run("clean template", func(t *testing.T) {
+		err := createTestDocxWithFiles([]byte(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+		<w:document xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main">
+			<w:body>
+				<w:p><w:r><w:t xml:space="preserve">Dear +++na</w:t></w:r><w:proofErr w:type="spellStart"/><w:ins w:id="1" w:author="Legal" w:date="2026-01-01T00:00:00Z"><w:r><w:t>me+</w:t></w:r></w:ins><w:proofErr w:type="spellEnd"/><w:del w:id="2" w:author="Legal" w:date="2026-01-01T00:00:00Z"><w:r><w:delText>old</w:delText></w:r></w:del><w:r><w:lastRenderedPageBreak/><w:t>++</w:t></w:r></w:p>
+				<w:p><w:commentRangeStart w:id="0"/><w:r><w:rPr><w:b/><w:rPrChange w:id="3" w:author="Legal" w:date="2026-01-01T00:00:00Z"><w:rPr/></w:rPrChange></w:rPr><w:t>Total: +++amount+++</w:t></w:r><w:commentRangeEnd w:id="0"/><w:r><w:rPr><w:rStyle w:val="CommentReference"/></w:rPr><w:commentReference w:id="0"/></w:r></w:p>
+				<w:p><w:pPr><w:rPr><w:del w:id="4" w:author="Legal" w:date="2026-01-01T00:00:00Z"/></w:rPr></w:pPr><w:r><w:t xml:space="preserve">Joined </w:t></w:r></w:p>
+				<w:p><w:pPr><w:jc w:val="center"/><w:rPr><w:ins w:id="5" w:author="Legal" w:date="2026-01-01T00:00:00Z"/></w:rPr></w:pPr><w:r><w:t>text</w:t></w:r></w:p>
+				<w:tbl>
+					<w:tr><w:tc><w:p><w:r><w:t>Kept</w:t></w:r></w:p></w:tc></w:tr>
+					<w:tr><w:trPr><w:del w:id="6" w:author="Legal" w:date="2026-01-01T00:00:00Z"/></w:trPr><w:tc><w:p><w:del w:id="7" w:author="Legal" w:date="2026-01-01T00:00:00Z"><w:r><w:delText>Deleted</w:delText></w:r></w:del></w:p></w:tc></w:tr>
+				</w:tbl>
+			</w:body>
+		</w:document>`), map[string][]byte{
+			"word/_rels/document.xml.rels": []byte(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+			<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">
+				<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/comments" Target="comments.xml"/>
+			</Relationships>`),
+			"word/comments.xml": []byte(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+			<w:comments xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main">
+				<w:comment w:id="0" w:author="Legal" w:initials="L"><w:p><w:r><w:annotationRef/></w:r><w:r><w:t>Check the amount</w:t></w:r></w:p></w:comment>
+			</w:comments>`),
+		}, "test_template_clean.docx")
+		if err != nil {
+			t.Fatalf("Failed to create test template: %v", err)
+		}
+		defer os.Remove("test_template_clean.docx")
+
+		data := &ReportData{"name": "Acme", "amount": 120}
+		report, err := CreateReport("test_template_clean.docx", data, CreateReportOptions{LiteralXmlDelimiter: "||", CleanTemplate: true})
+		if err != nil {
+			t.Fatalf("CreateReport failed: %v", err)
+		}
+		outputZip, err := zip.NewReader(bytes.NewReader(report), int64(len(report)))
+		if err != nil {
+			t.Fatalf("Failed to open output: %v", err)
+		}
+		files := map[string]string{}
+		for _, f := range outputZip.File {
+			rc, _ := f.Open()
+			content, _ := io.ReadAll(rc)
+			rc.Close()
+			files[f.Name] = string(content)
+		}
+		root, err := ParseXml(files["word/document.xml"])
+		if err != nil {
+			t.Fatalf("Failed to parse output: %v", err)
+		}
+
+		paragraphs := findDescendants(root, P_TAG)
+		texts := []string{}
+		for _, p := range paragraphs {
+			texts = append(texts, getParagraphText(p))
+		}
+		if expected := []string{"Dear Acme", "Total: 120", "Joined text", "Kept"}; !slices.Equal(texts, expected) {
+			t.Errorf("Unexpected paragraphs:\n%q\n%q", texts, expected)
+		}
+		for _, tag := range []string{"w:ins", "w:del", "w:rPrChange", "w:proofErr", "w:lastRenderedPageBreak", "w:commentRangeStart", "w:commentReference"} {
+			if found := findDescendants(root, tag); len(found) > 0 {
+				t.Errorf("Unexpected %s in %s", tag, files["word/document.xml"])
+			}
+		}
+		if len(findDescendants(root, TR_TAG)) != 1 || findDescendants(paragraphs[2], "w:jc") == nil || findDescendants(paragraphs[1], "w:b") == nil {
+			t.Errorf("Unexpected document: %s", files["word/document.xml"])
+		}
+		if strings.Contains(files["word/comments.xml"], "w:comment ") {
+			t.Errorf("Comments not removed: %s", files["word/comments.xml"])
+		}
+	})
+
+	t.Run("variables named after commands", func(t *testing.T) {
+		err := createTestDocx([]byte(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+		<w:document xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main">
+			<w:body>
+				<w:p><w:r><w:t>+++listPrice+++ +++comments+++ +++fieldName+++ +++chartTitle+++ +++includeVat+++</w:t></w:r></w:p>
+				<w:p><w:r><w:t>+++checkboxes+++ +++revisionsCount+++ +++bookmarkName+++ +++pagerefLabel+++ +++imageAlt+++</w:t></w:r></w:p>
+			</w:body>
+		</w:document>`), "test_template_variable_names.docx")
+		if err != nil {
+			t.Fatalf("Failed to create test template: %v", err)
+		}
+		defer os.Remove("test_template_variable_names.docx")
+
+		data := &ReportData{
+			"listPrice": 10, "comments": "none", "fieldName": "code", "chartTitle": "Sales", "includeVat": true,
+			"checkboxes": 2, "revisionsCount": 3, "bookmarkName": "top", "pagerefLabel": "page", "imageAlt": "logo",
+		}
+		report, err := CreateReport("test_template_variable_names.docx", data, CreateReportOptions{LiteralXmlDelimiter: "||"})
+		if err != nil {
+			t.Fatalf("CreateReport failed: %v", err)
+		}
+		outputZip, err := zip.NewReader(bytes.NewReader(report), int64(len(report)))
+		if err != nil {
+			t.Fatalf("Failed to open output: %v", err)
+		}
+		var document []byte
+		for _, f := range outputZip.File {
+			if f.Name == "word/document.xml" {
+				rc, _ := f.Open()
+				document, _ = io.ReadAll(rc)
+				rc.Close()
+			}
+		}
+		root, err := ParseXml(string(document))
+		if err != nil {
+			t.Fatalf("Failed to parse output: %v", err)
+		}
+		texts := []string{}
+		for _, p := range findDescendants(root, P_TAG) {
+			texts = append(texts, getParagraphText(p))
+		}
+		if expected := []string{"10 none code Sales true", "2 3 top page logo"}; !slices.Equal(texts, expected) {
+			t.Errorf("Unexpected paragraphs:\n%q\n%q", texts, expected)
+		}
+	})
+
+	t.Run("malformed svg", func(t *testing.T) {
+		err := createTestDocx([]byte(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+		<w:document xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main">
+			<w:body>
+				<w:p><w:r><w:t>+++IMAGE vector+++</w:t></w:r></w:p>
+			</w:body>
+		</w:document>`), "test_template_malformed_svg.docx")
+		if err != nil {
+			t.Fatalf("Failed to create test template: %v", err)
+		}
+		defer os.Remove("test_template_malformed_svg.docx")
+
+		shapes := []string{
+			`<path d="M1,1 a5 5 0 1 0 1e300 1e300"/>`,
+			`<path d="M1,1 A1e308 1e308 0 1 0 -1e308 1e308"/>`,
+			`<path d="M1,1 a5 5 0 1 0 3"/>`,
+			`<path d="M 1e308 1e308 L -1e308 -1e308 Z"/>`,
+			`<path d="M0 0 L"/><path d="C"/><path d="z z"/>`,
+			`<path d="M0 0 L3 4z2"/><path d="M 1 1 z 2 2"/><path d="M1 1 Z 3"/>`,
+			`<circle r="-1"/><circle r="1e308"/><rect width="1e308" height="1e308" rx="-3"/>`,
+			`<polygon points="1"/><line x1="0" y1="0" x2="1e308" y2="0" stroke="red" stroke-width="1e308"/>`,
+			`<g transform="matrix(1e308 0 0 1e308 0 0)"><rect width="5" height="5"/></g><g transform="rotate("><rect width="5" height="5"/></g>`,
+		}
+		for _, shape := range shapes {
+			svg := `<svg xmlns="http://www.w3.org/2000/svg" width="10" height="10">` + shape + `</svg>`
+			data := &ReportData{"vector": &ImagePars{Data: []byte(svg), Extension: ".svg", Width: 2}}
+			if _, err := CreateReport("test_template_malformed_svg.docx", data, CreateReportOptions{LiteralXmlDelimiter: "||"}); err != nil {
+				t.Errorf("CreateReport failed for %s: %v", shape, err)
+			}
+		}
+	})
+
+	t.Run("nested ternaries", func(t *testing.T) {
+		err := createTestDocx([]byte(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+		<w:document xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main">
+			<w:body>
+				<w:p><w:r><w:t>+++FOR case IN cases+++</w:t></w:r></w:p>
+				<w:p><w:r><w:t xml:space="preserve">+++$case.a ? $case.b ? 'ab' : 'a' : 'none'+++ +++$case.a ? 'a' : $case.b ? 'b' : 'none'+++ +++$case.a ? 'a: yes' : 'a: no'+++ +++$case.b ? "it's b" : 'not "b"'+++</w:t></w:r></w:p>
+				<w:p><w:r><w:t>+++END-FOR case+++</w:t></w:r></w:p>
+			</w:body>
+		</w:document>`), "test_template_nested_ternaries.docx")
+		if err != nil {
+			t.Fatalf("Failed to create test template: %v", err)
+		}
+		defer os.Remove("test_template_nested_ternaries.docx")
+
+		data := &ReportData{"cases": []any{
+			map[string]any{"a": true, "b": true},
+			map[string]any{"a": true, "b": false},
+			map[string]any{"a": false, "b": true},
+			map[string]any{"a": false, "b": false},
+		}}
+		report, err := CreateReport("test_template_nested_ternaries.docx", data, CreateReportOptions{LiteralXmlDelimiter: "||"})
+		if err != nil {
+			t.Fatalf("CreateReport failed: %v", err)
+		}
+		outputZip, err := zip.NewReader(bytes.NewReader(report), int64(len(report)))
+		if err != nil {
+			t.Fatalf("Failed to open output: %v", err)
+		}
+		var document []byte
+		for _, f := range outputZip.File {
+			if f.Name == "word/document.xml" {
+				rc, _ := f.Open()
+				document, _ = io.ReadAll(rc)
+				rc.Close()
+			}
+		}
+		root, err := ParseXml(string(document))
+		if err != nil {
+			t.Fatalf("Failed to parse output: %v", err)
+		}
+		texts := []string{}
+		for _, p := range findDescendants(root, P_TAG) {
+			texts = append(texts, getParagraphText(p))
+		}
+		expected := []string{`ab a a: yes it's b`, `a a a: yes not "b"`, `none b a: no it's b`, `none none a: no not "b"`}
+		if !slices.Equal(texts, expected) {
+			t.Errorf("Unexpected paragraphs:\n%q\n%q", texts, expected)
+		}
+	})
+
+	t.Run("headers and footers", func(t *testing.T) {
+		err := createTestDocxWithFiles([]byte(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+		<w:document xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main">
+			<w:body>
+				<w:p><w:r><w:t>Body</w:t></w:r></w:p>
+			</w:body>
+		</w:document>`), map[string][]byte{
+			"word/header1.xml": []byte(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+			<w:hdr xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main"
+				xmlns:wp="http://schemas.openxmlformats.org/drawingml/2006/wordprocessingDrawing"
+				xmlns:a="http://schemas.openxmlformats.org/drawingml/2006/main"
+				xmlns:pic="http://schemas.openxmlformats.org/drawingml/2006/picture"
+				xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">
+				<w:p><w:r><w:drawing>
+					<wp:inline distT="0" distB="0" distL="0" distR="0">
+						<wp:extent cx="1800000" cy="900000"/>
+						<wp:docPr id="1" name="Picture 1" descr="+++IMAGE logo+++"/>
+						<a:graphic><a:graphicData uri="http://schemas.openxmlformats.org/drawingml/2006/picture">
+							<pic:pic>
+								<pic:nvPicPr><pic:cNvPr id="0" name="sample.png"/><pic:cNvPicPr/></pic:nvPicPr>
+								<pic:blipFill><a:blip r:embed="rId5"/><a:stretch><a:fillRect/></a:stretch></pic:blipFill>
+								<pic:spPr><a:xfrm><a:off x="0" y="0"/><a:ext cx="1800000" cy="900000"/></a:xfrm><a:prstGeom prst="rect"/></pic:spPr>
+							</pic:pic>
+						</a:graphicData></a:graphic>
+					</wp:inline>
+				</w:drawing></w:r></w:p>
+				<w:p><w:r><w:t>+++REVISIONS changes+++</w:t></w:r></w:p>
+			</w:hdr>`),
+			"word/_rels/header1.xml.rels": []byte(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+			<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">
+				<Relationship Id="rId5" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/image" Target="media/sample.png"/>
+			</Relationships>`),
+			"word/media/sample.png": testPngData,
+			"word/footer1.xml": []byte(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+			<w:ftr xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main">
+				<w:p><w:r><w:t>+++IMAGE stamp+++</w:t></w:r></w:p>
+				<w:p><w:r><w:t>+++LINK site+++</w:t></w:r></w:p>
+				<w:p><w:r><w:t>+++LIST tags+++</w:t></w:r></w:p>
+				<w:p><w:r><w:t>+++RESTART-NUMBERING+++</w:t></w:r></w:p>
+				<w:p><w:pPr><w:numPr><w:ilvl w:val="0"/><w:numId w:val="1"/></w:numPr></w:pPr><w:r><w:t>Page</w:t></w:r></w:p>
+			</w:ftr>`),
+			"word/_rels/document.xml.rels": []byte(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+			<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">
+				<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/numbering" Target="numbering.xml"/>
+			</Relationships>`),
+			"word/numbering.xml": []byte(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+			<w:numbering xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main">
+				<w:abstractNum w:abstractNumId="0"><w:lvl w:ilvl="0"><w:start w:val="1"/><w:numFmt w:val="decimal"/></w:lvl></w:abstractNum>
+				<w:num w:numId="1"><w:abstractNumId w:val="0"/></w:num>
+			</w:numbering>`),
+		}, "test_template_headers.docx")
+		if err != nil {
+			t.Fatalf("Failed to create test template: %v", err)
+		}
+		defer os.Remove("test_template_headers.docx")
+
+		data := &ReportData{
+			"logo":  &ImagePars{Data: testPngData, Extension: ".png"},
+			"stamp": &ImagePars{Data: testPngData, Extension: ".png", Width: 2, Height: 2},
+			"site":  &LinkPars{Url: "https://example.com", Label: "Site"},
+			"tags":  []string{"new", "sale"},
+			"changes": []any{
+				map[string]any{"type": "insert", "text": "Draft"},
+				map[string]any{"type": "delete", "text": "Final"},
+			},
+		}
+		report, err := CreateReport("test_template_headers.docx", data, CreateReportOptions{LiteralXmlDelimiter: "||"})
+		if err != nil {
+			t.Fatalf("CreateReport failed: %v", err)
+		}
+		outputZip, err := zip.NewReader(bytes.NewReader(report), int64(len(report)))
+		if err != nil {
+			t.Fatalf("Failed to open output: %v", err)
+		}
+		files := map[string]string{}
+		for _, f := range outputZip.File {
+			rc, _ := f.Open()
+			content, _ := io.ReadAll(rc)
+			rc.Close()
+			files[f.Name] = string(content)
+		}
+
+		// Each relationship id of a part must be found in its own rels part, with its media
+		relIdRegexp := regexp.MustCompile(`r:(?:embed|id)="([^"]+)"`)
+		for _, part := range []string{"header1.xml", "footer1.xml"} {
+			relIds := relIdRegexp.FindAllStringSubmatch(files["word/"+part], -1)
+			if len(relIds) == 0 {
+				t.Errorf("No relationship in %s: %s", part, files["word/"+part])
+			}
+			rels, err := ParseXml(files["word/_rels/"+part+".rels"])
+			if err != nil {
+				t.Fatalf("Failed to parse rels of %s: %v", part, err)
+			}
+			for _, relId := range relIds {
+				var target string
+				for _, rel := range rels.Children() {
+					if rel, ok := rel.(*NonTextNode); ok && rel.Attrs["Id"] == relId[1] {
+						target = rel.Attrs["Target"]
+					}
+				}
+				if target == "" {
+					t.Errorf("Missing relationship %s of %s", relId[1], part)
+				} else if strings.HasPrefix(target, "media/") && files["word/"+target] == "" {
+					t.Errorf("Missing media %s of %s", target, part)
+				}
+			}
+		}
+		if !strings.Contains(files["[Content_Types].xml"], `Extension="png"`) {
+			t.Errorf("Missing png content type: %s", files["[Content_Types].xml"])
+		}
+
+		// The lists of the footer are numbered with definitions of the numbering part
+		footer, err := ParseXml(files["word/footer1.xml"])
+		if err != nil {
+			t.Fatalf("Failed to parse footer: %v", err)
+		}
+		numIds := []string{}
+		for _, numId := range findDescendants(footer, "w:numId") {
+			numIds = append(numIds, numId.Attrs["w:val"])
+		}
+		if len(numIds) != 3 || numIds[0] != numIds[1] || slices.Contains(numIds, "0") || slices.Contains(numIds, "1") {
+			t.Errorf("Unexpected footer numbering %v", numIds)
+		}
+		for _, numId := range numIds {
+			if !strings.Contains(files["word/numbering.xml"], `w:numId="`+numId+`"`) {
+				t.Errorf("Missing numbering definition %s: %s", numId, files["word/numbering.xml"])
+			}
+		}
+
+		// The tracked changes of the header are numbered, and comments are rejected
+		header, err := ParseXml(files["word/header1.xml"])
+		if err != nil {
+			t.Fatalf("Failed to parse header: %v", err)
+		}
+		changeIds := []string{}
+		for _, tag := range []string{"w:ins", "w:del"} {
+			for _, change := range findDescendants(header, tag) {
+				changeIds = append(changeIds, change.Attrs["w:id"])
+			}
+		}
+		if len(changeIds) != 2 || changeIds[0] == changeIds[1] || slices.Contains(changeIds, "0") {
+			t.Errorf("Unexpected change ids %v", changeIds)
+		}
+		err = createTestDocxWithFiles([]byte(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+		<w:document xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main">
+			<w:body><w:p><w:r><w:t>Body</w:t></w:r></w:p></w:body>
+		</w:document>`), map[string][]byte{
+			"word/footer1.xml": []byte(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+			<w:ftr xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main">
+				<w:p><w:r><w:t>+++COMMENT 'Check'+++Page+++END-COMMENT+++</w:t></w:r></w:p>
+			</w:ftr>`),
+		}, "test_template_footer_comment.docx")
+		if err != nil {
+			t.Fatalf("Failed to create test template: %v", err)
+		}
+		defer os.Remove("test_template_footer_comment.docx")
+		_, err = CreateReport("test_template_footer_comment.docx", &ReportData{}, CreateReportOptions{LiteralXmlDelimiter: "||"})
+		if err == nil || !strings.Contains(err.Error(), "COMMENT is not supported in word/footer1.xml") {
+			t.Errorf("Expected an error for a comment in a footer, got %v", err)
+		}
+	})
+
+}
+
+func FuzzParseSvgPath(f *testing.F) {
+	for _, d := range []string{"M0 0 L3 4z2", "M 1 1 z 2 2", "M1,1 a5 5 0 1 0 3", "m1 1h2v2zM0 0C1 1 2 2 3 3S4 4 5 5Q1 1 2 2T3 3"} {
+		f.Add(d)
+	}
+	f.Fuzz(func(t *testing.T, d string) {
+		// Each operation reads part of the path data
+		if ops := parseSvgPath(d); len(ops) > len(d) {
+			t.Errorf("%d operations for %q", len(ops), d)
+		}
+	})
 }
@@ -0,0 +1,275 @@
+package godocx
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	COMMENTS_CONTENT_TYPE  = "application/vnd.openxmlformats-officedocument.wordprocessingml.comments+xml"
+	COMMENTS_RELATION_TYPE = "http://schemas.openxmlformats.org/officeDocument/2006/relationships/comments"
+
+	DEFAULT_REVISION_AUTHOR = "godocx"
+	REVISION_DATE_FORMAT    = "2006-01-02T15:04:05Z"
+)
+
+// Revisions collects the tracked changes and comments generated during the walk, whose ids are
+// assigned afterwards (see ProcessRevisions)
+type Revisions struct {
+	changes  []*NonTextNode // `w:ins` and `w:del`
+	comments []*comment
+}
+
+type comment struct {
+	author   string
+	initials string
+	date     string
+	text     string
+	nodes    []*NonTextNode // range start and end, and reference, with the id of the comment
+}
+
+func newRevisions() *Revisions {
+	return &Revisions{}
+}
+
+func (r *Revisions) isEmpty() bool {
+	return r == nil || (len(r.changes) == 0 && len(r.comments) == 0)
+}
+
+// annotation holds the author and date of a change or comment given as data
+type annotation struct {
+	author string
+	date   string
+}
+
+func getAnnotation(fields map[string]any, ctx *Context) annotation {
+	a := annotation{author: ctx.options.RevisionAuthor, date: time.Now().UTC().Format(REVISION_DATE_FORMAT)}
+	if a.author == "" {
+		a.author = DEFAULT_REVISION_AUTHOR
+	}
+	if author, ok := fields["author"]; ok && fmt.Sprint(author) != "" {
+		a.author = fmt.Sprint(author)
+	}
+	switch date := fields["date"].(type) {
+	case time.Time:
+		a.date = date.UTC().Format(REVISION_DATE_FORMAT)
+	case string:
+		if date != "" {
+			a.date = date
+		}
+	}
+	return a
+}
+
+func toFields(value VarValue) (map[string]any, bool) {
+	switch fields := value.(type) {
+	case map[string]any:
+		return fields, true
+	case ReportData:
+		return fields, true
+	}
+	return nil, false
+}
+
+// REVISIONS <expression>: the changes are text (unchanged), or maps with a `type` ("insert",
+// "delete" or else unchanged), a `text`, and optionally an `author` and a `date`
+func processRevisions(data *ReportData, ctx *Context, rest string) error {
+	value, err := runAndGetValue(rest, ctx, data)
+	if err != nil {
+		return err
+	}
+	changes := []VarValue{value}
+	if reflected := reflect.ValueOf(value); reflected.Kind() == reflect.Slice {
+		changes = make([]VarValue, reflected.Len())
+		for i := range reflected.Len() {
+			changes[i] = reflected.Index(i).Interface()
+		}
+	}
+
+	node := NewNonTextNode
+	newRun := func(textTag string, text string) *NonTextNode {
+		run := node(R_TAG, nil, nil)
+		if ctx.textRunPropsNode != nil {
+			AddChild(run, cloneNode(ctx.textRunPropsNode))
+		}
+		AddChild(run, node(textTag, map[string]string{"xml:space": "preserve"}, []Node{NewTextNode(text)}))
+		return run
+	}
+	nodes := []Node{}
+	for _, change := range changes {
+		fields, ok := toFields(change)
+		if !ok {
+			nodes = append(nodes, newRun(T_TAG, fmt.Sprint(change)))
+			continue
+		}
+		text := fmt.Sprint(fields["text"])
+		if fields["text"] == nil {
+			text = ""
+		}
+		changeType := strings.ToLower(fmt.Sprint(fields["type"]))
+		var revision *NonTextNode
+		if strings.HasPrefix(changeType, "ins") {
+			revision = node("w:ins", nil, []Node{newRun(T_TAG, text)})
+		} else if strings.HasPrefix(changeType, "del") {
+			revision = node("w:del", nil, []Node{newRun("w:delText", text)})
+		} else {
+			nodes = append(nodes, newRun(T_TAG, text))
+			continue
+		}
+		a := getAnnotation(fields, ctx)
+		revision.Attrs = map[string]string{"w:id": "0", "w:author": a.author, "w:date": a.date}
+		ctx.revisions.changes = append(ctx.revisions.changes, revision)
+		nodes = append(nodes, revision)
+	}
+	addRunNodes(ctx, nodes...)
+	return nil
+}
+
+// COMMENT <expression>: the comment is text, or a map with a `text`, and optionally an `author`,
+// `initials` and a `date`. It applies to the content up to the next END-COMMENT.
+func processComment(data *ReportData, ctx *Context, rest string) error {
+	value, err := runAndGetValue(rest, ctx, data)
+	if err != nil {
+		return err
+	}
+	fields, ok := toFields(value)
+	if !ok {
+		fields = map[string]any{"text": value}
+	}
+	a := getAnnotation(fields, ctx)
+	c := &comment{author: a.author, date: a.date}
+	if fields["text"] != nil {
+		c.text = fmt.Sprint(fields["text"])
+	}
+	if initials, ok := fields["initials"]; ok {
+		c.initials = fmt.Sprint(initials)
+	} else {
+		for _, word := range strings.Fields(c.author) {
+			c.initials += string([]rune(word)[0])
+		}
+	}
+
+	start := NewNonTextNode("w:commentRangeStart", map[string]string{"w:id": "0"}, nil)
+	c.nodes = append(c.nodes, start)
+	ctx.revisions.comments = append(ctx.revisions.comments, c)
+	ctx.openComments = append(ctx.openComments, c)
+	addRunNodes(ctx, start)
+	return nil
+}
+
+// END-COMMENT
+func processEndComment(ctx *Context) error {
+	if len(ctx.openComments) == 0 {
+		return errors.New("END-COMMENT without COMMENT")
+	}
+	c := ctx.openComments[len(ctx.openComments)-1]
+	ctx.openComments = ctx.openComments[:len(ctx.openComments)-1]
+
+	node := NewNonTextNode
+	end := node("w:commentRangeEnd", map[string]string{"w:id": "0"}, nil)
+	reference := node("w:commentReference", map[string]string{"w:id": "0"}, nil)
+	c.nodes = append(c.nodes, end, reference)
+	addRunNodes(ctx, end, node(R_TAG, nil, []Node{reference}))
+	return nil
+}
+
+// ProcessRevisions numbers the generated tracked changes after the annotations of the report,
+// and adds the generated comments to the comments part, creating it if missing
+func ProcessRevisions(revisions *Revisions, report Node, documentComponent string, zip *ZipArchive, contentTypes *NonTextNode) error {
+	if revisions.isEmpty() {
+		return nil
+	}
+	maxId := getMaxAnnotationId(report, revisions)
+	for _, change := range revisions.changes {
+		maxId++
+		change.Attrs["w:id"] = fmt.Sprint(maxId)
+	}
+	if len(revisions.comments) == 0 {
+		return nil
+	}
+
+	xmlOptions := XmlOptions{LiteralXmlDelimiter: DEFAULT_LITERAL_XML_DELIMITER}
+	relsPath := fmt.Sprintf("%s/_rels/%s.rels", TEMPLATE_PATH, documentComponent)
+	rels, err := getRelsFromZip(zip, relsPath)
+	if err != nil {
+		return err
+	}
+	commentsPath := findRelTarget(rels, COMMENTS_RELATION_TYPE)
+	var comments *NonTextNode
+	if commentsPath == "" {
+		commentsPath = TEMPLATE_PATH + "/comments.xml"
+		comments = NewNonTextNode("w:comments", map[string]string{"xmlns:w": WORDML_NAMESPACE}, nil)
+		AddChild(rels, NewNonTextNode("Relationship", map[string]string{
+			"Id":     uniqueRelId(rels, "comments"),
+			"Type":   COMMENTS_RELATION_TYPE,
+			"Target": "comments.xml",
+		}, nil))
+		AddChild(contentTypes, NewNonTextNode("Override", map[string]string{
+			"PartName":    "/" + commentsPath,
+			"ContentType": COMMENTS_CONTENT_TYPE,
+		}, nil))
+		zip.SetFile(relsPath, BuildXml(rels, xmlOptions, ""))
+	} else {
+		comments, err = parsePath(zip, commentsPath)
+		if err != nil {
+			return err
+		}
+	}
+
+	commentId := -1
+	for _, existing := range findChildren(comments, "w:comment") {
+		if id, err := strconv.Atoi(existing.Attrs["w:id"]); err == nil {
+			commentId = max(commentId, id)
+		}
+	}
+	for _, c := range revisions.comments {
+		commentId++
+		for _, node := range c.nodes {
+			node.Attrs["w:id"] = fmt.Sprint(commentId)
+		}
+		AddChild(comments, newCommentNode(c, fmt.Sprint(commentId)))
+	}
+	zip.SetFile(commentsPath, BuildXml(comments, xmlOptions, ""))
+	return nil
+}
+
+func newCommentNode(c *comment, id string) *NonTextNode {
+	node := NewNonTextNode
+	commentNode := node("w:comment", map[string]string{"w:id": id, "w:author": c.author, "w:date": c.date, "w:initials": c.initials}, nil)
+	for i, line := range strings.Split(c.text, "\n") {
+		p := node(P_TAG, nil, nil)
+		if i == 0 {
+			AddChild(p, node(R_TAG, nil, []Node{node("w:annotationRef", nil, nil)}))
+		}
+		AddChild(p, node(R_TAG, nil, []Node{node(T_TAG, map[string]string{"xml:space": "preserve"}, []Node{NewTextNode(line)})}))
+		AddChild(commentNode, p)
+	}
+	return commentNode
+}
+
+// getMaxAnnotationId returns the highest id of the annotations of the report (tracked changes,
+// bookmarks...), other than the generated changes
+func getMaxAnnotationId(report Node, revisions *Revisions) int {
+	generated := map[*NonTextNode]bool{}
+	for _, change := range revisions.changes {
+		generated[change] = true
+	}
+	maxId := 0
+	var visit func(node Node)
+	visit = func(node Node) {
+		for _, child := range node.Children() {
+			if nonTextChild, ok := child.(*NonTextNode); ok {
+				if id, err := strconv.Atoi(nonTextChild.Attrs["w:id"]); err == nil && !generated[nonTextChild] {
+					maxId = max(maxId, id)
+				}
+				visit(nonTextChild)
+			}
+		}
+	}
+	visit(report)
+	return maxId
+}
@@ -0,0 +1,868 @@
+package godocx
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+
+	"golang.org/x/image/vector"
+)
+
+// Minimal SVG renderer, used to generate the PNG fallback that Word requires
+// alongside SVG images. It supports the basic shapes, paths, groups, transforms
+// and solid fills and strokes; text, gradients, clipping and filters are ignored
+// (gradients are rendered as a solid grey).
+
+// MAX_SVG_FALLBACK_SIZE is the size (in pixels) of the longest side of the SVG fallback images
+const MAX_SVG_FALLBACK_SIZE = 1024
+
+type svgMatrix [6]float64 // a b c d e f: x' = a*x + c*y + e, y' = b*x + d*y + f
+
+var svgIdentity = svgMatrix{1, 0, 0, 1, 0, 0}
+
+func (m svgMatrix) multiply(n svgMatrix) svgMatrix {
+	return svgMatrix{
+		m[0]*n[0] + m[2]*n[1],
+		m[1]*n[0] + m[3]*n[1],
+		m[0]*n[2] + m[2]*n[3],
+		m[1]*n[2] + m[3]*n[3],
+		m[0]*n[4] + m[2]*n[5] + m[4],
+		m[1]*n[4] + m[3]*n[5] + m[5],
+	}
+}
+
+func (m svgMatrix) apply(x, y float64) (float64, float64) {
+	return m[0]*x + m[2]*y + m[4], m[1]*x + m[3]*y + m[5]
+}
+
+// scale is the average scaling factor of the matrix (used for stroke widths)
+func (m svgMatrix) scale() float64 {
+	return math.Sqrt(math.Abs(m[0]*m[3] - m[1]*m[2]))
+}
+
+type svgStyle struct {
+	fill        color.Color // nil: none
+	stroke      color.Color // nil: none
+	strokeWidth float64
+	opacity     float64
+	fillOpacity float64
+	strokeOpac  float64
+	transform   svgMatrix
+	hidden      bool
+}
+
+type svgPoint struct{ x, y float64 }
+
+// svgPathOp is a path operation in user space: 'M', 'L', 'Q', 'C' or 'Z'
+type svgPathOp struct {
+	op     byte
+	points []svgPoint
+}
+
+type svgRenderer struct {
+	dst    *image.RGBA
+	raster *vector.Rasterizer
+}
+
+// renderSvgToPng renders an SVG image to a PNG image, of at most maxSize pixels
+func renderSvgToPng(data []byte, maxSize int) ([]byte, error) {
+	info, err := readSvgInfo(data)
+	if err != nil {
+		return nil, err
+	}
+	if info.width <= 0 || info.height <= 0 {
+		return nil, errors.New("SVG without a size nor a viewBox")
+	}
+	scale := min(float64(maxSize)/info.width, float64(maxSize)/info.height, 4)
+	width := max(1, int(math.Round(info.width*scale)))
+	height := max(1, int(math.Round(info.height*scale)))
+
+	renderer := &svgRenderer{
+		dst:    image.NewRGBA(image.Rect(0, 0, width, height)),
+		raster: vector.NewRasterizer(width, height),
+	}
+	renderer.raster.DrawOp = draw.Over
+	if err := renderer.render(data, float64(width), float64(height)); err != nil {
+		return nil, err
+	}
+	out := new(bytes.Buffer)
+	if err := png.Encode(out, renderer.dst); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+func (r *svgRenderer) render(data []byte, width float64, height float64) error {
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+	decoder.Strict = false
+	stack := []svgStyle{}
+	skipDepth := 0
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("SVG parsing error: %w", err)
+		}
+		switch t := token.(type) {
+		case xml.StartElement:
+			if skipDepth > 0 {
+				skipDepth++
+				continue
+			}
+			attrs := map[string]string{}
+			for _, attr := range t.Attr {
+				attrs[attr.Name.Local] = attr.Value
+			}
+			var parent svgStyle
+			if len(stack) == 0 {
+				if t.Name.Local != "svg" {
+					return errors.New("SVG parsing error: root element is not <svg>")
+				}
+				parent = svgStyle{
+					fill: color.Black, strokeWidth: 1, opacity: 1, fillOpacity: 1, strokeOpac: 1,
+					transform: svgViewBoxTransform(attrs["viewBox"], width, height),
+				}
+			} else {
+				parent = stack[len(stack)-1]
+			}
+			switch t.Name.Local {
+			case "defs", "clipPath", "mask", "symbol", "marker", "pattern", "title", "desc", "metadata", "style", "text", "linearGradient", "radialGradient", "filter":
+				skipDepth = 1
+				continue
+			}
+			style := parseSvgStyle(attrs, parent)
+			stack = append(stack, style)
+			if !style.hidden {
+				r.drawShape(t.Name.Local, attrs, style)
+			}
+		case xml.EndElement:
+			if skipDepth > 0 {
+				skipDepth--
+				continue
+			}
+			if len(stack) > 0 {
+				stack = stack[:len(stack)-1]
+			}
+		}
+	}
+	return nil
+}
+
+// svgViewBoxTransform maps the viewBox to the image (preserveAspectRatio="xMidYMid meet")
+func svgViewBoxTransform(viewBox string, width float64, height float64) svgMatrix {
+	values := parseSvgNumbers(viewBox)
+	if len(values) != 4 || values[2] <= 0 || values[3] <= 0 {
+		return svgIdentity
+	}
+	scale := min(width/values[2], height/values[3])
+	dx := (width - values[2]*scale) / 2
+	dy := (height - values[3]*scale) / 2
+	return svgMatrix{scale, 0, 0, scale, dx - values[0]*scale, dy - values[1]*scale}
+}
+
+func parseSvgStyle(attrs map[string]string, parent svgStyle) svgStyle {
+	style := parent
+	// Inline `style` declarations take precedence over the presentation attributes
+	props := map[string]string{}
+	for _, key := range []string{"fill", "stroke", "stroke-width", "opacity", "fill-opacity", "stroke-opacity", "display", "visibility"} {
+		if value, ok := attrs[key]; ok {
+			props[key] = value
+		}
+	}
+	for _, declaration := range strings.Split(attrs["style"], ";") {
+		key, value, found := strings.Cut(declaration, ":")
+		if found {
+			props[strings.TrimSpace(key)] = strings.TrimSpace(value)
+		}
+	}
+	for key, value := range props {
+		switch key {
+		case "fill":
+			style.fill = parseSvgColor(value)
+		case "stroke":
+			style.stroke = parseSvgColor(value)
+		case "stroke-width":
+			if width, ok := svgLengthToPx(value); ok {
+				style.strokeWidth = width
+			}
+		case "opacity":
+			style.opacity = parent.opacity * parseSvgOpacity(value)
+		case "fill-opacity":
+			style.fillOpacity = parseSvgOpacity(value)
+		case "stroke-opacity":
+			style.strokeOpac = parseSvgOpacity(value)
+		case "display":
+			style.hidden = style.hidden || value == "none"
+		case "visibility":
+			style.hidden = value == "hidden" || value == "collapse"
+		}
+	}
+	style.transform = parent.transform.multiply(parseSvgTransform(attrs["transform"]))
+	return style
+}
+
+func parseSvgOpacity(value string) float64 {
+	opacity, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+	if err != nil {
+		return 1
+	}
+	return math.Max(0, math.Min(1, opacity))
+}
+
+var svgNamedColors = map[string]color.RGBA{
+	"black": {0, 0, 0, 255}, "white": {255, 255, 255, 255}, "red": {255, 0, 0, 255},
+	"green": {0, 128, 0, 255}, "lime": {0, 255, 0, 255}, "blue": {0, 0, 255, 255},
+	"yellow": {255, 255, 0, 255}, "cyan": {0, 255, 255, 255}, "aqua": {0, 255, 255, 255},
+	"magenta": {255, 0, 255, 255}, "fuchsia": {255, 0, 255, 255}, "gray": {128, 128, 128, 255},
+	"grey": {128, 128, 128, 255}, "silver": {192, 192, 192, 255}, "maroon": {128, 0, 0, 255},
+	"olive": {128, 128, 0, 255}, "navy": {0, 0, 128, 255}, "purple": {128, 0, 128, 255},
+	"teal": {0, 128, 128, 255}, "orange": {255, 165, 0, 255}, "brown": {165, 42, 42, 255},
+	"pink": {255, 192, 203, 255}, "gold": {255, 215, 0, 255}, "darkgray": {169, 169, 169, 255},
+	"darkgrey": {169, 169, 169, 255}, "lightgray": {211, 211, 211, 255}, "lightgrey": {211, 211, 211, 255},
+	"darkred": {139, 0, 0, 255}, "darkgreen": {0, 100, 0, 255}, "darkblue": {0, 0, 139, 255},
+}
+
+// parseSvgColor parses a paint value; it returns nil for "none"
+func parseSvgColor(value string) color.Color {
+	value = strings.ToLower(strings.TrimSpace(value))
+	switch {
+	case value == "none" || value == "transparent" || value == "":
+		return nil
+	case value == "currentcolor":
+		return color.Black
+	case strings.HasPrefix(value, "url("):
+		// Gradients and patterns are not supported
+		return color.RGBA{128, 128, 128, 255}
+	case strings.HasPrefix(value, "#"):
+		hexValue := value[1:]
+		if len(hexValue) == 3 {
+			hexValue = string([]byte{hexValue[0], hexValue[0], hexValue[1], hexValue[1], hexValue[2], hexValue[2]})
+		}
+		rgb, err := strconv.ParseUint(hexValue, 16, 32)
+		if err != nil || len(hexValue) != 6 {
+			return color.Black
+		}
+		return color.RGBA{uint8(rgb >> 16), uint8(rgb >> 8), uint8(rgb), 255}
+	case strings.HasPrefix(value, "rgb"):
+		start, end := strings.Index(value, "("), strings.Index(value, ")")
+		if start < 0 || end < start {
+			return color.Black
+		}
+		parts := strings.Split(value[start+1:end], ",")
+		if len(parts) < 3 {
+			return color.Black
+		}
+		channels := [3]uint8{}
+		for i := range channels {
+			part := strings.TrimSpace(parts[i])
+			channel := 0.0
+			if strings.HasSuffix(part, "%") {
+				channel, _ = strconv.ParseFloat(strings.TrimSuffix(part, "%"), 64)
+				channel = channel * 255 / 100
+			} else {
+				channel, _ = strconv.ParseFloat(part, 64)
+			}
+			channels[i] = uint8(math.Max(0, math.Min(255, channel)))
+		}
+		return color.RGBA{channels[0], channels[1], channels[2], 255}
+	}
+	if named, ok := svgNamedColors[value]; ok {
+		return named
+	}
+	return color.Black
+}
+
+func parseSvgNumbers(text string) []float64 {
+	numbers := []float64{}
+	tokenizer := svgPathTokenizer{text: text}
+	for {
+		tokenizer.skipSeparators()
+		number, ok := tokenizer.number()
+		if !ok {
+			return numbers
+		}
+		numbers = append(numbers, number)
+	}
+}
+
+func parseSvgTransform(text string) svgMatrix {
+	matrix := svgIdentity
+	for text = strings.TrimSpace(text); text != ""; text = strings.TrimSpace(text) {
+		start, end := strings.Index(text, "("), strings.Index(text, ")")
+		if start < 0 || end < start {
+			break
+		}
+		name := strings.Trim(strings.TrimSpace(text[:start]), ",")
+		args := parseSvgNumbers(text[start+1 : end])
+		text = text[end+1:]
+		var m svgMatrix
+		switch {
+		case name == "matrix" && len(args) == 6:
+			m = svgMatrix{args[0], args[1], args[2], args[3], args[4], args[5]}
+		case name == "translate" && len(args) >= 1:
+			ty := 0.0
+			if len(args) > 1 {
+				ty = args[1]
+			}
+			m = svgMatrix{1, 0, 0, 1, args[0], ty}
+		case name == "scale" && len(args) >= 1:
+			sy := args[0]
+			if len(args) > 1 {
+				sy = args[1]
+			}
+			m = svgMatrix{args[0], 0, 0, sy, 0, 0}
+		case name == "rotate" && len(args) >= 1:
+			angle := args[0] * math.Pi / 180
+			cos, sin := math.Cos(angle), math.Sin(angle)
+			m = svgMatrix{cos, sin, -sin, cos, 0, 0}
+			if len(args) == 3 {
+				m = svgMatrix{1, 0, 0, 1, args[1], args[2]}.multiply(m).multiply(svgMatrix{1, 0, 0, 1, -args[1], -args[2]})
+			}
+		case name == "skewX" && len(args) == 1:
+			m = svgMatrix{1, 0, math.Tan(args[0] * math.Pi / 180), 1, 0, 0}
+		case name == "skewY" && len(args) == 1:
+			m = svgMatrix{1, math.Tan(args[0] * math.Pi / 180), 0, 1, 0, 0}
+		default:
+			continue
+		}
+		matrix = matrix.multiply(m)
+	}
+	return matrix
+}
+
+func svgAttrLength(attrs map[string]string, key string) float64 {
+	length, _ := svgLengthToPx(attrs[key])
+	return length
+}
+
+// ellipsePath approximates an ellipse with 4 cubic Bézier curves
+func ellipsePath(cx, cy, rx, ry float64) []svgPathOp {
+	const k = 0.5522847498
+	return []svgPathOp{
+		{'M', []svgPoint{{cx + rx, cy}}},
+		{'C', []svgPoint{{cx + rx, cy + k*ry}, {cx + k*rx, cy + ry}, {cx, cy + ry}}},
+		{'C', []svgPoint{{cx - k*rx, cy + ry}, {cx - rx, cy + k*ry}, {cx - rx, cy}}},
+		{'C', []svgPoint{{cx - rx, cy - k*ry}, {cx - k*rx, cy - ry}, {cx, cy - ry}}},
+		{'C', []svgPoint{{cx + k*rx, cy - ry}, {cx + rx, cy - k*ry}, {cx + rx, cy}}},
+		{'Z', nil},
+	}
+}
+
+func polyPath(points []float64, closed bool) []svgPathOp {
+	ops := []svgPathOp{}
+	for i := 0; i+1 < len(points); i += 2 {
+		op := byte('L')
+		if i == 0 {
+			op = 'M'
+		}
+		ops = append(ops, svgPathOp{op, []svgPoint{{points[i], points[i+1]}}})
+	}
+	if closed && len(ops) > 0 {
+		ops = append(ops, svgPathOp{'Z', nil})
+	}
+	return ops
+}
+
+func (r *svgRenderer) drawShape(name string, attrs map[string]string, style svgStyle) {
+	var ops []svgPathOp
+	fillable := true
+	switch name {
+	case "rect":
+		x, y := svgAttrLength(attrs, "x"), svgAttrLength(attrs, "y")
+		w, h := svgAttrLength(attrs, "width"), svgAttrLength(attrs, "height")
+		if w <= 0 || h <= 0 {
+			return
+		}
+		ops = polyPath([]float64{x, y, x + w, y, x + w, y + h, x, y + h}, true)
+	case "circle":
+		radius := svgAttrLength(attrs, "r")
+		if radius <= 0 {
+			return
+		}
+		ops = ellipsePath(svgAttrLength(attrs, "cx"), svgAttrLength(attrs, "cy"), radius, radius)
+	case "ellipse":
+		rx, ry := svgAttrLength(attrs, "rx"), svgAttrLength(attrs, "ry")
+		if rx <= 0 || ry <= 0 {
+			return
+		}
+		ops = ellipsePath(svgAttrLength(attrs, "cx"), svgAttrLength(attrs, "cy"), rx, ry)
+	case "line":
+		ops = polyPath([]float64{
+			svgAttrLength(attrs, "x1"), svgAttrLength(attrs, "y1"),
+			svgAttrLength(attrs, "x2"), svgAttrLength(attrs, "y2"),
+		}, false)
+		fillable = false
+	case "polyline", "polygon":
+		ops = polyPath(parseSvgNumbers(attrs["points"]), name == "polygon")
+	case "path":
+		ops = parseSvgPath(attrs["d"])
+	default:
+		return
+	}
+	if fillable && style.fill != nil {
+		r.fill(ops, style.transform, style.fill, style.opacity*style.fillOpacity)
+	}
+	if style.stroke != nil && style.strokeWidth > 0 {
+		r.stroke(ops, style.transform, style.strokeWidth*style.transform.scale(), style.stroke, style.opacity*style.strokeOpac)
+	}
+}
+
+func (r *svgRenderer) paint(paint color.Color, opacity float64) {
+	red, green, blue, alpha := paint.RGBA()
+	scale := opacity
+	src := image.NewUniform(color.RGBA64{
+		uint16(float64(red) * scale), uint16(float64(green) * scale),
+		uint16(float64(blue) * scale), uint16(float64(alpha) * scale),
+	})
+	r.raster.Draw(r.dst, r.dst.Bounds(), src, image.Point{})
+}
+
+func (r *svgRenderer) fill(ops []svgPathOp, matrix svgMatrix, paint color.Color, opacity float64) {
+	bounds := r.dst.Bounds()
+	r.raster.Reset(bounds.Dx(), bounds.Dy())
+	point := func(p svgPoint) (float32, float32) {
+		x, y := matrix.apply(p.x, p.y)
+		return float32(x), float32(y)
+	}
+	open := false
+	for _, op := range ops {
+		switch op.op {
+		case 'M':
+			if open {
+				r.raster.ClosePath()
+			}
+			r.raster.MoveTo(point(op.points[0]))
+			open = true
+		case 'L':
+			r.raster.LineTo(point(op.points[0]))
+		case 'Q':
+			x1, y1 := point(op.points[0])
+			x2, y2 := point(op.points[1])
+			r.raster.QuadTo(x1, y1, x2, y2)
+		case 'C':
+			x1, y1 := point(op.points[0])
+			x2, y2 := point(op.points[1])
+			x3, y3 := point(op.points[2])
+			r.raster.CubeTo(x1, y1, x2, y2, x3, y3)
+		case 'Z':
+			r.raster.ClosePath()
+			open = false
+		}
+	}
+	if open {
+		r.raster.ClosePath()
+	}
+	r.paint(paint, opacity)
+}
+
+// flattenPath converts a path to polylines in device space
+func flattenPath(ops []svgPathOp, matrix svgMatrix) [][]svgPoint {
+	polylines := [][]svgPoint{}
+	current := []svgPoint{}
+	transform := func(p svgPoint) svgPoint {
+		x, y := matrix.apply(p.x, p.y)
+		return svgPoint{x, y}
+	}
+	last := func() svgPoint { return current[len(current)-1] }
+	const steps = 16
+	for _, op := range ops {
+		switch op.op {
+		case 'M':
+			if len(current) > 1 {
+				polylines = append(polylines, current)
+			}
+			current = []svgPoint{transform(op.points[0])}
+		case 'L':
+			if len(current) > 0 {
+				current = append(current, transform(op.points[0]))
+			}
+		case 'Q', 'C':
+			if len(current) == 0 {
+				continue
+			}
+			control := []svgPoint{last()}
+			for _, p := range op.points {
+				control = append(control, transform(p))
+			}
+			for i := 1; i <= steps; i++ {
+				t := float64(i) / steps
+				current = append(current, bezierPoint(control, t))
+			}
+		case 'Z':
+			if len(current) > 0 {
+				current = append(current, current[0])
+				polylines = append(polylines, current)
+				current = []svgPoint{current[0]}
+			}
+		}
+	}
+	if len(current) > 1 {
+		polylines = append(polylines, current)
+	}
+	return polylines
+}
+
+// bezierPoint evaluates a Bézier curve of any degree (de Casteljau)
+func bezierPoint(control []svgPoint, t float64) svgPoint {
+	points := append([]svgPoint{}, control...)
+	for n := len(points) - 1; n > 0; n-- {
+		for i := 0; i < n; i++ {
+			points[i] = svgPoint{points[i].x + (points[i+1].x-points[i].x)*t, points[i].y + (points[i+1].y-points[i].y)*t}
+		}
+	}
+	return points[0]
+}
+
+// addPolygon adds a closed polygon to the rasterizer, always with the same orientation
+// so that overlapping polygons are merged instead of cancelling each other
+func (r *svgRenderer) addPolygon(points []svgPoint) {
+	area := 0.0
+	for i := range points {
+		j := (i + 1) % len(points)
+		area += points[i].x*points[j].y - points[j].x*points[i].y
+	}
+	if area < 0 {
+		for i, j := 0, len(points)-1; i < j; i, j = i+1, j-1 {
+			points[i], points[j] = points[j], points[i]
+		}
+	}
+	r.raster.MoveTo(float32(points[0].x), float32(points[0].y))
+	for _, p := range points[1:] {
+		r.raster.LineTo(float32(p.x), float32(p.y))
+	}
+	r.raster.ClosePath()
+}
+
+// stroke draws each segment of the path as a quad, with round joins and caps
+func (r *svgRenderer) stroke(ops []svgPathOp, matrix svgMatrix, width float64, paint color.Color, opacity float64) {
+	bounds := r.dst.Bounds()
+	r.raster.Reset(bounds.Dx(), bounds.Dy())
+	half := width / 2
+	for _, polyline := range flattenPath(ops, matrix) {
+		for i, p := range polyline {
+			if half > 0.75 {
+				circle := make([]svgPoint, 12)
+				for k := range circle {
+					angle := float64(k) * 2 * math.Pi / float64(len(circle))
+					circle[k] = svgPoint{p.x + half*math.Cos(angle), p.y + half*math.Sin(angle)}
+				}
+				r.addPolygon(circle)
+			}
+			if i == 0 {
+				continue
+			}
+			prev := polyline[i-1]
+			dx, dy := p.x-prev.x, p.y-prev.y
+			length := math.Hypot(dx, dy)
+			if length == 0 {
+				continue
+			}
+			nx, ny := -dy/length*half, dx/length*half
+			r.addPolygon([]svgPoint{
+				{prev.x + nx, prev.y + ny}, {p.x + nx, p.y + ny},
+				{p.x - nx, p.y - ny}, {prev.x - nx, prev.y - ny},
+			})
+		}
+	}
+	r.paint(paint, opacity)
+}
+
+type svgPathTokenizer struct {
+	text string
+	pos  int
+}
+
+func (t *svgPathTokenizer) skipSeparators() {
+	for t.pos < len(t.text) && strings.IndexByte(" \t\r\n,", t.text[t.pos]) >= 0 {
+		t.pos++
+	}
+}
+
+// number reads a number, e.g. `-1.5e3` (note that `1.5.5` is two numbers)
+func (t *svgPathTokenizer) number() (float64, bool) {
+	start := t.pos
+	i := t.pos
+	if i < len(t.text) && (t.text[i] == '-' || t.text[i] == '+') {
+		i++
+	}
+	digits, dot := false, false
+	for i < len(t.text) {
+		c := t.text[i]
+		if c >= '0' && c <= '9' {
+			digits = true
+		} else if c == '.' && !dot {
+			dot = true
+		} else {
+			break
+		}
+		i++
+	}
+	if !digits {
+		return 0, false
+	}
+	if i < len(t.text) && (t.text[i] == 'e' || t.text[i] == 'E') {
+		j := i + 1
+		if j < len(t.text) && (t.text[j] == '-' || t.text[j] == '+') {
+			j++
+		}
+		if j < len(t.text) && t.text[j] >= '0' && t.text[j] <= '9' {
+			for j < len(t.text) && t.text[j] >= '0' && t.text[j] <= '9' {
+				j++
+			}
+			i = j
+		}
+	}
+	value, err := strconv.ParseFloat(t.text[start:i], 64)
+	if err != nil {
+		return 0, false
+	}
+	t.pos = i
+	return value, true
+}
+
+// flag reads an arc flag, which may not be separated from the next number
+func (t *svgPathTokenizer) flag() (bool, bool) {
+	t.skipSeparators()
+	if t.pos < len(t.text) && (t.text[t.pos] == '0' || t.text[t.pos] == '1') {
+		t.pos++
+		return t.text[t.pos-1] == '1', true
+	}
+	return false, false
+}
+
+func (t *svgPathTokenizer) numbers(n int) ([]float64, bool) {
+	values := make([]float64, n)
+	for i := range values {
+		t.skipSeparators()
+		value, ok := t.number()
+		if !ok {
+			return nil, false
+		}
+		values[i] = value
+	}
+	return values, true
+}
+
+// parseSvgPath parses path data into absolute M/L/Q/C/Z operations
+func parseSvgPath(d string) []svgPathOp {
+	ops := []svgPathOp{}
+	t := &svgPathTokenizer{text: d}
+	var cmd byte
+	cur, start := svgPoint{}, svgPoint{}
+	lastControl := svgPoint{}
+	var lastCmd byte
+	for {
+		t.skipSeparators()
+		if t.pos >= len(t.text) {
+			return ops
+		}
+		if c := t.text[t.pos]; strings.IndexByte("MmLlHhVvCcSsQqTtAaZz", c) >= 0 {
+			cmd = c
+			t.pos++
+		} else if cmd == 0 {
+			return ops
+		}
+		relative := cmd >= 'a'
+		offset := func(x, y float64) svgPoint {
+			if relative {
+				return svgPoint{cur.x + x, cur.y + y}
+			}
+			return svgPoint{x, y}
+		}
+		upper := cmd &^ 0x20
+		switch upper {
+		case 'Z':
+			ops = append(ops, svgPathOp{'Z', nil})
+			cur = start
+			lastCmd = 'Z'
+			// Numbers can't follow a closepath: the path stops at the next one
+			cmd = 0
+			continue
+		case 'M', 'L', 'T':
+			values, ok := t.numbers(2)
+			if !ok {
+				return ops
+			}
+			p := offset(values[0], values[1])
+			if upper == 'M' {
+				ops = append(ops, svgPathOp{'M', []svgPoint{p}})
+				start = p
+				// Subsequent pairs are implicit lineto commands
+				if relative {
+					cmd = 'l'
+				} else {
+					cmd = 'L'
+				}
+			} else if upper == 'T' {
+				control := cur
+				if lastCmd == 'Q' || lastCmd == 'T' {
+					control = svgPoint{2*cur.x - lastControl.x, 2*cur.y - lastControl.y}
+				}
+				ops = append(ops, svgPathOp{'Q', []svgPoint{control, p}})
+				lastControl = control
+			} else {
+				ops = append(ops, svgPathOp{'L', []svgPoint{p}})
+			}
+			cur = p
+		case 'H', 'V':
+			values, ok := t.numbers(1)
+			if !ok {
+				return ops
+			}
+			p := cur
+			if upper == 'H' {
+				p.x = values[0]
+				if relative {
+					p.x += cur.x
+				}
+			} else {
+				p.y = values[0]
+				if relative {
+					p.y += cur.y
+				}
+			}
+			ops = append(ops, svgPathOp{'L', []svgPoint{p}})
+			cur = p
+		case 'C', 'S':
+			n := 6
+			if upper == 'S' {
+				n = 4
+			}
+			values, ok := t.numbers(n)
+			if !ok {
+				return ops
+			}
+			var c1 svgPoint
+			if upper == 'S' {
+				c1 = cur
+				if lastCmd == 'C' || lastCmd == 'S' {
+					c1 = svgPoint{2*cur.x - lastControl.x, 2*cur.y - lastControl.y}
+				}
+				values = append([]float64{0, 0}, values...)
+			} else {
+				c1 = offset(values[0], values[1])
+			}
+			c2 := offset(values[2], values[3])
+			p := offset(values[4], values[5])
+			ops = append(ops, svgPathOp{'C', []svgPoint{c1, c2, p}})
+			lastControl = c2
+			cur = p
+		case 'Q':
+			values, ok := t.numbers(4)
+			if !ok {
+				return ops
+			}
+			control := offset(values[0], values[1])
+			p := offset(values[2], values[3])
+			ops = append(ops, svgPathOp{'Q', []svgPoint{control, p}})
+			lastControl = control
+			cur = p
+		case 'A':
+			radii, ok := t.numbers(3)
+			if !ok {
+				return ops
+			}
+			largeArc, ok1 := t.flag()
+			sweep, ok2 := t.flag()
+			end, ok3 := t.numbers(2)
+			if !ok1 || !ok2 || !ok3 {
+				return ops
+			}
+			p := offset(end[0], end[1])
+			ops = append(ops, arcToCubics(cur, p, radii[0], radii[1], radii[2], largeArc, sweep)...)
+			cur = p
+		}
+		lastCmd = upper
+	}
+}
+
+// arcToCubics converts an SVG elliptical arc to cubic Bézier curves
+// (https://www.w3.org/TR/SVG/implnote.html#ArcConversionEndpointToCenter)
+func arcToCubics(from, to svgPoint, rx, ry, rotation float64, largeArc, sweep bool) []svgPathOp {
+	rx, ry = math.Abs(rx), math.Abs(ry)
+	line := []svgPathOp{{'L', []svgPoint{to}}}
+	if rx == 0 || ry == 0 || (from.x == to.x && from.y == to.y) || !isFinite(from.x, from.y, to.x, to.y, rx, ry, rotation) {
+		return line
+	}
+	phi := rotation * math.Pi / 180
+	cosPhi, sinPhi := math.Cos(phi), math.Sin(phi)
+	dx, dy := (from.x-to.x)/2, (from.y-to.y)/2
+	x1p := cosPhi*dx + sinPhi*dy
+	y1p := -sinPhi*dx + cosPhi*dy
+	// Scale up the radii if they are too small
+	if lambda := x1p*x1p/(rx*rx) + y1p*y1p/(ry*ry); lambda > 1 {
+		rx, ry = rx*math.Sqrt(lambda), ry*math.Sqrt(lambda)
+	}
+	num := rx*rx*ry*ry - rx*rx*y1p*y1p - ry*ry*x1p*x1p
+	den := rx*rx*y1p*y1p + ry*ry*x1p*x1p
+	coef := math.Sqrt(math.Max(0, num/den))
+	if largeArc == sweep {
+		coef = -coef
+	}
+	cxp, cyp := coef*rx*y1p/ry, -coef*ry*x1p/rx
+	cx := cosPhi*cxp - sinPhi*cyp + (from.x+to.x)/2
+	cy := sinPhi*cxp + cosPhi*cyp + (from.y+to.y)/2
+
+	angle := func(ux, uy, vx, vy float64) float64 {
+		return math.Atan2(ux*vy-uy*vx, ux*vx+uy*vy)
+	}
+	theta1 := angle(1, 0, (x1p-cxp)/rx, (y1p-cyp)/ry)
+	delta := angle((x1p-cxp)/rx, (y1p-cyp)/ry, (-x1p-cxp)/rx, (-y1p-cyp)/ry)
+	if !sweep && delta > 0 {
+		delta -= 2 * math.Pi
+	} else if sweep && delta < 0 {
+		delta += 2 * math.Pi
+	}
+
+	if !isFinite(cx, cy, rx, ry, theta1, delta) {
+		return line
+	}
+	segments := int(math.Ceil(math.Abs(delta) / (math.Pi / 2)))
+	if segments == 0 {
+		return line
+	}
+	step := delta / float64(segments)
+	k := 4.0 / 3 * math.Tan(step/4)
+	point := func(theta float64) (svgPoint, svgPoint) {
+		cos, sin := math.Cos(theta), math.Sin(theta)
+		p := svgPoint{cx + rx*cos*cosPhi - ry*sin*sinPhi, cy + rx*cos*sinPhi + ry*sin*cosPhi}
+		d := svgPoint{-rx*sin*cosPhi - ry*cos*sinPhi, -rx*sin*sinPhi + ry*cos*cosPhi}
+		return p, d
+	}
+	ops := []svgPathOp{}
+	theta := theta1
+	p1, d1 := point(theta)
+	for range segments {
+		p2, d2 := point(theta + step)
+		ops = append(ops, svgPathOp{'C', []svgPoint{
+			{p1.x + k*d1.x, p1.y + k*d1.y},
+			{p2.x - k*d2.x, p2.y - k*d2.y},
+			p2,
+		}})
+		theta += step
+		p1, d1 = p2, d2
+	}
+	// Avoid rounding errors on the end point
+	ops[len(ops)-1].points[2] = to
+	return ops
+}
+
+func isFinite(values ...float64) bool {
+	for _, value := range values {
+		if math.IsNaN(value) || math.IsInf(value, 0) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,423 @@
+package godocx
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Schema order of the children of the property nodes touched by the table
+// commands. Word refuses documents whose property children are out of order.
+var (
+	TCPR_ORDER = []string{
+		"w:cnfStyle", "w:tcW", "w:gridSpan", "w:hMerge", "w:vMerge", "w:tcBorders", "w:shd",
+		"w:noWrap", "w:tcMar", "w:textDirection", "w:tcFitText", "w:vAlign", "w:hideMark",
+		"w:headers", "w:cellIns", "w:cellDel", "w:cellMerge", "w:tcPrChange",
+	}
+	TRPR_ORDER = []string{
+		"w:cnfStyle", "w:divId", "w:gridBefore", "w:gridAfter", "w:wBefore", "w:wAfter",
+		"w:cantSplit", "w:trHeight", "w:tblHeader", "w:tblCellSpacing", "w:jc", "w:hidden",
+		"w:ins", "w:del", "w:trPrChange",
+	}
+	RPR_ORDER = []string{
+		"w:rStyle", "w:rFonts", "w:b", "w:bCs", "w:i", "w:iCs", "w:caps", "w:smallCaps",
+		"w:strike", "w:dstrike", "w:outline", "w:shadow", "w:emboss", "w:imprint", "w:noProof",
+		"w:snapToGrid", "w:vanish", "w:webHidden", "w:color", "w:spacing", "w:w", "w:kern",
+		"w:position", "w:sz", "w:szCs", "w:highlight", "w:u", "w:effect", "w:bdr", "w:shd",
+		"w:fitText", "w:vertAlign", "w:rtl", "w:cs", "w:em", "w:lang", "w:eastAsianLayout",
+		"w:specVanish", "w:oMath", "w:rPrChange",
+	}
+	TBL_ORDER = []string{"w:tblPr", "w:tblGrid"}
+	TR_ORDER  = []string{"w:tblPrEx", "w:trPr"}
+	TC_ORDER  = []string{"w:tcPr"}
+	RUN_ORDER = []string{"w:rPr"}
+)
+
+type cellStyle struct {
+	shade  string
+	color  string
+	bold   *bool
+	italic *bool
+	height float64 // cm, rows only
+}
+
+// evalCellStyle evaluates the `key=<expression>` arguments of STYLE-CELL / STYLE-ROW
+func evalCellStyle(args map[string]string, ctx *Context, data *ReportData) (*cellStyle, error) {
+	style := &cellStyle{}
+	for key, expr := range args {
+		value, err := runAndGetValue(expr, ctx, data)
+		if err != nil {
+			return nil, err
+		}
+		switch key {
+		case "shade", "fill":
+			style.shade, err = toHexColor(value)
+		case "color":
+			style.color, err = toHexColor(value)
+		case "bold":
+			bold := isTruthy(value)
+			style.bold = &bold
+		case "italic":
+			italic := isTruthy(value)
+			style.italic = &italic
+		case "height":
+			height, ok := toNumber(value)
+			if !ok {
+				return nil, fmt.Errorf("Invalid row height: %v", value)
+			}
+			style.height = height
+		default:
+			return nil, errors.New("Unknown style property: " + key)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return style, nil
+}
+
+var hexColorRegexp = regexp.MustCompile(`^[0-9A-F]{6}$`)
+
+// toHexColor converts a color value ("#4472c4", "4472C4" or "auto") to the RRGGBB form of
+// the color attributes. An empty value gives an empty color (no change)
+func toHexColor(value VarValue) (string, error) {
+	if value == nil {
+		return "", nil
+	}
+	color := strings.ToUpper(strings.TrimPrefix(strings.TrimSpace(fmt.Sprint(value)), "#"))
+	if color == "" || color == "AUTO" {
+		return strings.ToLower(color), nil
+	}
+	if !hexColorRegexp.MatchString(color) {
+		return "", fmt.Errorf("Invalid color: %q (expected 6 hexadecimal digits or auto)", fmt.Sprint(value))
+	}
+	return color, nil
+}
+
+func applyCellStyle(tc *NonTextNode, style *cellStyle) {
+	if style.shade != "" {
+		tcPr := ensureChildInOrder(tc, "w:tcPr", TC_ORDER)
+		setChildInOrder(tcPr, NewNonTextNode("w:shd", map[string]string{
+			"w:val":   "clear",
+			"w:color": "auto",
+			"w:fill":  style.shade,
+		}, nil), TCPR_ORDER)
+	}
+	if style.color == "" && style.bold == nil && style.italic == nil {
+		return
+	}
+	for _, run := range findDescendants(tc, R_TAG) {
+		rPr := ensureChildInOrder(run, RPR_TAG, RUN_ORDER)
+		if style.color != "" {
+			setChildInOrder(rPr, NewNonTextNode("w:color", map[string]string{"w:val": style.color}, nil), RPR_ORDER)
+		}
+		if style.bold != nil {
+			setChildInOrder(rPr, NewNonTextNode("w:b", map[string]string{"w:val": fmt.Sprint(*style.bold)}, nil), RPR_ORDER)
+			setChildInOrder(rPr, NewNonTextNode("w:bCs", map[string]string{"w:val": fmt.Sprint(*style.bold)}, nil), RPR_ORDER)
+		}
+		if style.italic != nil {
+			setChildInOrder(rPr, NewNonTextNode("w:i", map[string]string{"w:val": fmt.Sprint(*style.italic)}, nil), RPR_ORDER)
+			setChildInOrder(rPr, NewNonTextNode("w:iCs", map[string]string{"w:val": fmt.Sprint(*style.italic)}, nil), RPR_ORDER)
+		}
+	}
+}
+
+func applyRowStyle(tr *NonTextNode, style *cellStyle) {
+	if style.height > 0 {
+		trPr := ensureChildInOrder(tr, "w:trPr", TR_ORDER)
+		setChildInOrder(trPr, NewNonTextNode("w:trHeight", map[string]string{
+			"w:val":   fmt.Sprint(int(style.height * TWIPS_PER_CM)),
+			"w:hRule": "atLeast",
+		}, nil), TRPR_ORDER)
+	}
+	for _, child := range tr.Children() {
+		if tc, ok := child.(*NonTextNode); ok && tc.Tag == TC_TAG {
+			applyCellStyle(tc, style)
+		}
+	}
+}
+
+// ensureChildInOrder returns the child of parent with the given tag, creating it
+// at its schema position when missing
+func ensureChildInOrder(parent *NonTextNode, tag string, order []string) *NonTextNode {
+	if child := findChild(parent, tag); child != nil {
+		return child
+	}
+	child := NewNonTextNode(tag, map[string]string{}, nil)
+	setChildInOrder(parent, child, order)
+	return child
+}
+
+// setChildInOrder inserts child into parent, replacing any child with the same tag.
+// Tags missing from order are considered to come after all the listed ones.
+func setChildInOrder(parent *NonTextNode, child *NonTextNode, order []string) {
+	child.SetParent(parent)
+	children := parent.Children()
+	for i, existing := range children {
+		if nonTextExisting, ok := existing.(*NonTextNode); ok && nonTextExisting.Tag == child.Tag {
+			children[i] = child
+			return
+		}
+	}
+	childIdx := slices.Index(order, child.Tag)
+	insertAt := len(children)
+	for i, existing := range children {
+		if childIdx < 0 {
+			break
+		}
+		existingIdx := -1
+		if nonTextExisting, ok := existing.(*NonTextNode); ok {
+			existingIdx = slices.Index(order, nonTextExisting.Tag)
+		}
+		if existingIdx < 0 || existingIdx > childIdx {
+			insertAt = i
+			break
+		}
+	}
+	parent.SetChildren(slices.Insert(children, insertAt, Node(child)))
+}
+
+// Column settings collected during the walk (COLUMN-IF, COLUMN-WIDTH), by output `w:tbl` node
+type tableColumns struct {
+	hidden  []int
+	weights map[int]float64
+}
+
+type pendingColumn struct {
+	hidden bool
+	weight float64
+}
+
+// DEFAULT_TABLE_WIDTH is used when neither the grid nor the table specify a width (A4 text width, in twips)
+const DEFAULT_TABLE_WIDTH = 9638
+
+// findCell returns node if it is a table cell, or the cell containing it
+func findCell(node Node) *NonTextNode {
+	if tc, ok := node.(*NonTextNode); ok && tc.Tag == TC_TAG {
+		return tc
+	}
+	return findAncestor(node, TC_TAG)
+}
+
+func registerColumn(ctx *Context, tc *NonTextNode, column *pendingColumn) {
+	tr, isTr := tc.Parent().(*NonTextNode)
+	if !isTr || tr.Parent() == nil {
+		return
+	}
+	tbl := tr.Parent()
+	columns, ok := ctx.tableColumns[tbl]
+	if !ok {
+		columns = &tableColumns{weights: map[int]float64{}}
+		ctx.tableColumns[tbl] = columns
+	}
+	idx := gridIndex(tc)
+	if column.hidden && !slices.Contains(columns.hidden, idx) {
+		columns.hidden = append(columns.hidden, idx)
+	}
+	if column.weight > 0 {
+		for i := range cellSpan(tc) {
+			columns.weights[idx+i] = column.weight / float64(cellSpan(tc))
+		}
+	}
+}
+
+func getIntAttr(node *NonTextNode, attr string) int {
+	if node == nil {
+		return 0
+	}
+	value, err := strconv.Atoi(node.Attrs[attr])
+	if err != nil {
+		return 0
+	}
+	return value
+}
+
+func findChild(parent Node, tag string) *NonTextNode {
+	for _, child := range parent.Children() {
+		if nonTextChild, ok := child.(*NonTextNode); ok && nonTextChild.Tag == tag {
+			return nonTextChild
+		}
+	}
+	return nil
+}
+
+func findChildren(parent Node, tag string) []*NonTextNode {
+	found := []*NonTextNode{}
+	for _, child := range parent.Children() {
+		if nonTextChild, ok := child.(*NonTextNode); ok && nonTextChild.Tag == tag {
+			found = append(found, nonTextChild)
+		}
+	}
+	return found
+}
+
+func cellSpan(tc *NonTextNode) int {
+	tcPr := findChild(tc, "w:tcPr")
+	if tcPr == nil {
+		return 1
+	}
+	return max(1, getIntAttr(findChild(tcPr, "w:gridSpan"), "w:val"))
+}
+
+func rowGridBefore(tr Node) int {
+	trPr := findChild(tr, "w:trPr")
+	if trPr == nil {
+		return 0
+	}
+	return getIntAttr(findChild(trPr, "w:gridBefore"), "w:val")
+}
+
+// gridIndex returns the index of the first grid column covered by the cell
+func gridIndex(tc *NonTextNode) int {
+	tr := tc.Parent()
+	idx := rowGridBefore(tr)
+	for _, cell := range findChildren(tr, TC_TAG) {
+		if cell == tc {
+			break
+		}
+		idx += cellSpan(cell)
+	}
+	return idx
+}
+
+func rowGridCount(tr Node) int {
+	count := rowGridBefore(tr)
+	for _, tc := range findChildren(tr, TC_TAG) {
+		count += cellSpan(tc)
+	}
+	trPr := findChild(tr, "w:trPr")
+	if trPr != nil {
+		count += getIntAttr(findChild(trPr, "w:gridAfter"), "w:val")
+	}
+	return count
+}
+
+func setCellSpan(tc *NonTextNode, span int) {
+	tcPr := ensureChildInOrder(tc, "w:tcPr", TC_ORDER)
+	setChildInOrder(tcPr, NewNonTextNode("w:gridSpan", map[string]string{"w:val": fmt.Sprint(span)}, nil), TCPR_ORDER)
+}
+
+// removeGridColumn removes the cell covering the grid column idx of a row (or
+// reduces its span, if it covers several columns)
+func removeGridColumn(tr *NonTextNode, idx int) {
+	start := rowGridBefore(tr)
+	for _, tc := range findChildren(tr, TC_TAG) {
+		span := cellSpan(tc)
+		if idx >= start && idx < start+span {
+			if span > 1 {
+				setCellSpan(tc, span-1)
+			} else {
+				tr.SetChildren(slices.DeleteFunc(tr.Children(), func(n Node) bool { return n == tc }))
+			}
+			return
+		}
+		start += span
+	}
+}
+
+// fixTableGrids updates the `w:tblGrid` of the generated tables so that it matches
+// their cells, after column loops added or removed some of them and COLUMN-IF hid others.
+// The width of the table is split evenly between the columns, or according to the
+// COLUMN-WIDTH weights. Only the tables whose cells were changed are updated: rows
+// may be shorter than the grid in templates.
+func fixTableGrids(root Node, tables map[Node]*tableColumns) {
+	for _, tbl := range findDescendants(root, TBL_TAG) {
+		rows := findChildren(tbl, TR_TAG)
+		columns := tables[tbl]
+		if columns == nil {
+			continue
+		}
+		weights := map[int]float64{}
+		hidden := slices.Clone(columns.hidden)
+		// Remove from the right, so that the indexes stay valid
+		slices.Sort(hidden)
+		slices.Reverse(hidden)
+		for _, idx := range hidden {
+			for _, tr := range rows {
+				removeGridColumn(tr, idx)
+			}
+		}
+		for idx, weight := range columns.weights {
+			if slices.Contains(hidden, idx) {
+				continue
+			}
+			shift := 0
+			for _, hiddenIdx := range hidden {
+				if hiddenIdx < idx {
+					shift++
+				}
+			}
+			weights[idx-shift] = weight
+		}
+
+		numCols := 0
+		for _, tr := range rows {
+			numCols = max(numCols, rowGridCount(tr))
+		}
+		tblGrid := findChild(tbl, "w:tblGrid")
+		var gridCols []*NonTextNode
+		if tblGrid != nil {
+			gridCols = findChildren(tblGrid, "w:gridCol")
+		}
+		if numCols == 0 || (numCols == len(gridCols) && len(columns.hidden) == 0 && len(columns.weights) == 0) {
+			continue
+		}
+
+		total := 0
+		for _, gridCol := range gridCols {
+			total += getIntAttr(gridCol, "w:w")
+		}
+		if total == 0 {
+			if tblPr := findChild(tbl, "w:tblPr"); tblPr != nil {
+				if tblW := findChild(tblPr, "w:tblW"); tblW != nil && tblW.Attrs["w:type"] == "dxa" {
+					total = getIntAttr(tblW, "w:w")
+				}
+			}
+		}
+		if total == 0 {
+			total = DEFAULT_TABLE_WIDTH
+		}
+
+		sumWeights := 0.0
+		colWeights := make([]float64, numCols)
+		for i := range colWeights {
+			colWeights[i] = 1
+			if weight, ok := weights[i]; ok {
+				colWeights[i] = weight
+			}
+			sumWeights += colWeights[i]
+		}
+		widths := make([]int, numCols)
+		newGridCols := make([]Node, numCols)
+		for i := range widths {
+			widths[i] = int(float64(total) * colWeights[i] / sumWeights)
+			newGridCols[i] = NewNonTextNode("w:gridCol", map[string]string{"w:w": fmt.Sprint(widths[i])}, nil)
+		}
+		if tblGrid == nil {
+			tblGrid = NewNonTextNode("w:tblGrid", map[string]string{}, nil)
+			setChildInOrder(tbl, tblGrid, TBL_ORDER)
+		}
+		for _, gridCol := range newGridCols {
+			gridCol.SetParent(tblGrid)
+		}
+		tblGrid.SetChildren(newGridCols)
+
+		// Cell widths take precedence over the grid in Word, so keep them in sync
+		for _, tr := range rows {
+			start := rowGridBefore(tr)
+			for _, tc := range findChildren(tr, TC_TAG) {
+				span := cellSpan(tc)
+				width := 0
+				for i := start; i < start+span && i < numCols; i++ {
+					width += widths[i]
+				}
+				tcPr := ensureChildInOrder(tc, "w:tcPr", TC_ORDER)
+				setChildInOrder(tcPr, NewNonTextNode("w:tcW", map[string]string{"w:w": fmt.Sprint(width), "w:type": "dxa"}, nil), TCPR_ORDER)
+				start += span
+			}
+		}
+	}
+}
@@ -0,0 +1,148 @@
+package godocx
+
+import (
+	"archive/zip"
+	"fmt"
+	"io"
+	"iter"
+	"os"
+	"strings"
+)
+
+// Template is a parsed and preprocessed template, to generate many reports
+// (e.g. mail merge) without reading and parsing it for each one
+type Template struct {
+	data         []byte
+	options      CreateReportOptions
+	root         Node            // preprocessed main document
+	extras       map[string]Node // preprocessed headers and footers
+	mainDocument string
+	contentTypes *NonTextNode
+}
+
+type RenderedReport struct {
+	Name string
+	Data []byte
+}
+
+// NewTemplate reads and parses a template file
+func NewTemplate(templatePath string, options CreateReportOptions) (*Template, error) {
+	data, err := os.ReadFile(templatePath)
+	if err != nil {
+		return nil, err
+	}
+	return NewTemplateFromBytes(data, options)
+}
+
+// NewTemplateFromBytes parses a template held in memory
+func NewTemplateFromBytes(data []byte, options CreateReportOptions) (*Template, error) {
+	zip, err := NewZipArchiveFromBytes(data, io.Discard)
+	if err != nil {
+		return nil, err
+	}
+
+	// xml parse the document
+	parseResult, err := ParseTemplate(zip)
+	if err != nil {
+		return nil, fmt.Errorf("ParseTemplate failed: %w", err)
+	}
+
+	if options.CmdDelimiter == nil {
+		options.CmdDelimiter = &Delimiters{
+			Open:  DEFAULT_CMD_DELIMITER,
+			Close: DEFAULT_CMD_DELIMITER,
+		}
+	}
+	if options.LiteralXmlDelimiter == "" {
+		options.LiteralXmlDelimiter = DEFAULT_LITERAL_XML_DELIMITER
+	}
+
+	if options.CleanTemplate {
+		CleanTemplate(parseResult.Root)
+		for _, extraNode := range parseResult.Extras {
+			CleanTemplate(extraNode)
+		}
+	}
+
+	preppedTemplate, err := PreprocessTemplate(parseResult.Root, *options.CmdDelimiter)
+	if err != nil {
+		return nil, fmt.Errorf("PreprocessTemplate failed: %w", err)
+	}
+	extras := map[string]Node{}
+	for extraPath, extraNode := range parseResult.Extras {
+		extras[extraPath], err = PreprocessTemplate(extraNode, *options.CmdDelimiter)
+		if err != nil {
+			return nil, fmt.Errorf("PreprocessTemplate failed: %w", err)
+		}
+	}
+
+	return &Template{
+		data:         data,
+		options:      options,
+		root:         preppedTemplate,
+		extras:       extras,
+		mainDocument: parseResult.MainDocument,
+		contentTypes: parseResult.ContentTypes,
+	}, nil
+}
+
+// RenderEach generates a report per item, as they are iterated. The reports are named by
+// nameExpr, evaluated with the data of the item (".docx" is added if missing), or numbered
+// (report1.docx, report2.docx...) when it is empty. The iteration stops after an error.
+//
+// The reports are yielded with an error rather than as name/content pairs
+// (iter.Seq2[string, []byte]), which would have no way to report a failed rendering.
+func (t *Template) RenderEach(items []ReportData, nameExpr string) iter.Seq2[*RenderedReport, error] {
+	return func(yield func(*RenderedReport, error) bool) {
+		for i := range items {
+			report, err := t.renderItem(&items[i], i, nameExpr)
+			if err != nil {
+				yield(nil, fmt.Errorf("Report %d: %w", i+1, err))
+				return
+			}
+			if !yield(report, nil) {
+				return
+			}
+		}
+	}
+}
+
+func (t *Template) renderItem(data *ReportData, idx int, nameExpr string) (*RenderedReport, error) {
+	name := fmt.Sprintf("report%d", idx+1)
+	if nameExpr != "" {
+		ctx := NewContext(t.options, 0)
+		value, err := runAndGetValue(nameExpr, &ctx, data)
+		if err != nil {
+			return nil, err
+		}
+		name = fmt.Sprint(value)
+	}
+	if !strings.HasSuffix(strings.ToLower(name), ".docx") {
+		name += ".docx"
+	}
+	output, err := t.Render(data)
+	if err != nil {
+		return nil, err
+	}
+	return &RenderedReport{Name: name, Data: output}, nil
+}
+
+// WriteZip generates a report per item (see RenderEach), and writes them to a zip archive
+func (t *Template) WriteZip(w io.Writer, items []ReportData, nameExpr string) error {
+	writer := zip.NewWriter(w)
+	names := map[string]bool{}
+	for report, err := range t.RenderEach(items, nameExpr) {
+		if err != nil {
+			return err
+		}
+		if names[report.Name] {
+			return fmt.Errorf("Duplicate report name %s", report.Name)
+		}
+		names[report.Name] = true
+		err = ZipSet(writer, report.Name, report.Data)
+		if err != nil {
+			return err
+		}
+	}
+	return writer.Close()
+}
@@ -0,0 +1,318 @@
+package godocx
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+const (
+	SETTINGS_CONTENT_TYPE  = "application/vnd.openxmlformats-officedocument.wordprocessingml.settings+xml"
+	SETTINGS_RELATION_TYPE = "http://schemas.openxmlformats.org/officeDocument/2006/relationships/settings"
+	WORDML_NAMESPACE       = "http://schemas.openxmlformats.org/wordprocessingml/2006/main"
+
+	// Cached page number of the generated TOC entries, until the fields are updated
+	TOC_PAGE_PLACEHOLDER = "#"
+	TOC_EMPTY_TEXT       = "No table of contents entries found."
+)
+
+var (
+	// Children of `w:settings` following `w:updateFields` in the schema
+	SETTINGS_AFTER_UPDATE_FIELDS = []string{
+		"w:hdrShapeDefaults", "w:footnotePr", "w:endnotePr", "w:compat", "w:docVars", "w:rsids",
+		"w:attachedSchema", "w:themeFontLang", "w:clrSchemeMapping", "w:doNotIncludeSubdocsInStats",
+		"w:doNotAutoCompressPictures", "w:forceUpgrade", "w:captions", "w:readModeInkLockDown",
+		"w:smartTagType", "w:shapeDefaults", "w:doNotEmbedSmartTags", "w:decimalSymbol", "w:listSeparator",
+	}
+
+	tocLevelsRegexp    = regexp.MustCompile(`\\o\s+"(\d)-(\d)"`)
+	headingStyleRegexp = regexp.MustCompile(`(?i)^heading\s*(\d)$`)
+)
+
+// SetUpdateFields has Word update the fields of the document (e.g. page numbers) when opening it,
+// with `w:updateFields` in its settings
+func SetUpdateFields(documentComponent string, zip *ZipArchive, contentTypes *NonTextNode) error {
+	xmlOptions := XmlOptions{LiteralXmlDelimiter: DEFAULT_LITERAL_XML_DELIMITER}
+	relsPath := fmt.Sprintf("%s/_rels/%s.rels", TEMPLATE_PATH, documentComponent)
+	rels, err := getRelsFromZip(zip, relsPath)
+	if err != nil {
+		return err
+	}
+	settingsPath := findRelTarget(rels, SETTINGS_RELATION_TYPE)
+	var settings *NonTextNode
+	if settingsPath == "" {
+		settingsPath = TEMPLATE_PATH + "/settings.xml"
+		settings = NewNonTextNode("w:settings", map[string]string{"xmlns:w": WORDML_NAMESPACE}, nil)
+		AddChild(rels, NewNonTextNode("Relationship", map[string]string{
+			"Id":     uniqueRelId(rels, "settings"),
+			"Type":   SETTINGS_RELATION_TYPE,
+			"Target": "settings.xml",
+		}, nil))
+		AddChild(contentTypes, NewNonTextNode("Override", map[string]string{
+			"PartName":    "/" + settingsPath,
+			"ContentType": SETTINGS_CONTENT_TYPE,
+		}, nil))
+		zip.SetFile(relsPath, BuildXml(rels, xmlOptions, ""))
+	} else {
+		settings, err = parsePath(zip, settingsPath)
+		if err != nil {
+			return err
+		}
+	}
+
+	if updateFields := findChild(settings, "w:updateFields"); updateFields != nil {
+		updateFields.Attrs["w:val"] = "true"
+	} else {
+		updateFields = NewNonTextNode("w:updateFields", map[string]string{"w:val": "true"}, nil)
+		updateFields.SetParent(settings)
+		children := settings.Children()
+		// Extension elements (w14:, w15:, ...) and math properties come last too
+		idx := slices.IndexFunc(children, func(child Node) bool {
+			nonTextChild, ok := child.(*NonTextNode)
+			return ok && (slices.Contains(SETTINGS_AFTER_UPDATE_FIELDS, nonTextChild.Tag) || !strings.HasPrefix(nonTextChild.Tag, "w:"))
+		})
+		if idx < 0 {
+			idx = len(children)
+		}
+		settings.SetChildren(slices.Insert(slices.Clone(children), idx, Node(updateFields)))
+	}
+	zip.SetFile(settingsPath, BuildXml(settings, xmlOptions, ""))
+	return nil
+}
+
+type tocHeading struct {
+	level  int
+	text   string
+	anchor string
+}
+
+// UpdateToc regenerates the entries of the tables of contents (TOC fields) of the report from its
+// headings, which get `_Toc` bookmarks. The page numbers are left as placeholders, to be updated
+// by the word processor (see the UpdateFields option).
+func UpdateToc(report Node, documentComponent string, zip *ZipArchive) error {
+	fields := slices.DeleteFunc(findFields(report), func(f *field) bool {
+		instruction := strings.Fields(f.instruction)
+		return len(instruction) == 0 || strings.ToUpper(instruction[0]) != "TOC"
+	})
+	if len(fields) == 0 {
+		return nil
+	}
+	styleLevels, err := getHeadingStyles(documentComponent, zip)
+	if err != nil {
+		return err
+	}
+
+	bookmarkId, bookmarkNames := 0, map[string]bool{}
+	for _, bookmark := range findDescendants(report, "w:bookmarkStart") {
+		id, _ := strconv.Atoi(bookmark.Attrs["w:id"])
+		bookmarkId = max(bookmarkId, id)
+		bookmarkNames[bookmark.Attrs["w:name"]] = true
+	}
+	textWidth := getTextWidth(report)
+
+	for _, tocField := range fields {
+		beginP, endP := findAncestor(tocField.begin, P_TAG), findAncestor(tocField.end, P_TAG)
+		if beginP == nil || endP == nil || beginP.Parent() != endP.Parent() {
+			continue
+		}
+		siblings := beginP.Parent().Children()
+		beginIdx, endIdx := slices.Index(siblings, Node(beginP)), slices.Index(siblings, Node(endP))
+		if beginIdx < 0 || endIdx < beginIdx {
+			continue
+		}
+		tocParagraphs := siblings[beginIdx : endIdx+1]
+
+		minLevel, maxLevel := 1, 9
+		if match := tocLevelsRegexp.FindStringSubmatch(tocField.instruction); match != nil {
+			minLevel, _ = strconv.Atoi(match[1])
+			maxLevel, _ = strconv.Atoi(match[2])
+		}
+		headings := []tocHeading{}
+		for _, p := range findDescendants(report, P_TAG) {
+			if slices.ContainsFunc(tocParagraphs, func(tocP Node) bool { return tocP == p || findAncestor(p, P_TAG) == tocP }) {
+				continue
+			}
+			level := getHeadingLevel(p, styleLevels)
+			text := strings.TrimSpace(getParagraphText(p))
+			if level < minLevel || level > maxLevel || text == "" {
+				continue
+			}
+			anchor := ""
+			for _, bookmark := range findChildren(p, "w:bookmarkStart") {
+				if strings.HasPrefix(bookmark.Attrs["w:name"], "_Toc") {
+					anchor = bookmark.Attrs["w:name"]
+				}
+			}
+			if anchor == "" {
+				bookmarkId++
+				anchor = fmt.Sprintf("_Toc%d", bookmarkId)
+				for bookmarkNames[anchor] {
+					anchor += "_"
+				}
+				bookmarkNames[anchor] = true
+				addBookmark(p, anchor, fmt.Sprint(bookmarkId))
+			}
+			headings = append(headings, tocHeading{level: level, text: text, anchor: anchor})
+		}
+
+		entries := newTocEntries(tocField.instruction, headings, textWidth)
+		parent := beginP.Parent()
+		for _, entry := range entries {
+			entry.SetParent(parent)
+		}
+		parent.SetChildren(slices.Concat(siblings[:beginIdx], entries, siblings[endIdx+1:]))
+	}
+	return nil
+}
+
+// getHeadingStyles returns the outline level (1-9) of the heading paragraph styles, by style id
+func getHeadingStyles(documentComponent string, zip *ZipArchive) (map[string]int, error) {
+	levels := map[string]int{}
+	rels, err := getRelsFromZip(zip, fmt.Sprintf("%s/_rels/%s.rels", TEMPLATE_PATH, documentComponent))
+	if err != nil {
+		return nil, err
+	}
+	stylesPath := findRelTarget(rels, STYLES_RELATION_TYPE)
+	if stylesPath == "" {
+		return levels, nil
+	}
+	styles, err := parsePath(zip, stylesPath)
+	if err != nil {
+		return nil, err
+	}
+	for _, style := range findChildren(styles, "w:style") {
+		if style.Attrs["w:type"] != "paragraph" {
+			continue
+		}
+		if outlineLevel := getOutlineLevel(style); outlineLevel > 0 {
+			levels[style.Attrs["w:styleId"]] = outlineLevel
+		} else if name := findChild(style, "w:name"); name != nil {
+			if match := headingStyleRegexp.FindStringSubmatch(name.Attrs["w:val"]); match != nil {
+				levels[style.Attrs["w:styleId"]], _ = strconv.Atoi(match[1])
+			}
+		}
+	}
+	return levels, nil
+}
+
+// getOutlineLevel returns the outline level (1-9) of the paragraph properties of node, or 0
+func getOutlineLevel(node *NonTextNode) int {
+	pPr := findChild(node, "w:pPr")
+	if pPr == nil {
+		return 0
+	}
+	outlineLvl := findChild(pPr, "w:outlineLvl")
+	if outlineLvl == nil {
+		return 0
+	}
+	level, err := strconv.Atoi(outlineLvl.Attrs["w:val"])
+	if err != nil || level > 8 {
+		return 0
+	}
+	return level + 1
+}
+
+func getHeadingLevel(p *NonTextNode, styleLevels map[string]int) int {
+	if level := getOutlineLevel(p); level > 0 {
+		return level
+	}
+	pPr := findChild(p, "w:pPr")
+	if pPr == nil {
+		return 0
+	}
+	pStyle := findChild(pPr, "w:pStyle")
+	if pStyle == nil {
+		return 0
+	}
+	if level, ok := styleLevels[pStyle.Attrs["w:val"]]; ok {
+		return level
+	}
+	// Documents without styles part (the default heading style ids)
+	if match := headingStyleRegexp.FindStringSubmatch(pStyle.Attrs["w:val"]); match != nil {
+		level, _ := strconv.Atoi(match[1])
+		return level
+	}
+	return 0
+}
+
+func getParagraphText(p Node) string {
+	var text strings.Builder
+	for _, t := range findDescendants(p, T_TAG) {
+		text.WriteString(getNodeText(t))
+	}
+	return text.String()
+}
+
+// addBookmark wraps the content of the paragraph p in a bookmark
+func addBookmark(p *NonTextNode, name string, id string) {
+	start := NewNonTextNode("w:bookmarkStart", map[string]string{"w:id": id, "w:name": name}, nil)
+	start.SetParent(p)
+	children := slices.Clone(p.Children())
+	insertAt := 0
+	if len(children) > 0 {
+		if pPr, ok := children[0].(*NonTextNode); ok && pPr.Tag == "w:pPr" {
+			insertAt = 1
+		}
+	}
+	p.SetChildren(slices.Insert(children, insertAt, Node(start)))
+	AddChild(p, NewNonTextNode("w:bookmarkEnd", map[string]string{"w:id": id}, nil))
+}
+
+// getTextWidth returns the width between the margins of the last section of the report, in twips
+func getTextWidth(report Node) int {
+	body := findChild(report, "w:body")
+	if body == nil {
+		return DEFAULT_TABLE_WIDTH
+	}
+	sectPr := findChild(body, "w:sectPr")
+	if sectPr == nil {
+		return DEFAULT_TABLE_WIDTH
+	}
+	pgMar := findChild(sectPr, "w:pgMar")
+	width := getIntAttr(findChild(sectPr, "w:pgSz"), "w:w") - getIntAttr(pgMar, "w:left") - getIntAttr(pgMar, "w:right")
+	if width <= 0 {
+		return DEFAULT_TABLE_WIDTH
+	}
+	return width
+}
+
+// newTocEntries returns the paragraphs of a TOC field listing headings
+func newTocEntries(instruction string, headings []tocHeading, textWidth int) []Node {
+	node := NewNonTextNode
+	tocBegin, _, tocEnd := newFieldRuns(instruction, "")
+	if len(headings) == 0 {
+		return []Node{node(P_TAG, nil, slices.Concat(tocBegin, []Node{
+			node(R_TAG, nil, []Node{node(T_TAG, nil, []Node{NewTextNode(TOC_EMPTY_TEXT)})}),
+			tocEnd,
+		}))}
+	}
+	hyperlinks := slices.ContainsFunc(strings.Fields(instruction), func(s string) bool { return strings.EqualFold(s, `\h`) })
+	entries := make([]Node, len(headings))
+	for i, heading := range headings {
+		pageBegin, pageResult, pageEnd := newFieldRuns(fmt.Sprintf(" PAGEREF %s \\h ", heading.anchor), TOC_PAGE_PLACEHOLDER)
+		content := slices.Concat([]Node{
+			node(R_TAG, nil, []Node{node(T_TAG, map[string]string{"xml:space": "preserve"}, []Node{NewTextNode(heading.text)})}),
+			node(R_TAG, nil, []Node{node("w:tab", nil, nil)}),
+		}, pageBegin, pageResult, []Node{pageEnd})
+		if hyperlinks {
+			content = []Node{node("w:hyperlink", map[string]string{"w:anchor": heading.anchor, "w:history": "1"}, content)}
+		}
+		if i == 0 {
+			content = slices.Concat(tocBegin, content)
+		}
+		if i == len(headings)-1 {
+			content = append(content, tocEnd)
+		}
+		entries[i] = node(P_TAG, nil, slices.Concat([]Node{
+			node("w:pPr", nil, []Node{
+				node("w:pStyle", map[string]string{"w:val": fmt.Sprintf("TOC%d", heading.level)}, nil),
+				node("w:tabs", nil, []Node{
+					node("w:tab", map[string]string{"w:val": "right", "w:leader": "dot", "w:pos": fmt.Sprint(textWidth)}, nil),
+				}),
+			}),
+		}, content))
+	}
+	return entries
+}
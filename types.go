@@ -13,6 +13,9 @@ const (
 	TR_TAG       = "w:tr"
 	TC_TAG       = "w:tc"
 	DOCPR_TAG    = "wp:docPr"
+	INLINE_TAG   = "wp:inline"
+	ANCHOR_TAG   = "wp:anchor"
+	BLIP_TAG     = "a:blip"
 	VSHAPE_TAG   = "v:shape"
 	ALTCHUNK_TAG = "w:altChunk"
 
@@ -20,6 +23,10 @@ const (
 	CONTENT_TYPES_PATH            = "[Content_Types].xml"
 	TEMPLATE_PATH                 = "word"
 	DEFAULT_LITERAL_XML_DELIMITER = "||"
+	SVG_BLIP_EXT_URI              = "{96DAC541-7B7A-43D3-8B79-37D633B846F1}"
+
+	EMU_PER_CM   = 360e3
+	TWIPS_PER_CM = 1440 / 2.54
 )
 
 type Node interface {
@@ -139,8 +146,35 @@ type Context struct {
 	shorthands               map[string]string
 	options                  CreateReportOptions
 	//jsSandbox                SandBox
-	textRunPropsNode *NonTextNode
-
+	textRunPropsNode        *NonTextNode
+	pendingCellStyle        *cellStyle
+	pendingRowStyle         *cellStyle
+	pendingColumn           *pendingColumn
+	pendingPlaceholderImage *placeholderImage
+	tableColumns            map[Node]*tableColumns
+	convertedImages         map[string]*Image
+	chartId                 int
+	charts                  Charts
+	pendingPlaceholderChart *ChartPars
+	chartUpdates            map[string]*ChartPars
+	pendingIncludeNodes     []Node
+	includes                Includes
+	includeStack            []string           // names of the templates being included, to detect recursion
+	includeData             map[string][]byte  // [name]loaded template
+	pendingRunNodes         []*pendingRunNodes // inserted in the `w:r` of their command (breaks, fields)
+	pendingBookmarks        []string
+	bookmarkId              int
+	seqCounters             map[string]int       // [identifier]last SEQ field value
+	pendingRefs             map[*TextNode]string // [result text]bookmark of the REF fields
+	pendingSectPr           *NonTextNode
+	sectionBreaks           map[*NonTextNode]*sectionBreak
+	pendingList             *pendingList
+	pendingFormCheckboxes   []bool // values of the legacy check box form fields of the paragraph
+	numbering               *Numbering
+	revisions               *Revisions
+	openComments            []*comment // comments waiting for their END-COMMENT
+
+	ifNames      map[Node]string // [IF or END-IF command node]name of the IF
 	pIfCheckMap  map[Node]string
 	trIfCheckMap map[Node]string
 }
@@ -152,9 +186,15 @@ type Delimiters struct {
 	Close string
 }
 
+// Function is a function callable from the template code. Returning an error fails the command
 type Function func(args ...any) VarValue
+
+// Functions are the functions callable from the template code, by name. They take precedence over the built-in ones
 type Functions map[string]Function
 
+// IncludeLoader returns the content of the .docx template included by `INCLUDE name`
+type IncludeLoader func(name string) ([]byte, error)
+
 type CreateReportOptions struct {
 	CmdDelimiter        *Delimiters
 	LiteralXmlDelimiter string
@@ -168,7 +208,27 @@ type CreateReportOptions struct {
 	FixSmartQuotes             bool
 	ProcessLineBreaksAsNewText bool
 	MaximumWalkingDepth        int
-	Functions                  Functions
+	Functions                  Functions         // optional: functions callable from the template code; returning an error fails the command
+	ImageCompression           *ImageCompression // optional: applied to all images, unless they have their own settings
+	ChartsByTitle              map[string]string // optional: template charts to update, by title: [title]expression
+	Barcodes                   BarcodeOptions    // optional: settings of the built-in qr, code128 and ean13 functions
+	IncludeLoader              IncludeLoader     // optional: loads the templates of the INCLUDE command
+	UpdateFields               bool              // optional: have Word update the fields (TOC, page numbers...) when opening the report
+	UpdateToc                  bool              // optional: regenerate the entries of the tables of contents from the headings of the report
+	CoreProperties             map[string]string // optional: core properties of the report (title, author...), by name: [name]expression
+	CustomProperties           map[string]string // optional: custom properties of the report, by name: [name]expression
+	ContentControls            bool              // optional: fill the content controls (`w:sdt`) bound to a data path by their tag or alias
+	UnwrapContentControls      bool              // optional: replace the filled content controls with their content
+	CustomXml                  map[string]string // optional: data of the custom XML parts, by namespace of their root element: [namespace]expression
+	RevisionAuthor             string            // optional: default author of the generated tracked changes and comments
+	CleanTemplate              bool              // optional: accept the tracked changes of the template, and remove its comments and proofing marks before processing
+}
+
+// BarcodeOptions configures the images generated by the built-in qr, code128 and ean13 functions
+type BarcodeOptions struct {
+	Format          string // optional: "png" (default) or "svg"
+	ErrorCorrection string // optional, QR codes only: "L", "M" (default), "Q" or "H"
+	QuietZone       int    // optional: blank margin in modules (default: 4 for QR codes, 10 for barcodes; negative for none)
 }
 
 type VarValue = any
@@ -187,20 +247,76 @@ var ImageExtensions []string = []string{
 	".svg",
 }
 
+// ConvertedImageExtensions are the image formats that are converted to PNG
+var ConvertedImageExtensions []string = []string{
+	".bmp",
+	".tif",
+	".tiff",
+	".webp",
+}
+
 type Thumbnail struct {
 	Image
 	Width  int
 	Height int
 }
 type ImagePars struct {
-	Extension string // [".png", ".gif", ".jpg", ".jpeg", ".svg"]
+	Extension string // [".png", ".gif", ".jpg", ".jpeg", ".svg"], or [".bmp", ".tif", ".tiff", ".webp"] (converted to PNG)
 	Data      []byte
-	Width     float32
-	Height    float32
-	Thumbnail *Thumbnail // optional
-	Alt       string     // optional
-	Rotation  int        // optional
-	Caption   string     // optional
+	Width     float32      // cm, optional: computed from Height and the aspect ratio when missing
+	Height    float32      // cm, optional: computed from Width and the aspect ratio when missing
+	MaxWidth  float32      // cm, optional: the image is scaled down to fit in MaxWidth x MaxHeight
+	MaxHeight float32      // cm, optional
+	Dpi       float32      // optional: overrides the resolution read from the image (natural size)
+	Thumbnail *Thumbnail   // optional
+	Alt       string       // optional
+	Rotation  int          // optional
+	Caption   string       // optional
+	Stretch   bool         // optional: placeholder pictures only, fill the placeholder instead of keeping the aspect ratio
+	Anchor    *ImageAnchor // optional: floating image, instead of an inline one
+
+	Compression *ImageCompression // optional: overrides CreateReportOptions.ImageCompression
+}
+
+// ImageCompression reduces the size of PNG and JPEG images.
+// Re-encoded images are upright (EXIF orientation applied), without metadata.
+type ImageCompression struct {
+	MaxDpi        float32 // optional: images are downscaled to this resolution, at their size on the page
+	JpegQuality   int     // optional: re-encode JPEG images with this quality (1-100)
+	StripMetadata bool    // optional: remove EXIF and other metadata
+}
+
+// ImageAnchor places an image relatively to the page, the margins or the paragraph,
+// instead of inline with the text
+type ImageAnchor struct {
+	RelativeFromH string  // optional: ["page", "margin", "column", "character"], defaults to "column"
+	RelativeFromV string  // optional: ["page", "margin", "paragraph", "line"], defaults to "paragraph"
+	OffsetX       float32 // cm, from the left of RelativeFromH
+	OffsetY       float32 // cm, from the top of RelativeFromV
+	AlignH        string  // optional: ["left", "center", "right"], overrides OffsetX
+	AlignV        string  // optional: ["top", "center", "bottom"], overrides OffsetY
+	Wrap          string  // optional: ["square", "tight", "topAndBottom", "none"], defaults to "none"
+	BehindDoc     bool    // optional: place the image behind the text (e.g. watermarks), rather than in front of it
+}
+
+type ChartPars struct {
+	Type       string        // ["bar", "column", "line", "pie", "scatter"] ("bar" has horizontal bars)
+	Title      string        // optional
+	Categories []string      // labels of the values (except for scatter charts)
+	Series     []ChartSeries //
+	XAxisTitle string        // optional
+	YAxisTitle string        // optional
+	HideLegend bool          // optional
+	Width      float32       // cm, optional: defaults to 15
+	Height     float32       // cm, optional: defaults to 9
+	Alt        string        // optional: defaults to the title
+}
+
+type ChartSeries struct {
+	Name    string
+	Values  []float64 // one per category (NaN for a missing value)
+	XValues []float64 // scatter charts only: the x of each value
+	Color   string    // optional: hex color, e.g. "4472C4" (ignored by pie charts, which have a color per category)
 }
 
 type LoopStatus struct {
@@ -219,6 +335,7 @@ type LinkPars struct {
 
 type Link struct{ url string }
 type Links map[string]Link
+type Charts map[string]Node // [relId]chart part
 type Htmls map[string]string
 
 func isSlice(v any) bool {
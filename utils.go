@@ -4,6 +4,7 @@ import (
 	"errors"
 	"fmt"
 	"log/slog"
+	"maps"
 	"slices"
 	"strings"
 )
@@ -60,7 +61,7 @@ func CloneNodeWithoutChildren(node Node) Node {
 				ParentNode: node.Parent(),
 			},
 			Tag:   nd.Tag,
-			Attrs: nd.Attrs,
+			Attrs: maps.Clone(nd.Attrs),
 		}
 	case *TextNode:
 		return &TextNode{
@@ -174,3 +175,56 @@ func logLoop(loops []LoopStatus) {
 	builder.WriteString(fmt.Sprint(len(loopLevel.loopOver)))
 	slog.Debug(builder.String())
 }
+
+// findAncestor returns the closest ancestor of node with the given tag, or nil
+func findAncestor(node Node, tag string) *NonTextNode {
+	for parent := node.Parent(); parent != nil; parent = parent.Parent() {
+		if nonTextParent, ok := parent.(*NonTextNode); ok && nonTextParent.Tag == tag {
+			return nonTextParent
+		}
+	}
+	return nil
+}
+
+// findDescendants returns all the descendants of node with the given tag, in document order
+func findDescendants(node Node, tag string) []*NonTextNode {
+	found := []*NonTextNode{}
+	for _, child := range node.Children() {
+		if nonTextChild, ok := child.(*NonTextNode); ok {
+			if nonTextChild.Tag == tag {
+				found = append(found, nonTextChild)
+			}
+			found = append(found, findDescendants(nonTextChild, tag)...)
+		}
+	}
+	return found
+}
+
+// cloneNode returns a deep copy of node
+func cloneNode(node Node) Node {
+	clone := CloneNodeWithoutChildren(node)
+	clone.SetParent(nil)
+	for _, child := range node.Children() {
+		AddChild(clone, cloneNode(child))
+	}
+	return clone
+}
+
+// removeNode removes node from its parent
+func removeNode(node Node) {
+	parent := node.Parent()
+	if parent == nil {
+		return
+	}
+	parent.SetChildren(slices.DeleteFunc(slices.Clone(parent.Children()), func(child Node) bool { return child == node }))
+	node.SetParent(nil)
+}
+
+// insertAfter inserts node right after ref, in the parent of ref
+func insertAfter(ref Node, node Node) {
+	parent := ref.Parent()
+	children := parent.Children()
+	idx := slices.Index(children, ref)
+	parent.SetChildren(slices.Insert(slices.Clone(children), idx+1, node))
+	node.SetParent(parent)
+}
@@ -3,7 +3,9 @@ package godocx
 import (
 	"errors"
 	"fmt"
+	"io/fs"
 	"log/slog"
+	"maps"
 	"slices"
 	"strings"
 )
@@ -42,13 +44,17 @@ func ProcessImages(images Images, documentComponent string, zip *ZipArchive) err
 		extension := image.Extension
 		imgData := image.Data
 
-		// `template_${documentComponent}_${imageId}${extension}`;
-		imgName := fmt.Sprintf("template_%s_%s%s", documentComponent, imageId, extension)
+		// Image ids are content hashes: identical images share the same media part
+		imgName := imageId + extension
 		// logger.debug(`Writing image ${imageId} (${imgName})...`);
 		slog.Debug("Writing image " + imageId + " (" + imgName + ")...")
 		imgPath := fmt.Sprintf("%s/media/%s", TEMPLATE_PATH, imgName)
 		zip.SetFile(imgPath, imgData)
 
+		// The document may already have the image (e.g. when merging generated documents)
+		if hasRelationship(rels, imageId) {
+			continue
+		}
 		AddChild(rels, NewNonTextNode("Relationship", map[string]string{
 			"Id":     imageId,
 			"Type":   "http://schemas.openxmlformats.org/officeDocument/2006/relationships/image",
@@ -137,9 +143,47 @@ func ProcessLinks(links Links, documentComponent string, zip *ZipArchive) error
 	return nil
 }
 
+// ProcessCharts writes the chart parts, with their relationships and content types.
+// The parts are named after the first free `word/charts/chartN.xml`, as the template may have charts too.
+func ProcessCharts(charts Charts, documentComponent string, zip *ZipArchive, contentTypes *NonTextNode) error {
+	slog.Debug("Processing charts for " + documentComponent + "...")
+	if len(charts) == 0 {
+		return nil
+	}
+	relsPath := fmt.Sprintf("%s/_rels/%s.rels", TEMPLATE_PATH, documentComponent)
+	rels, err := getRelsFromZip(zip, relsPath)
+	if err != nil {
+		return err
+	}
+
+	xmlOptions := XmlOptions{LiteralXmlDelimiter: DEFAULT_LITERAL_XML_DELIMITER}
+	n := 1
+	for _, chartId := range slices.Sorted(maps.Keys(charts)) {
+		for zip.HasFile(fmt.Sprintf("%s/charts/chart%d.xml", TEMPLATE_PATH, n)) {
+			n++
+		}
+		chartName := fmt.Sprintf("chart%d.xml", n)
+		slog.Debug("Writing chart " + chartId + " (" + chartName + ")...")
+		zip.SetFile(fmt.Sprintf("%s/charts/%s", TEMPLATE_PATH, chartName), BuildXml(charts[chartId], xmlOptions, ""))
+
+		AddChild(rels, NewNonTextNode("Relationship", map[string]string{
+			"Id":     chartId,
+			"Type":   CHART_RELATION_TYPE,
+			"Target": "charts/" + chartName,
+		}, nil))
+		AddChild(contentTypes, NewNonTextNode("Override", map[string]string{
+			"PartName":    fmt.Sprintf("/%s/charts/%s", TEMPLATE_PATH, chartName),
+			"ContentType": CHART_CONTENT_TYPE,
+		}, nil))
+	}
+	zip.SetFile(relsPath, BuildXml(rels, xmlOptions, ""))
+	return nil
+}
+
 func getRelsFromZip(zip *ZipArchive, relsPath string) (Node, error) {
 	relsXmlBytes, err := zip.GetFile(relsPath)
-	if err != nil {
+	// A part without relationships (e.g. a plain footer) has no rels part yet
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
 		return nil, err
 	}
 
@@ -0,0 +1,87 @@
+package godocx
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+)
+
+const WORKBOOK_SHEET_NAME = "Sheet1"
+
+// columnName gives the name of a spreadsheet column: A, B, ... Z, AA, AB...
+func columnName(idx int) string {
+	name := ""
+	for idx++; idx > 0; idx = (idx - 1) / 26 {
+		name = string(rune('A'+(idx-1)%26)) + name
+	}
+	return name
+}
+
+// buildWorkbook generates a minimal xlsx file, with a single sheet filled with rows
+// of strings and numbers (nil for empty cells)
+func buildWorkbook(rows [][]any) ([]byte, error) {
+	node := NewNonTextNode
+	xmlOptions := XmlOptions{LiteralXmlDelimiter: DEFAULT_LITERAL_XML_DELIMITER}
+
+	sheetRows := []Node{}
+	for i, row := range rows {
+		cells := []Node{}
+		for j, value := range row {
+			ref := fmt.Sprintf("%s%d", columnName(j), i+1)
+			switch v := value.(type) {
+			case nil:
+				continue
+			case float64:
+				if formatted := formatChartNumber(v); formatted != "" {
+					cells = append(cells, node("c", map[string]string{"r": ref}, []Node{chartText("v", formatted)}))
+				}
+			default:
+				cells = append(cells, node("c", map[string]string{"r": ref, "t": "inlineStr"}, []Node{
+					node("is", map[string]string{}, []Node{chartText("t", fmt.Sprint(v))}),
+				}))
+			}
+		}
+		sheetRows = append(sheetRows, node("row", map[string]string{"r": fmt.Sprint(i + 1)}, cells))
+	}
+
+	files := []struct {
+		name    string
+		content Node
+	}{
+		{CONTENT_TYPES_PATH, node("Types", map[string]string{"xmlns": "http://schemas.openxmlformats.org/package/2006/content-types"}, []Node{
+			node("Default", map[string]string{"Extension": "rels", "ContentType": "application/vnd.openxmlformats-package.relationships+xml"}, nil),
+			node("Default", map[string]string{"Extension": "xml", "ContentType": "application/xml"}, nil),
+			node("Override", map[string]string{"PartName": "/xl/workbook.xml", "ContentType": "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"}, nil),
+			node("Override", map[string]string{"PartName": "/xl/worksheets/sheet1.xml", "ContentType": "application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"}, nil),
+		})},
+		{"_rels/.rels", node("Relationships", map[string]string{"xmlns": "http://schemas.openxmlformats.org/package/2006/relationships"}, []Node{
+			node("Relationship", map[string]string{"Id": "rId1", "Type": "http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument", "Target": "xl/workbook.xml"}, nil),
+		})},
+		{"xl/workbook.xml", node("workbook", map[string]string{
+			"xmlns":   "http://schemas.openxmlformats.org/spreadsheetml/2006/main",
+			"xmlns:r": "http://schemas.openxmlformats.org/officeDocument/2006/relationships",
+		}, []Node{
+			node("sheets", map[string]string{}, []Node{
+				node("sheet", map[string]string{"name": WORKBOOK_SHEET_NAME, "sheetId": "1", "r:id": "rId1"}, nil),
+			}),
+		})},
+		{"xl/_rels/workbook.xml.rels", node("Relationships", map[string]string{"xmlns": "http://schemas.openxmlformats.org/package/2006/relationships"}, []Node{
+			node("Relationship", map[string]string{"Id": "rId1", "Type": "http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet", "Target": "worksheets/sheet1.xml"}, nil),
+		})},
+		{"xl/worksheets/sheet1.xml", node("worksheet", map[string]string{"xmlns": "http://schemas.openxmlformats.org/spreadsheetml/2006/main"}, []Node{
+			node("sheetData", map[string]string{}, sheetRows),
+		})},
+	}
+
+	out := new(bytes.Buffer)
+	writer := zip.NewWriter(out)
+	for _, file := range files {
+		if err := ZipSet(writer, file.name, BuildXml(file.content, xmlOptions, "")); err != nil {
+			return nil, err
+		}
+	}
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
@@ -2,6 +2,8 @@ package godocx
 
 import (
 	"archive/zip"
+	"bytes"
+	"errors"
 	"io"
 	"io/fs"
 	"slices"
@@ -55,7 +57,8 @@ func ZipSet(z *zip.Writer, filename string, data []byte) error {
 }
 
 type ZipArchive struct {
-	reader *zip.ReadCloser
+	reader *zip.Reader
+	closer io.Closer
 	writer *zip.Writer
 	files  map[string][]byte
 }
@@ -67,16 +70,37 @@ func NewZipArchive(name string, w io.Writer) (*ZipArchive, error) {
 	}
 	writer := zip.NewWriter(w)
 	return &ZipArchive{
-		reader: reader,
+		reader: &reader.Reader,
+		closer: reader,
 		writer: writer,
 		files:  make(map[string][]byte),
 	}, nil
 }
 
+// NewZipArchiveFromBytes opens an archive held in memory (e.g. a template loaded by the caller)
+func NewZipArchiveFromBytes(data []byte, w io.Writer) (*ZipArchive, error) {
+	reader, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, err
+	}
+	return &ZipArchive{
+		reader: reader,
+		writer: zip.NewWriter(w),
+		files:  make(map[string][]byte),
+	}, nil
+}
+
 func (za *ZipArchive) SetFile(name string, data []byte) {
 	za.files[name] = data
 }
 
+func (za *ZipArchive) HasFile(name string) bool {
+	if _, ok := za.files[name]; ok {
+		return true
+	}
+	return slices.ContainsFunc(za.reader.File, func(file *zip.File) bool { return file.Name == name })
+}
+
 func (za *ZipArchive) GetFile(name string) ([]byte, error) {
 	if data, ok := za.files[name]; ok {
 		return data, nil
@@ -132,5 +156,9 @@ func (za *ZipArchive) Close() error {
 		}
 	}
 
-	return za.writer.Close()
+	err := za.writer.Close()
+	if za.closer != nil {
+		return errors.Join(err, za.closer.Close())
+	}
+	return err
 }